@@ -0,0 +1,113 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterPolicyTemplateSpec defines the desired state of ClusterPolicyTemplate
+type ClusterPolicyTemplateSpec struct {
+	// +optional
+	// Description is the description of template
+	Description string `json:"description,omitempty"`
+	// +optional
+	// Parameters is a list of typed parameters which can be used in statements as ${params.<name>}
+	Parameters []TemplateParameter `json:"parameters,omitempty"`
+	// Statement defines the statements rendered into the inline policy of iam role
+	Statement []StatementSpec `json:"statement"`
+}
+
+// +kubebuilder:validation:Enum=String;StringList;Integer;Boolean
+type TemplateParameterType string
+
+var (
+	TemplateParameterString TemplateParameterType = "String"
+	// TemplateParameterStringList can only be used as a whole item of resource or action, it is expanded to several items
+	TemplateParameterStringList TemplateParameterType = "StringList"
+	TemplateParameterInteger    TemplateParameterType = "Integer"
+	TemplateParameterBoolean    TemplateParameterType = "Boolean"
+)
+
+// TemplateParameter defines a parameter of ClusterPolicyTemplate
+type TemplateParameter struct {
+	// +kubebuilder:validation:Pattern=`^[A-Za-z][A-Za-z0-9_]*$`
+	// Name is the name of parameter
+	Name string `json:"name"`
+	// +optional
+	// Description is the description of parameter
+	Description string `json:"description,omitempty"`
+	// +optional
+	// Type is the type of parameter, default is String
+	Type TemplateParameterType `json:"type,omitempty"`
+	// +optional
+	// Required means the parameter must be set by the arguments of irsa
+	Required bool `json:"required,omitempty"`
+	// +optional
+	// Default is the value of parameter if it is not set by the arguments of irsa
+	Default *TemplateValue `json:"default,omitempty"`
+}
+
+// TemplateValue is the value of template parameter, Values is used by StringList and Value is used by other types
+type TemplateValue struct {
+	// +optional
+	Value string `json:"value,omitempty"`
+	// +optional
+	Values []string `json:"values,omitempty"`
+}
+
+// PolicyTemplateRef references a ClusterPolicyTemplate with arguments
+type PolicyTemplateRef struct {
+	// Name is the name of ClusterPolicyTemplate
+	Name string `json:"name"`
+	// +optional
+	// Arguments are the values of template parameters
+	Arguments []TemplateArgument `json:"arguments,omitempty"`
+}
+
+// TemplateArgument sets the value of a template parameter
+type TemplateArgument struct {
+	// Name is the name of template parameter
+	Name          string `json:"name"`
+	TemplateValue `json:",inline"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// ClusterPolicyTemplate is the Schema for the clusterpolicytemplates API
+// +kubebuilder:printcolumn:name="Description",type=string,JSONPath=`.spec.description`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type ClusterPolicyTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClusterPolicyTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterPolicyTemplateList contains a list of ClusterPolicyTemplate
+type ClusterPolicyTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterPolicyTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterPolicyTemplate{}, &ClusterPolicyTemplateList{})
+}
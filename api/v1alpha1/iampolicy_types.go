@@ -0,0 +1,95 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IamPolicySpec defines the desired state of IamPolicy
+type IamPolicySpec struct {
+	// +optional
+	// Description is the description of customer managed policy, it cannot be changed after the policy is created
+	Description string `json:"description,omitempty"`
+	// Policy defines the statements of customer managed policy
+	Policy InlinePolicySpec `json:"policy"`
+}
+
+// IamPolicyStatus defines the observed state of IamPolicy
+type IamPolicyStatus struct {
+	// +optional
+	// PolicyArn is the arn of customer managed policy in aws account if the policy is created
+	PolicyArn string `json:"policyArn,omitempty"`
+	// +optional
+	// Condition is the status of customer managed policy
+	Condition IamPolicyCondition `json:"condition,omitempty"`
+	// +optional
+	// Reason is a brief string that describes any failure.
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	// AttachedRoles is a list of iam roles which the policy is attached to
+	AttachedRoles []string `json:"attachedRoles,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Failed;Synced;InUse
+type IamPolicyCondition string
+
+var (
+	IamPolicyFailed IamPolicyCondition = "Failed"
+	IamPolicySynced IamPolicyCondition = "Synced"
+	// IamPolicyInUse means the policy is being deleted but still attached to iam roles
+	IamPolicyInUse IamPolicyCondition = "InUse"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// IamPolicy is the Schema for the iampolicies API
+// +kubebuilder:printcolumn:name="PolicyArn",type=string,JSONPath=`.status.policyArn`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.condition`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type IamPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IamPolicySpec   `json:"spec,omitempty"`
+	Status IamPolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// IamPolicyList contains a list of IamPolicy
+type IamPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IamPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IamPolicy{}, &IamPolicyList{})
+}
+
+// AwsIamPolicyName returns the name of customer managed policy in aws account
+func (i *IamPolicy) AwsIamPolicyName(prefix, cluster string) string {
+	prefixClusterName := fmt.Sprintf("%s-%s", prefix, cluster)
+	if prefix == "" {
+		prefixClusterName = cluster
+	}
+	return fmt.Sprintf("%s-%s-%s-policy", prefixClusterName, i.GetNamespace(), i.GetName())
+}
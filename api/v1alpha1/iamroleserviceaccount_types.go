@@ -19,7 +19,9 @@ package v1alpha1
 import (
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -41,18 +43,311 @@ type IamRoleServiceAccountSpec struct {
 	// Policy defines the policy list of iam role in aws account
 	Policy *PolicySpec `json:"policy,omitempty"`
 
+	// +optional
+	// Access is a list of high-level access intents, they are compiled to least-privilege statements merged into the default inline policy,
+	// it cannot be used with InlinePolicyDocument or InlinePolicyFrom
+	Access []AccessSpec `json:"access,omitempty"`
+
+	// +optional
+	// Trust defines the conditions of web identity in the trust relationship of iam role ( only if the iam role is created by irsa-controller )
+	Trust *TrustSpec `json:"trust,omitempty"`
+
 	// +optional
 	// Tags is a list of tags to apply to the IAM role ( only if the iam role is created by irsa-controller )
 	Tags map[string]string `json:"tags,omitempty"`
+
+	// +optional
+	// ServiceAccounts is a list of service accounts which will be annotated with the iam role and trusted by it
+	// if it is empty, a service account with the same name and namespace as irsa will be used
+	ServiceAccounts []ServiceAccountRef `json:"serviceAccounts,omitempty"`
+
+	// +optional
+	// ServiceAccount defines how the service accounts bound to the iam role are managed
+	ServiceAccount *ServiceAccountSpec `json:"serviceAccount,omitempty"`
+
+	// +optional
+	// ServiceAccountTemplate defines the metadata and settings of the service accounts created by irsa-controller
+	ServiceAccountTemplate *ServiceAccountTemplateSpec `json:"serviceAccountTemplate,omitempty"`
+
+	// +optional
+	// Outputs is a list of ConfigMaps or Secrets in the namespace of irsa which the details of iam role will be published to
+	Outputs []OutputSpec `json:"outputs,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=ConfigMap;Secret
+type OutputKind string
+
+var (
+	OutputConfigMap OutputKind = "ConfigMap"
+	OutputSecret    OutputKind = "Secret"
+)
+
+// OutputSpec defines the ConfigMap or Secret which the details of iam role are published to
+type OutputSpec struct {
+	// +optional
+	// Kind is the kind of output, default is ConfigMap
+	Kind OutputKind `json:"kind,omitempty"`
+	// Name is the name of ConfigMap or Secret, it will be created and owned by irsa
+	Name string `json:"name"`
+	// +optional
+	// Keys defines the key names of the details in output
+	Keys *OutputKeysSpec `json:"keys,omitempty"`
+}
+
+// OutputKeysSpec defines the key names of the details of iam role
+type OutputKeysSpec struct {
+	// +optional
+	// RoleArn is the key of role arn, default is "roleArn"
+	RoleArn string `json:"roleArn,omitempty"`
+	// +optional
+	// RoleName is the key of role name, default is "roleName"
+	RoleName string `json:"roleName,omitempty"`
+	// +optional
+	// AccountID is the key of aws account id, default is "accountID"
+	AccountID string `json:"accountID,omitempty"`
+	// +optional
+	// OIDCProvider is the key of oidc provider arn, default is "oidcProvider"
+	OIDCProvider string `json:"oidcProvider,omitempty"`
+}
+
+// ServiceAccountTemplateSpec defines the metadata and settings of service account
+type ServiceAccountTemplateSpec struct {
+	// +optional
+	// Labels will be set into the labels of service account
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	// Annotations will be set into the annotations of service account
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// +optional
+	// ImagePullSecrets is the list of image pull secrets of service account
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// +optional
+	// AutomountServiceAccountToken indicates whether pods running as service account should have an API token automatically mounted
+	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+	// +optional
+	// StsRegionalEndpoints sets `eks.amazonaws.com/sts-regional-endpoints` annotation, pods will use the regional sts endpoint if it is true
+	StsRegionalEndpoints *bool `json:"stsRegionalEndpoints,omitempty"`
+	// +optional
+	// +kubebuilder:validation:Minimum=600
+	// +kubebuilder:validation:Maximum=86400
+	// TokenExpiration sets `eks.amazonaws.com/token-expiration` annotation, the expiration seconds of projected service account token
+	TokenExpiration *int64 `json:"tokenExpiration,omitempty"`
+	// +optional
+	// Audience sets `eks.amazonaws.com/audience` annotation, the audience of projected service account token
+	Audience string `json:"audience,omitempty"`
+}
+
+// ServiceAccountSpec defines how irsa-controller manages the service accounts
+type ServiceAccountSpec struct {
+	// +optional
+	// Create defines whether to create the service accounts, default is true.
+	// If it is false, irsa-controller only manages the iam role trusted by the service accounts, which may be in other clusters
+	Create *bool `json:"create,omitempty"`
+	// +optional
+	// Adopt allows irsa-controller to adopt the existing service accounts which are not created by irsa-controller.
+	// Only the role annotation of adopted service accounts is managed, and they will not be deleted with irsa
+	Adopt bool `json:"adopt,omitempty"`
+}
+
+// ServiceAccountRef defines the service account bound to the iam role
+type ServiceAccountRef struct {
+	// Name is the name of service account
+	Name string `json:"name"`
+	// +optional
+	// Namespace is the namespace of service account, default is the namespace of irsa.
+	// A namespace other than irsa's must trust irsa's namespace in its `irsa.domc.me/trusted-namespaces` annotation
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type PolicySpec struct {
 	// +optional
-	// ManagedPolicies will make the iam role be attached with a list of managed policies
+	// ManagedPolicies will make the iam role be attached with a list of managed policies,
+	// the item can be the arn of policy or the name of aws managed policy, e.g. AmazonS3ReadOnlyAccess
 	ManagedPolicies []string `json:"managedPolicies"`
 	// +optional
+	// PolicyRefs is a list of IamPolicy in the namespace of irsa, the iam role will be attached with their customer managed policies
+	PolicyRefs []IamPolicyRef `json:"policyRefs,omitempty"`
+	// +optional
 	// InlinePolicy defines the details of inline policy of iam role in aws account
 	InlinePolicy *InlinePolicySpec `json:"inlinePolicy"`
+	// +optional
+	// InlinePolicyDocument is the raw json document of inline policy, it cannot be used with InlinePolicy or InlinePolicyFrom
+	InlinePolicyDocument string `json:"inlinePolicyDocument,omitempty"`
+	// +optional
+	// InlinePolicyFrom defines the source of the raw json document of inline policy, it cannot be used with InlinePolicy or InlinePolicyDocument
+	InlinePolicyFrom *InlinePolicySource `json:"inlinePolicyFrom,omitempty"`
+	// +optional
+	// InlinePolicies is a list of named inline policies of iam role in aws account
+	InlinePolicies []NamedInlinePolicySpec `json:"inlinePolicies,omitempty"`
+	// +optional
+	// Templates is a list of ClusterPolicyTemplate, their rendered statements are merged into the default inline policy,
+	// it cannot be used with InlinePolicyDocument or InlinePolicyFrom
+	Templates []PolicyTemplateRef `json:"templates,omitempty"`
+	// +optional
+	// OversizeStrategy defines how to handle the inline policies exceeding the size limit of iam role, default is Fail
+	OversizeStrategy OversizeStrategy `json:"oversizeStrategy,omitempty"`
+	// +optional
+	// Mode defines how the statements of inlinePolicy, inlinePolicyDocument or inlinePolicyFrom are created in aws, default is Inline
+	Mode PolicyMode `json:"mode,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=s3;sqs;sns;secretsmanager;dynamodb
+type AccessService string
+
+var (
+	AccessS3             AccessService = "s3"
+	AccessSQS            AccessService = "sqs"
+	AccessSNS            AccessService = "sns"
+	AccessSecretsManager AccessService = "secretsmanager"
+	AccessDynamoDB       AccessService = "dynamodb"
+)
+
+// +kubebuilder:validation:Enum=read;write;readwrite;consume;produce;publish
+type AccessMode string
+
+var (
+	// AccessRead is supported by s3, secretsmanager and dynamodb
+	AccessRead AccessMode = "read"
+	// AccessWrite is supported by s3 and dynamodb
+	AccessWrite AccessMode = "write"
+	// AccessReadWrite is supported by s3 and dynamodb
+	AccessReadWrite AccessMode = "readwrite"
+	// AccessConsume is supported by sqs
+	AccessConsume AccessMode = "consume"
+	// AccessProduce is supported by sqs
+	AccessProduce AccessMode = "produce"
+	// AccessPublish is supported by sns
+	AccessPublish AccessMode = "publish"
+)
+
+// AccessSpec defines an access intent to the resource of aws service
+type AccessSpec struct {
+	// Service is the aws service of resource
+	Service AccessService `json:"service"`
+	// Mode is the access mode of resource
+	Mode AccessMode `json:"mode"`
+	// +optional
+	// Bucket is the name of s3 bucket
+	Bucket string `json:"bucket,omitempty"`
+	// +optional
+	// Prefix limits the access to the objects with the prefix in s3 bucket
+	Prefix string `json:"prefix,omitempty"`
+	// +optional
+	// Queue is the name or arn of sqs queue
+	Queue string `json:"queue,omitempty"`
+	// +optional
+	// Topic is the name or arn of sns topic
+	Topic string `json:"topic,omitempty"`
+	// +optional
+	// Secret is the name or arn of secretsmanager secret
+	Secret string `json:"secret,omitempty"`
+	// +optional
+	// Table is the name or arn of dynamodb table
+	Table string `json:"table,omitempty"`
+	// +optional
+	// Region is the region of the resources given by name, defaults to the region of the oidc provider of EKS,
+	// it is required if the resources are given by name in other clusters
+	Region string `json:"region,omitempty"`
+	// +optional
+	// AllowWildcards allows * and ? in the names and arns of resources, which grants the access to all matched resources.
+	// Wildcards are rejected by default
+	AllowWildcards bool `json:"allowWildcards,omitempty"`
+}
+
+// TrustSpec defines the conditions of web identity in the trust relationship of iam role
+type TrustSpec struct {
+	// +optional
+	// Audience is the value of the aud condition, default is "sts.amazonaws.com"
+	Audience string `json:"audience,omitempty"`
+	// +optional
+	// SubjectPatterns is a list of subjects matched by StringLike, e.g. system:serviceaccount:team-a:*.
+	// Wildcards are only allowed in the name of service account and must be allowed by allowWildcardSubjects of controller,
+	// the namespace must be the namespace of irsa or trust it in its `irsa.domc.me/trusted-namespaces` annotation
+	SubjectPatterns []string `json:"subjectPatterns,omitempty"`
+	// +optional
+	// AdditionalPrincipals is a list of principals which can assume the iam role besides the service accounts
+	AdditionalPrincipals []TrustPrincipal `json:"additionalPrincipals,omitempty"`
+	// +optional
+	// OIDCProviders is a list of oidc providers of other clusters, the service accounts with the same subjects
+	// in these clusters can also assume the iam role, e.g. during blue/green cluster migrations
+	OIDCProviders []OIDCProvider `json:"oidcProviders,omitempty"`
+}
+
+// OIDCProvider defines the iam oidc provider of a cluster
+type OIDCProvider struct {
+	// Arn is the arn of iam oidc provider, e.g. arn:aws:iam::000000000000:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE
+	Arn string `json:"arn"`
+	// Cluster is the name of cluster which the oidc provider belongs to, it is recorded in the tags of iam role
+	Cluster string `json:"cluster"`
+}
+
+// TrustPrincipal defines a principal which can assume the iam role, exactly one of AWS, Service and Federated must be set
+type TrustPrincipal struct {
+	// +optional
+	// AWS is the arn of aws account, iam role or iam user, an account id is converted to the arn of account root
+	AWS string `json:"aws,omitempty"`
+	// +optional
+	// Service is the principal of aws service, e.g. ec2.amazonaws.com
+	Service string `json:"service,omitempty"`
+	// +optional
+	// Federated is the arn of another oidc or saml provider
+	Federated string `json:"federated,omitempty"`
+	// +optional
+	// Action is the action of trust statement, default is sts:AssumeRoleWithWebIdentity for Federated and sts:AssumeRole for others
+	Action string `json:"action,omitempty"`
+	// +optional
+	// Condition is the condition of trust statement
+	Condition StatementConditionSpec `json:"condition,omitempty"`
+}
+
+// IamPolicyRef references an IamPolicy in the namespace of irsa
+type IamPolicyRef struct {
+	// Name is the name of IamPolicy
+	Name string `json:"name"`
+}
+
+// +kubebuilder:validation:Enum=Inline;Managed
+type PolicyMode string
+
+var (
+	// PolicyModeInline creates the statements as an inline policy of iam role
+	PolicyModeInline PolicyMode = "Inline"
+	// PolicyModeManaged creates the statements as customer managed policies owned by irsa-controller and attached to iam role
+	PolicyModeManaged PolicyMode = "Managed"
+)
+
+// +kubebuilder:validation:Enum=Fail;SplitManagedPolicies
+type OversizeStrategy string
+
+var (
+	// OversizeFail fails the reconciliation with the size and limit of inline policies
+	OversizeFail OversizeStrategy = "Fail"
+	// OversizeSplitManagedPolicies splits the statements of inline policy across several customer managed policies owned by irsa-controller
+	OversizeSplitManagedPolicies OversizeStrategy = "SplitManagedPolicies"
+)
+
+// NamedInlinePolicySpec defines a named inline policy created within iam role
+type NamedInlinePolicySpec struct {
+	// +kubebuilder:validation:Pattern=`^[\w+=,.@-]{1,128}$`
+	// Name is the name of inline policy in iam role
+	Name             string `json:"name"`
+	InlinePolicySpec `json:",inline"`
+}
+
+// InlinePolicySource defines the source of inline policy document
+type InlinePolicySource struct {
+	// ConfigMapKeyRef selects a key of ConfigMap in the namespace of irsa
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef"`
+}
+
+// ResourceSource defines the source of resource arns, only one of ConfigMapKeyRef and SecretKeyRef can be set
+type ResourceSource struct {
+	// +optional
+	// ConfigMapKeyRef selects a key of ConfigMap in the namespace of irsa
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// +optional
+	// SecretKeyRef selects a key of Secret in the namespace of irsa
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
 }
 
 // InlinePolicySpec defines the policy create within iam role
@@ -67,8 +362,14 @@ type StatementConditionSpec map[string]map[string]string
 
 // StatementSpec defines the policy statement
 type StatementSpec struct {
+	// +optional
+	// Resource is a list of resource arns, it can be omitted if ResourceFrom is set
 	Resource []string `json:"resource"`
-	Action   []string `json:"action"`
+	// +optional
+	// ResourceFrom reads resource arns from ConfigMaps or Secrets in the namespace of irsa at reconcile time,
+	// several arns in one key can be separated by commas or whitespaces
+	ResourceFrom []ResourceSource `json:"resourceFrom,omitempty"`
+	Action       []string         `json:"action"`
 	// +kubebuilder:validation:Enum=Allow;Deny
 	Effect string `json:"effect"`
 	// +optional
@@ -88,6 +389,26 @@ type IamRoleServiceAccountStatus struct {
 	// +optional
 	// Reason is a brief string that describes any failure.
 	Reason string `json:"reason,omitempty"`
+	// +optional
+	// ServiceAccounts is a list of service accounts ( namespace/name ) which have been bound to the iam role
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+	// +optional
+	// TrustedServiceAccounts is a list of service accounts ( namespace/name ) added to the trust policy of external iam role by irsa
+	TrustedServiceAccounts []string `json:"trustedServiceAccounts,omitempty"`
+	// +optional
+	// ManagedPolicyErrors is a list of managed policies which cannot be attached to the iam role
+	ManagedPolicyErrors []ManagedPolicyError `json:"managedPolicyErrors,omitempty"`
+	// +optional
+	// AccessStatements are the statements compiled from the access intents of irsa
+	AccessStatements []StatementSpec `json:"accessStatements,omitempty"`
+}
+
+// ManagedPolicyError describes why the managed policy cannot be attached to the iam role
+type ManagedPolicyError struct {
+	// PolicyArn is the arn of managed policy
+	PolicyArn string `json:"policyArn"`
+	// Error is the error returned by aws
+	Error string `json:"error"`
 }
 
 // +kubebuilder:validation:Enum=Pending;Conflict;Forbidden;Failed;Progressing;Synced
@@ -140,3 +461,32 @@ func (i *IamRoleServiceAccount) AwsIamRoleName(prefix, cluster string) string {
 	}
 	return fmt.Sprintf("%s-%s-%s", prefixClusterName, i.GetNamespace(), i.GetName())
 }
+
+// ServiceAccounts returns the service accounts bound to the iam role, duplicated items are removed
+func (i *IamRoleServiceAccount) ServiceAccounts() []types.NamespacedName {
+	if len(i.Spec.ServiceAccounts) == 0 {
+		return []types.NamespacedName{{Namespace: i.GetNamespace(), Name: i.GetName()}}
+	}
+	var res []types.NamespacedName
+	seen := make(map[types.NamespacedName]bool, len(i.Spec.ServiceAccounts))
+	for _, ref := range i.Spec.ServiceAccounts {
+		key := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+		if key.Namespace == "" {
+			key.Namespace = i.GetNamespace()
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		res = append(res, key)
+	}
+	return res
+}
+
+// CreateServiceAccounts returns true if the service accounts bound to the iam role should be created
+func (i *IamRoleServiceAccount) CreateServiceAccounts() bool {
+	if i.Spec.ServiceAccount == nil || i.Spec.ServiceAccount.Create == nil {
+		return true
+	}
+	return *i.Spec.ServiceAccount.Create
+}
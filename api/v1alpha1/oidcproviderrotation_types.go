@@ -0,0 +1,101 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OIDCProviderRotationSpec defines the desired state of OIDCProviderRotation
+type OIDCProviderRotationSpec struct {
+	// From is the arn of the oidc provider being replaced
+	From string `json:"from"`
+	// To is the arn of the new oidc provider, it is trusted alongside From by all of the iam roles until the rotation is confirmed
+	To string `json:"to"`
+	// +optional
+	// Confirmed removes From from all of the iam roles, the oidcProviderArn of controller must be updated to To before confirmation
+	Confirmed bool `json:"confirmed,omitempty"`
+}
+
+// OIDCProviderRotationStatus defines the observed state of OIDCProviderRotation
+type OIDCProviderRotationStatus struct {
+	// +optional
+	// Phase is the stage of rotation
+	Phase OIDCProviderRotationPhase `json:"phase,omitempty"`
+	// +optional
+	// Reason is a brief string that describes any failure.
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	// Roles is the count of iam roles bound to irsa
+	Roles int `json:"roles,omitempty"`
+	// +optional
+	// UpdatedRoles is the count of iam roles whose trust relationship has been updated in the current phase
+	UpdatedRoles int `json:"updatedRoles,omitempty"`
+	// +optional
+	// PendingRoles is a list of iam roles whose trust relationship has not been updated in the current phase
+	PendingRoles []string `json:"pendingRoles,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Adding;AwaitingConfirmation;Removing;Completed;Failed
+type OIDCProviderRotationPhase string
+
+var (
+	// OIDCProviderRotationAdding means the new oidc provider is being added to the iam roles
+	OIDCProviderRotationAdding OIDCProviderRotationPhase = "Adding"
+	// OIDCProviderRotationAwaitingConfirmation means all of the iam roles trust both oidc providers
+	OIDCProviderRotationAwaitingConfirmation OIDCProviderRotationPhase = "AwaitingConfirmation"
+	// OIDCProviderRotationRemoving means the old oidc provider is being removed from the iam roles
+	OIDCProviderRotationRemoving OIDCProviderRotationPhase = "Removing"
+	// OIDCProviderRotationCompleted means none of the iam roles trusts the old oidc provider
+	OIDCProviderRotationCompleted OIDCProviderRotationPhase = "Completed"
+	OIDCProviderRotationFailed    OIDCProviderRotationPhase = "Failed"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+
+// OIDCProviderRotation is the Schema for the oidcproviderrotations API
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Updated",type=integer,JSONPath=`.status.updatedRoles`
+// +kubebuilder:printcolumn:name="Roles",type=integer,JSONPath=`.status.roles`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type OIDCProviderRotation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OIDCProviderRotationSpec   `json:"spec,omitempty"`
+	Status OIDCProviderRotationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// OIDCProviderRotationList contains a list of OIDCProviderRotation
+type OIDCProviderRotationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OIDCProviderRotation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OIDCProviderRotation{}, &OIDCProviderRotationList{})
+}
+
+// Removing returns true if the old oidc provider should be removed from the iam roles
+func (o *OIDCProviderRotation) Removing() bool {
+	return o.Status.Phase == OIDCProviderRotationRemoving || o.Status.Phase == OIDCProviderRotationCompleted
+}
@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	"fmt"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
@@ -36,6 +37,55 @@ type ProjectConfigSpec struct {
 	Cluster         string         `json:"cluster,omitempty"`
 	AdditionalTags  []string       `json:"additionalTags,omitempty"`
 	AWSConfig       *AWSConfigSpec `json:"awsConfig,omitempty"`
+	// DiscoverOIDCProvider finds the iam oidc provider of the service account issuer of cluster if OIDCProviderArn is not set
+	DiscoverOIDCProvider bool `json:"discoverOIDCProvider,omitempty"`
+	// AllowWildcardSubjects allows irsa to trust service accounts by wildcard subject patterns
+	AllowWildcardSubjects bool `json:"allowWildcardSubjects,omitempty"`
+	// AllowedTrustPrincipals is a list of principals ( aws arns or account ids, services and federated providers ) irsa can trust
+	// by additional principals, additional principals are forbidden if it is empty
+	AllowedTrustPrincipals []string `json:"allowedTrustPrincipals,omitempty"`
+	// TrustPolicySizeLimit is the max size of the trust policy of iam role, defaults to 2048,
+	// set it up to 4096 if the quota of the aws account is raised
+	TrustPolicySizeLimit int `json:"trustPolicySizeLimit,omitempty"`
+	// AdditionalOIDCProviders is a list of oidc providers of other clusters trusted by all of the iam roles
+	AdditionalOIDCProviders []OIDCProvider `json:"additionalOIDCProviders,omitempty"`
+	// OIDCProvider configures the iam oidc provider managed by irsa-controller
+	OIDCProvider *OIDCProviderConfigSpec `json:"oidcProvider,omitempty"`
+	// OIDCDiscovery configures the publishing of the service account issuer discovery documents
+	OIDCDiscovery *OIDCDiscoveryConfigSpec `json:"oidcDiscovery,omitempty"`
+}
+
+type OIDCProviderConfigSpec struct {
+	// Manage creates and keeps the iam oidc provider of IssuerURL up to date, used by self-managed clusters
+	Manage bool `json:"manage,omitempty"`
+	// IssuerURL is the service account issuer of cluster, must be served over https
+	IssuerURL string `json:"issuerURL,omitempty"`
+	// ClientIDs is the audiences allowed by the iam oidc provider, defaults to sts.amazonaws.com
+	ClientIDs []string `json:"clientIDs,omitempty"`
+}
+
+type OIDCDiscoveryConfigSpec struct {
+	// Publish uploads the discovery document and json web key set of the service account issuer to Bucket,
+	// and republishes them when the signing keys rotate
+	Publish bool `json:"publish,omitempty"`
+	// Bucket is the name of the s3 bucket serving the service account issuer
+	Bucket string `json:"bucket,omitempty"`
+	// KeyPrefix is the prefix of the object keys, equals to the path of the service account issuer in Bucket
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+	// Region is the region of Bucket, defaults to the region of AWSConfig
+	Region string `json:"region,omitempty"`
+	// Endpoint is the url of s3 compatible storage, path style addressing is used if it is set
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// PublishOIDCDiscovery returns true if the discovery documents of service account issuer are published by irsa-controller
+func (p *ProjectConfig) PublishOIDCDiscovery() bool {
+	return p.OIDCDiscovery != nil && p.OIDCDiscovery.Publish
+}
+
+// ManageOIDCProvider returns true if the iam oidc provider is managed by irsa-controller
+func (p *ProjectConfig) ManageOIDCProvider() bool {
+	return p.OIDCProvider != nil && p.OIDCProvider.Manage
 }
 
 type AWSConfigSpec struct {
@@ -50,12 +100,45 @@ type AWSConfigSpec struct {
 type ProjectConfigStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// OIDCProviderArn is the oidc provider used by controller, which may be discovered or managed by controller
+	OIDCProviderArn string `json:"oidcProviderArn,omitempty"`
+	// AccountID is the aws account of the credentials used by controller
+	AccountID string `json:"accountID,omitempty"`
+	// Ready is true if controller passes the readiness check against aws
+	Ready bool `json:"ready"`
+	// Reason is the reason of the failed readiness check
+	Reason string `json:"reason,omitempty"`
+	// IrsaConditions is the count of IamRoleServiceAccounts by condition
+	IrsaConditions map[string]int `json:"irsaConditions,omitempty"`
+	// LastAWSSuccessTime is the time of the last successful aws api call, it is only refreshed when the other fields of status change
+	LastAWSSuccessTime *metav1.Time `json:"lastAWSSuccessTime,omitempty"`
+	// Throttling is the rate limiting state of aws api calls
+	Throttling AWSThrottlingStatus `json:"throttling,omitempty"`
+	// LastUpdateTime is the time the status is last changed by controller
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+type AWSThrottlingStatus struct {
+	// Throttled is true if aws api calls are throttled recently, the failed calls are retried with backoff
+	Throttled bool `json:"throttled"`
+	// ThrottledRequests is the count of throttled aws api calls since controller started
+	ThrottledRequests int64 `json:"throttledRequests,omitempty"`
+	// RetriedRequests is the count of retried aws api calls since controller started
+	RetriedRequests int64 `json:"retriedRequests,omitempty"`
+	// LastThrottledTime is the time of the last throttled aws api call
+	LastThrottledTime *metav1.Time `json:"lastThrottledTime,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 
-// ProjectConfig is the Schema for the projectconfigs API
+// ProjectConfig is the Schema for the projectconfigs API, controller reports its health to the status of
+// the ProjectConfig mirroring its configuration
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Account",type=string,JSONPath=`.status.accountID`
+// +kubebuilder:printcolumn:name="OIDCProvider",type=string,JSONPath=`.status.oidcProviderArn`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type ProjectConfig struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -81,14 +164,32 @@ func init() {
 }
 
 func (p *ProjectConfig) Validate() error {
-	if p.OIDCProviderArn == "" {
-		return fmt.Errorf("OIDCProviderArn is required.")
+	if p.OIDCProviderArn == "" && !p.DiscoverOIDCProvider && !p.ManageOIDCProvider() {
+		return fmt.Errorf("OIDCProviderArn is required when DiscoverOIDCProvider and OIDCProvider.Manage are disabled.")
+	}
+
+	if p.ManageOIDCProvider() && !strings.HasPrefix(p.OIDCProvider.IssuerURL, "https://") {
+		return fmt.Errorf("IssuerURL must be a https url when OIDCProvider.Manage is enabled.")
 	}
 
 	if p.Cluster == "" {
 		return fmt.Errorf("Cluster is required.")
 	}
 
+	if p.PublishOIDCDiscovery() && p.OIDCDiscovery.Bucket == "" {
+		return fmt.Errorf("Bucket is required when OIDCDiscovery.Publish is enabled.")
+	}
+
+	if p.TrustPolicySizeLimit < 0 || p.TrustPolicySizeLimit > 4096 {
+		return fmt.Errorf("TrustPolicySizeLimit must be between 0 and 4096.")
+	}
+
+	for _, provider := range p.AdditionalOIDCProviders {
+		if provider.Arn == "" || provider.Cluster == "" {
+			return fmt.Errorf("Arn and Cluster are required in additional oidc providers.")
+		}
+	}
+
 	if p.AWSConfig != nil {
 		if p.AWSConfig.AccessKeyID == "" || p.AWSConfig.SecretAccessKey == "" {
 			return fmt.Errorf("AccessKeyID and SecretAccessKey is required when aws config is setten.")
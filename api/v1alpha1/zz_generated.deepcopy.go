@@ -22,6 +22,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -40,27 +41,536 @@ func (in *AWSConfigSpec) DeepCopy() *AWSConfigSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSThrottlingStatus) DeepCopyInto(out *AWSThrottlingStatus) {
+	*out = *in
+	if in.LastThrottledTime != nil {
+		in, out := &in.LastThrottledTime, &out.LastThrottledTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSThrottlingStatus.
+func (in *AWSThrottlingStatus) DeepCopy() *AWSThrottlingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSThrottlingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessSpec) DeepCopyInto(out *AccessSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessSpec.
+func (in *AccessSpec) DeepCopy() *AccessSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPolicyTemplate) DeepCopyInto(out *ClusterPolicyTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPolicyTemplate.
+func (in *ClusterPolicyTemplate) DeepCopy() *ClusterPolicyTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPolicyTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterPolicyTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPolicyTemplateList) DeepCopyInto(out *ClusterPolicyTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterPolicyTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPolicyTemplateList.
+func (in *ClusterPolicyTemplateList) DeepCopy() *ClusterPolicyTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPolicyTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterPolicyTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPolicyTemplateSpec) DeepCopyInto(out *ClusterPolicyTemplateSpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]TemplateParameter, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Statement != nil {
+		in, out := &in.Statement, &out.Statement
+		*out = make([]StatementSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPolicyTemplateSpec.
+func (in *ClusterPolicyTemplateSpec) DeepCopy() *ClusterPolicyTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPolicyTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IamPolicy) DeepCopyInto(out *IamPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamPolicy.
+func (in *IamPolicy) DeepCopy() *IamPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(IamPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IamPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IamPolicyList) DeepCopyInto(out *IamPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]IamPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamPolicyList.
+func (in *IamPolicyList) DeepCopy() *IamPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(IamPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IamPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IamPolicyRef) DeepCopyInto(out *IamPolicyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamPolicyRef.
+func (in *IamPolicyRef) DeepCopy() *IamPolicyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(IamPolicyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IamPolicySpec) DeepCopyInto(out *IamPolicySpec) {
+	*out = *in
+	in.Policy.DeepCopyInto(&out.Policy)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamPolicySpec.
+func (in *IamPolicySpec) DeepCopy() *IamPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IamPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IamPolicyStatus) DeepCopyInto(out *IamPolicyStatus) {
+	*out = *in
+	if in.AttachedRoles != nil {
+		in, out := &in.AttachedRoles, &out.AttachedRoles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamPolicyStatus.
+func (in *IamPolicyStatus) DeepCopy() *IamPolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IamPolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IamRoleServiceAccount) DeepCopyInto(out *IamRoleServiceAccount) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamRoleServiceAccount.
+func (in *IamRoleServiceAccount) DeepCopy() *IamRoleServiceAccount {
+	if in == nil {
+		return nil
+	}
+	out := new(IamRoleServiceAccount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IamRoleServiceAccount) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IamRoleServiceAccountList) DeepCopyInto(out *IamRoleServiceAccountList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]IamRoleServiceAccount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamRoleServiceAccountList.
+func (in *IamRoleServiceAccountList) DeepCopy() *IamRoleServiceAccountList {
+	if in == nil {
+		return nil
+	}
+	out := new(IamRoleServiceAccountList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IamRoleServiceAccountList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IamRoleServiceAccountSpec) DeepCopyInto(out *IamRoleServiceAccountSpec) {
+	*out = *in
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(PolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Access != nil {
+		in, out := &in.Access, &out.Access
+		*out = make([]AccessSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.Trust != nil {
+		in, out := &in.Trust, &out.Trust
+		*out = new(TrustSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]ServiceAccountRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceAccount != nil {
+		in, out := &in.ServiceAccount, &out.ServiceAccount
+		*out = new(ServiceAccountSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ServiceAccountTemplate != nil {
+		in, out := &in.ServiceAccountTemplate, &out.ServiceAccountTemplate
+		*out = new(ServiceAccountTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]OutputSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamRoleServiceAccountSpec.
+func (in *IamRoleServiceAccountSpec) DeepCopy() *IamRoleServiceAccountSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IamRoleServiceAccountSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IamRoleServiceAccountStatus) DeepCopyInto(out *IamRoleServiceAccountStatus) {
+	*out = *in
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TrustedServiceAccounts != nil {
+		in, out := &in.TrustedServiceAccounts, &out.TrustedServiceAccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ManagedPolicyErrors != nil {
+		in, out := &in.ManagedPolicyErrors, &out.ManagedPolicyErrors
+		*out = make([]ManagedPolicyError, len(*in))
+		copy(*out, *in)
+	}
+	if in.AccessStatements != nil {
+		in, out := &in.AccessStatements, &out.AccessStatements
+		*out = make([]StatementSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamRoleServiceAccountStatus.
+func (in *IamRoleServiceAccountStatus) DeepCopy() *IamRoleServiceAccountStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IamRoleServiceAccountStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InlinePolicySource) DeepCopyInto(out *InlinePolicySource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InlinePolicySource.
+func (in *InlinePolicySource) DeepCopy() *InlinePolicySource {
+	if in == nil {
+		return nil
+	}
+	out := new(InlinePolicySource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InlinePolicySpec) DeepCopyInto(out *InlinePolicySpec) {
+	*out = *in
+	if in.Statement != nil {
+		in, out := &in.Statement, &out.Statement
+		*out = make([]StatementSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InlinePolicySpec.
+func (in *InlinePolicySpec) DeepCopy() *InlinePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(InlinePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedPolicyError) DeepCopyInto(out *ManagedPolicyError) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedPolicyError.
+func (in *ManagedPolicyError) DeepCopy() *ManagedPolicyError {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedPolicyError)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamedInlinePolicySpec) DeepCopyInto(out *NamedInlinePolicySpec) {
+	*out = *in
+	in.InlinePolicySpec.DeepCopyInto(&out.InlinePolicySpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamedInlinePolicySpec.
+func (in *NamedInlinePolicySpec) DeepCopy() *NamedInlinePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NamedInlinePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCDiscoveryConfigSpec) DeepCopyInto(out *OIDCDiscoveryConfigSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCDiscoveryConfigSpec.
+func (in *OIDCDiscoveryConfigSpec) DeepCopy() *OIDCDiscoveryConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCDiscoveryConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProvider) DeepCopyInto(out *OIDCProvider) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProvider.
+func (in *OIDCProvider) DeepCopy() *OIDCProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProviderConfigSpec) DeepCopyInto(out *OIDCProviderConfigSpec) {
+	*out = *in
+	if in.ClientIDs != nil {
+		in, out := &in.ClientIDs, &out.ClientIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderConfigSpec.
+func (in *OIDCProviderConfigSpec) DeepCopy() *OIDCProviderConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCProviderConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProviderRotation) DeepCopyInto(out *OIDCProviderRotation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamRoleServiceAccount.
-func (in *IamRoleServiceAccount) DeepCopy() *IamRoleServiceAccount {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderRotation.
+func (in *OIDCProviderRotation) DeepCopy() *OIDCProviderRotation {
 	if in == nil {
 		return nil
 	}
-	out := new(IamRoleServiceAccount)
+	out := new(OIDCProviderRotation)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *IamRoleServiceAccount) DeepCopyObject() runtime.Object {
+func (in *OIDCProviderRotation) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -68,31 +578,31 @@ func (in *IamRoleServiceAccount) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IamRoleServiceAccountList) DeepCopyInto(out *IamRoleServiceAccountList) {
+func (in *OIDCProviderRotationList) DeepCopyInto(out *OIDCProviderRotationList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]IamRoleServiceAccount, len(*in))
+		*out = make([]OIDCProviderRotation, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamRoleServiceAccountList.
-func (in *IamRoleServiceAccountList) DeepCopy() *IamRoleServiceAccountList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderRotationList.
+func (in *OIDCProviderRotationList) DeepCopy() *OIDCProviderRotationList {
 	if in == nil {
 		return nil
 	}
-	out := new(IamRoleServiceAccountList)
+	out := new(OIDCProviderRotationList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *IamRoleServiceAccountList) DeepCopyObject() runtime.Object {
+func (in *OIDCProviderRotationList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -100,65 +610,71 @@ func (in *IamRoleServiceAccountList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IamRoleServiceAccountSpec) DeepCopyInto(out *IamRoleServiceAccountSpec) {
+func (in *OIDCProviderRotationSpec) DeepCopyInto(out *OIDCProviderRotationSpec) {
 	*out = *in
-	if in.Policy != nil {
-		in, out := &in.Policy, &out.Policy
-		*out = new(PolicySpec)
-		(*in).DeepCopyInto(*out)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderRotationSpec.
+func (in *OIDCProviderRotationSpec) DeepCopy() *OIDCProviderRotationSpec {
+	if in == nil {
+		return nil
 	}
-	if in.Tags != nil {
-		in, out := &in.Tags, &out.Tags
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	out := new(OIDCProviderRotationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProviderRotationStatus) DeepCopyInto(out *OIDCProviderRotationStatus) {
+	*out = *in
+	if in.PendingRoles != nil {
+		in, out := &in.PendingRoles, &out.PendingRoles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamRoleServiceAccountSpec.
-func (in *IamRoleServiceAccountSpec) DeepCopy() *IamRoleServiceAccountSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderRotationStatus.
+func (in *OIDCProviderRotationStatus) DeepCopy() *OIDCProviderRotationStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(IamRoleServiceAccountSpec)
+	out := new(OIDCProviderRotationStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IamRoleServiceAccountStatus) DeepCopyInto(out *IamRoleServiceAccountStatus) {
+func (in *OutputKeysSpec) DeepCopyInto(out *OutputKeysSpec) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IamRoleServiceAccountStatus.
-func (in *IamRoleServiceAccountStatus) DeepCopy() *IamRoleServiceAccountStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputKeysSpec.
+func (in *OutputKeysSpec) DeepCopy() *OutputKeysSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(IamRoleServiceAccountStatus)
+	out := new(OutputKeysSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *InlinePolicySpec) DeepCopyInto(out *InlinePolicySpec) {
+func (in *OutputSpec) DeepCopyInto(out *OutputSpec) {
 	*out = *in
-	if in.Statement != nil {
-		in, out := &in.Statement, &out.Statement
-		*out = make([]StatementSpec, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = new(OutputKeysSpec)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InlinePolicySpec.
-func (in *InlinePolicySpec) DeepCopy() *InlinePolicySpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputSpec.
+func (in *OutputSpec) DeepCopy() *OutputSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(InlinePolicySpec)
+	out := new(OutputSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -171,11 +687,35 @@ func (in *PolicySpec) DeepCopyInto(out *PolicySpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PolicyRefs != nil {
+		in, out := &in.PolicyRefs, &out.PolicyRefs
+		*out = make([]IamPolicyRef, len(*in))
+		copy(*out, *in)
+	}
 	if in.InlinePolicy != nil {
 		in, out := &in.InlinePolicy, &out.InlinePolicy
 		*out = new(InlinePolicySpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.InlinePolicyFrom != nil {
+		in, out := &in.InlinePolicyFrom, &out.InlinePolicyFrom
+		*out = new(InlinePolicySource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InlinePolicies != nil {
+		in, out := &in.InlinePolicies, &out.InlinePolicies
+		*out = make([]NamedInlinePolicySpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make([]PolicyTemplateRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicySpec.
@@ -188,13 +728,35 @@ func (in *PolicySpec) DeepCopy() *PolicySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyTemplateRef) DeepCopyInto(out *PolicyTemplateRef) {
+	*out = *in
+	if in.Arguments != nil {
+		in, out := &in.Arguments, &out.Arguments
+		*out = make([]TemplateArgument, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyTemplateRef.
+func (in *PolicyTemplateRef) DeepCopy() *PolicyTemplateRef {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyTemplateRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProjectConfig) DeepCopyInto(out *ProjectConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.ProjectConfigSpec.DeepCopyInto(&out.ProjectConfigSpec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	in.ControllerManagerConfigurationSpec.DeepCopyInto(&out.ControllerManagerConfigurationSpec)
 }
 
@@ -261,6 +823,26 @@ func (in *ProjectConfigSpec) DeepCopyInto(out *ProjectConfigSpec) {
 		*out = new(AWSConfigSpec)
 		**out = **in
 	}
+	if in.AllowedTrustPrincipals != nil {
+		in, out := &in.AllowedTrustPrincipals, &out.AllowedTrustPrincipals
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdditionalOIDCProviders != nil {
+		in, out := &in.AdditionalOIDCProviders, &out.AdditionalOIDCProviders
+		*out = make([]OIDCProvider, len(*in))
+		copy(*out, *in)
+	}
+	if in.OIDCProvider != nil {
+		in, out := &in.OIDCProvider, &out.OIDCProvider
+		*out = new(OIDCProviderConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OIDCDiscovery != nil {
+		in, out := &in.OIDCDiscovery, &out.OIDCDiscovery
+		*out = new(OIDCDiscoveryConfigSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProjectConfigSpec.
@@ -276,6 +858,22 @@ func (in *ProjectConfigSpec) DeepCopy() *ProjectConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProjectConfigStatus) DeepCopyInto(out *ProjectConfigStatus) {
 	*out = *in
+	if in.IrsaConditions != nil {
+		in, out := &in.IrsaConditions, &out.IrsaConditions
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LastAWSSuccessTime != nil {
+		in, out := &in.LastAWSSuccessTime, &out.LastAWSSuccessTime
+		*out = (*in).DeepCopy()
+	}
+	in.Throttling.DeepCopyInto(&out.Throttling)
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProjectConfigStatus.
@@ -288,6 +886,115 @@ func (in *ProjectConfigStatus) DeepCopy() *ProjectConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceSource) DeepCopyInto(out *ResourceSource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceSource.
+func (in *ResourceSource) DeepCopy() *ResourceSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountRef) DeepCopyInto(out *ServiceAccountRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountRef.
+func (in *ServiceAccountRef) DeepCopy() *ServiceAccountRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountSpec) DeepCopyInto(out *ServiceAccountSpec) {
+	*out = *in
+	if in.Create != nil {
+		in, out := &in.Create, &out.Create
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountSpec.
+func (in *ServiceAccountSpec) DeepCopy() *ServiceAccountSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountTemplateSpec) DeepCopyInto(out *ServiceAccountTemplateSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.AutomountServiceAccountToken != nil {
+		in, out := &in.AutomountServiceAccountToken, &out.AutomountServiceAccountToken
+		*out = new(bool)
+		**out = **in
+	}
+	if in.StsRegionalEndpoints != nil {
+		in, out := &in.StsRegionalEndpoints, &out.StsRegionalEndpoints
+		*out = new(bool)
+		**out = **in
+	}
+	if in.TokenExpiration != nil {
+		in, out := &in.TokenExpiration, &out.TokenExpiration
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountTemplateSpec.
+func (in *ServiceAccountTemplateSpec) DeepCopy() *ServiceAccountTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in StatementConditionSpec) DeepCopyInto(out *StatementConditionSpec) {
 	{
@@ -327,6 +1034,13 @@ func (in *StatementSpec) DeepCopyInto(out *StatementSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ResourceFrom != nil {
+		in, out := &in.ResourceFrom, &out.ResourceFrom
+		*out = make([]ResourceSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Action != nil {
 		in, out := &in.Action, &out.Action
 		*out = make([]string, len(*in))
@@ -360,3 +1074,123 @@ func (in *StatementSpec) DeepCopy() *StatementSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateArgument) DeepCopyInto(out *TemplateArgument) {
+	*out = *in
+	in.TemplateValue.DeepCopyInto(&out.TemplateValue)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateArgument.
+func (in *TemplateArgument) DeepCopy() *TemplateArgument {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateArgument)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateParameter) DeepCopyInto(out *TemplateParameter) {
+	*out = *in
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(TemplateValue)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateParameter.
+func (in *TemplateParameter) DeepCopy() *TemplateParameter {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateParameter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateValue) DeepCopyInto(out *TemplateValue) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateValue.
+func (in *TemplateValue) DeepCopy() *TemplateValue {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateValue)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustPrincipal) DeepCopyInto(out *TrustPrincipal) {
+	*out = *in
+	if in.Condition != nil {
+		in, out := &in.Condition, &out.Condition
+		*out = make(StatementConditionSpec, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustPrincipal.
+func (in *TrustPrincipal) DeepCopy() *TrustPrincipal {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustPrincipal)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustSpec) DeepCopyInto(out *TrustSpec) {
+	*out = *in
+	if in.SubjectPatterns != nil {
+		in, out := &in.SubjectPatterns, &out.SubjectPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdditionalPrincipals != nil {
+		in, out := &in.AdditionalPrincipals, &out.AdditionalPrincipals
+		*out = make([]TrustPrincipal, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OIDCProviders != nil {
+		in, out := &in.OIDCProviders, &out.OIDCProviders
+		*out = make([]OIDCProvider, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustSpec.
+func (in *TrustSpec) DeepCopy() *TrustSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustSpec)
+	in.DeepCopyInto(out)
+	return out
+}
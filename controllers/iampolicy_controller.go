@@ -0,0 +1,274 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	gerrors "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"domc.me/irsa-controller/pkg/aws"
+	"domc.me/irsa-controller/pkg/utils/slices"
+)
+
+var (
+	ErrIamPolicyConflict = gerrors.New("Iam policy is already exists and not manged by irsa-controller")
+	ErrIamPolicyInUse    = gerrors.New("Iam policy is still attached to iam roles")
+
+	iamPolicyFinalizerName = "iamPolicy.finalizer.irsa.domc.me"
+)
+
+// IamPolicyReconciler reconciles a IamPolicy object
+type IamPolicyReconciler struct {
+	client.Client
+	scheme *runtime.Scheme
+
+	iamRoleClient *aws.IamClient
+}
+
+func NewIamPolicyReconciler(cli client.Client, scheme *runtime.Scheme, iamRoleClient *aws.IamClient) *IamPolicyReconciler {
+	return &IamPolicyReconciler{
+		Client:        cli,
+		scheme:        scheme,
+		iamRoleClient: iamRoleClient,
+	}
+}
+
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=iampolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=iampolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=iampolicies/finalizers,verbs=update
+
+// Reconcile creates and versions the customer managed policy of IamPolicy,
+// and blocks the deletion of IamPolicy while the policy is attached to iam roles
+func (r *IamPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+	l.Info("Syncing the status of iam policy")
+
+	policy := new(irsav1alpha1.IamPolicy)
+	if err := r.Client.Get(ctx, req.NamespacedName, policy); err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("IamPolicy is deleted, ignore events")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Get iam policy failed")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if !policy.ObjectMeta.DeletionTimestamp.IsZero() {
+		if !slices.ContainsString(policy.ObjectMeta.Finalizers, iamPolicyFinalizerName) {
+			return ctrl.Result{}, nil
+		}
+		if err := r.deleteExternalResources(ctx, policy); err != nil {
+			condition := irsav1alpha1.IamPolicyFailed
+			if gerrors.Is(err, ErrIamPolicyInUse) {
+				condition = irsav1alpha1.IamPolicyInUse
+			}
+			r.updatePolicyStatus(ctx, policy, condition, err)
+			l.Error(err, "Delete aws iam policy failed", "policyArn", policy.Status.PolicyArn)
+			return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+		}
+		policy.ObjectMeta.Finalizers = slices.RemoveString(policy.ObjectMeta.Finalizers, iamPolicyFinalizerName)
+		if err := r.Update(ctx, policy); err != nil {
+			return ctrl.Result{}, err
+		}
+		l.Info("Delete IamPolicy successfully")
+		return ctrl.Result{}, nil
+	}
+
+	if !slices.ContainsString(policy.ObjectMeta.Finalizers, iamPolicyFinalizerName) {
+		policy.ObjectMeta.Finalizers = append(policy.ObjectMeta.Finalizers, iamPolicyFinalizerName)
+		err := r.Update(ctx, policy)
+		return ctrl.Result{Requeue: err != nil}, err
+	}
+
+	if err := r.reconcile(ctx, policy); err != nil {
+		r.updatePolicyStatus(ctx, policy, irsav1alpha1.IamPolicyFailed, err)
+		l.Error(err, "Reconcile iam policy failed")
+		return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+	}
+	r.updatePolicyStatus(ctx, policy, irsav1alpha1.IamPolicySynced, nil)
+	l.Info("The status of iam policy has been synced")
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IamPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&irsav1alpha1.IamPolicy{}).
+		Watches(&source.Kind{Type: &irsav1alpha1.IamRoleServiceAccount{}}, handler.EnqueueRequestsFromMapFunc(r.mapIrsaToIamPolicy)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.mapObjectToIamPolicy)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.mapObjectToIamPolicy)).
+		Complete(r)
+}
+
+// reconcile creates or updates the customer managed policy, and refreshes the attached roles in status
+func (r *IamPolicyReconciler) reconcile(ctx context.Context, policy *irsav1alpha1.IamPolicy) error {
+	spec := policy.Spec.Policy.DeepCopy()
+	if err := resolveStatementResources(ctx, r.Client, policy.GetNamespace(), spec.Statement); err != nil {
+		return err
+	}
+	want := aws.NewRoleDocument(spec)
+	if err := want.Validate(); err != nil {
+		return gerrors.Wrap(err, "Invalid policy")
+	}
+	size, err := want.Size()
+	if err != nil {
+		return gerrors.Wrap(err, "Compute size of policy failed")
+	}
+	if size > aws.ManagedPolicySizeLimit {
+		return &aws.PolicySizeExceededError{Kind: "managed policy", Size: size, Limit: aws.ManagedPolicySizeLimit}
+	}
+
+	policyArn, err := r.policyArn(ctx, policy)
+	if err != nil {
+		return err
+	}
+	got, err := r.iamRoleClient.GetPolicy(ctx, policyArn)
+	switch {
+	case err != nil && aws.ErrIsNotFound(err):
+		if policyArn, err = r.iamRoleClient.CreatePolicy(ctx, r.iamRoleClient.PolicyName(policy), policy.Spec.Description, want); err != nil {
+			return gerrors.Wrap(err, "Create iam policy failed")
+		}
+	case err != nil:
+		return gerrors.Wrap(err, "Get iam policy failed")
+	case !got.IsManagedByIrsaController():
+		return ErrIamPolicyConflict
+	case !reflect.DeepEqual(got.Document, want):
+		if err := r.iamRoleClient.UpdatePolicy(ctx, policyArn, want); err != nil {
+			return gerrors.Wrap(err, "Update iam policy failed")
+		}
+	}
+	policy.Status.PolicyArn = policyArn
+
+	roles, err := r.iamRoleClient.ListPolicyRoles(ctx, policyArn)
+	if err != nil {
+		return gerrors.Wrap(err, "List attached roles of iam policy failed")
+	}
+	policy.Status.AttachedRoles = roles
+	return nil
+}
+
+// deleteExternalResources deletes the customer managed policy if it is not attached to any iam role
+func (r *IamPolicyReconciler) deleteExternalResources(ctx context.Context, policy *irsav1alpha1.IamPolicy) error {
+	policyArn, err := r.policyArn(ctx, policy)
+	if err != nil {
+		return err
+	}
+	got, err := r.iamRoleClient.GetPolicy(ctx, policyArn)
+	if err != nil {
+		if aws.ErrIsNotFound(err) {
+			return nil
+		}
+		return gerrors.Wrap(err, "Get iam policy failed")
+	}
+	// the policy is not created by irsa-controller
+	if !got.IsManagedByIrsaController() {
+		return nil
+	}
+	roles, err := r.iamRoleClient.ListPolicyRoles(ctx, policyArn)
+	if err != nil {
+		return gerrors.Wrap(err, "List attached roles of iam policy failed")
+	}
+	policy.Status.AttachedRoles = roles
+	if len(roles) > 0 {
+		return gerrors.Wrapf(ErrIamPolicyInUse, "Attached roles: %v", roles)
+	}
+	return r.iamRoleClient.DeletePolicy(ctx, policyArn)
+}
+
+// policyArn returns the arn recorded in status once the policy is created,
+// otherwise the arn in the account of aws credentials, which may differ from the account of oidc provider
+func (r *IamPolicyReconciler) policyArn(ctx context.Context, policy *irsav1alpha1.IamPolicy) (string, error) {
+	if policy.Status.PolicyArn != "" {
+		return policy.Status.PolicyArn, nil
+	}
+	policyArn, err := r.iamRoleClient.PolicyArn(ctx, policy)
+	if err != nil {
+		return "", gerrors.Wrap(err, "Get arn of iam policy failed")
+	}
+	return policyArn, nil
+}
+
+// mapIrsaToIamPolicy returns the IamPolicy referenced by irsa, so that the attached roles in status can be refreshed
+func (r *IamPolicyReconciler) mapIrsaToIamPolicy(obj client.Object) []reconcile.Request {
+	irsa, ok := obj.(*irsav1alpha1.IamRoleServiceAccount)
+	if !ok || irsa.Spec.Policy == nil {
+		return nil
+	}
+	var res []reconcile.Request
+	for _, ref := range irsa.Spec.Policy.PolicyRefs {
+		res = append(res, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: irsa.GetNamespace(), Name: ref.Name}})
+	}
+	return res
+}
+
+// mapObjectToIamPolicy returns the IamPolicy whose statements read resource arns from the ConfigMap or Secret
+func (r *IamPolicyReconciler) mapObjectToIamPolicy(obj client.Object) []reconcile.Request {
+	var policies irsav1alpha1.IamPolicyList
+	if err := r.List(context.Background(), &policies, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.Log.Error(err, "List iam policy by referenced object failed")
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, policy := range policies.Items {
+		if statementsReferenceObject(policy.Spec.Policy.Statement, obj) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: policy.GetNamespace(), Name: policy.GetName()}})
+		}
+	}
+	return reqs
+}
+
+// updatePolicyStatus returns true if the status is updated
+func (r *IamPolicyReconciler) updatePolicyStatus(ctx context.Context, policy *irsav1alpha1.IamPolicy, condition irsav1alpha1.IamPolicyCondition, reconcileErr error) bool {
+	l := log.FromContext(ctx)
+	var origin irsav1alpha1.IamPolicy
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(policy), &origin); err != nil {
+		l.Error(err, "Get iam policy failed")
+		return false
+	}
+	policy.Status.Condition = condition
+	policy.Status.Reason = ""
+	if reconcileErr != nil {
+		policy.Status.Reason = reconcileErr.Error()
+	}
+	// ignore the request id in reason, avoid updating status in loop
+	if sameReason(origin.Status.Reason, policy.Status.Reason) {
+		origin.Status.Reason = policy.Status.Reason
+	}
+	if equality.Semantic.DeepEqual(origin.Status, policy.Status) {
+		return false
+	}
+	if err := r.Status().Update(ctx, policy); err != nil {
+		l.Error(err, "Update status failed", "to", condition)
+		return false
+	}
+	return true
+}
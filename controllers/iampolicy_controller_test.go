@@ -0,0 +1,249 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"domc.me/irsa-controller/pkg/aws"
+	"domc.me/irsa-controller/pkg/utils/slices"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func getIamPolicyReconciler(mic *aws.MockedIamClient, objs ...runtime.Object) (*IamPolicyReconciler, *IamRoleServiceAccountReconciler) {
+	irsaReconciler := getReconciler(mic, objs...)
+	r := NewIamPolicyReconciler(irsaReconciler.Client, irsaReconciler.scheme, irsaReconciler.iamRoleClient)
+	return r, irsaReconciler
+}
+
+func TestIamPolicyReconciler_Reconcile(t *testing.T) {
+	policy := &irsav1alpha1.IamPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamPolicySpec{
+			Policy: irsav1alpha1.InlinePolicySpec{
+				Version: "2012-10-17",
+				Statement: []irsav1alpha1.StatementSpec{
+					{
+						Resource: []string{"*"},
+						Action:   []string{"s3:GetObject"},
+						Effect:   "Allow",
+					},
+				},
+			},
+		},
+	}
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Policy: &irsav1alpha1.PolicySpec{
+				PolicyRefs: []irsav1alpha1.IamPolicyRef{{Name: policy.GetName()}},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r, irsaReconciler := getIamPolicyReconciler(mic, policy, irsa)
+	key := types.NamespacedName{Namespace: policy.GetNamespace(), Name: policy.GetName()}
+	reconcile := func() *irsav1alpha1.IamPolicy {
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile iam policy failed: %v", err)
+		}
+		var got irsav1alpha1.IamPolicy
+		if err := r.Get(context.Background(), key, &got); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			t.Fatalf("Get iam policy failed: %v", err)
+		}
+		return &got
+	}
+
+	// 1. irsa cannot reference the IamPolicy which is not ready
+	if _, err := irsaReconciler.resolveIrsa(context.Background(), irsa); err == nil {
+		t.Fatalf("1 resolveIrsa should failed if IamPolicy is not ready")
+	}
+
+	// 2. customer managed policy should be created
+	got := reconcile()
+	if !slices.ContainsString(got.Finalizers, iamPolicyFinalizerName) {
+		t.Fatalf("2 finalizer should be added, but got: %v", got.Finalizers)
+	}
+	got = reconcile()
+	if got.Status.Condition != irsav1alpha1.IamPolicySynced || got.Status.PolicyArn != "arn:aws:iam::000000000000:policy/test-test-default-policy-policy" {
+		t.Fatalf("2 iam policy should be created, but got: %v", got.Status)
+	}
+
+	// 3. irsa should be attached with the policy
+	if err := irsaReconciler.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("3 create external resource failed: %v", err)
+	}
+	role, err := irsaReconciler.iamRoleClient.Get(context.Background(), irsaReconciler.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("3 get iam role failed: %v", err)
+	}
+	if !slices.ContainsString(role.ManagedPolicies, got.Status.PolicyArn) {
+		t.Fatalf("3 iam role should be attached with policy, but got: %v", role.ManagedPolicies)
+	}
+	got = reconcile()
+	if len(got.Status.AttachedRoles) != 1 || got.Status.AttachedRoles[0] != role.RoleName {
+		t.Fatalf("3 attached roles should be listed in status, but got: %v", got.Status.AttachedRoles)
+	}
+
+	// 4. policy should be versioned when it is changed
+	got.Spec.Policy.Statement[0].Action = []string{"s3:PutObject"}
+	if err := r.Update(context.Background(), got); err != nil {
+		t.Fatalf("4 update iam policy failed: %v", err)
+	}
+	reconcile()
+	awsPolicy, err := r.iamRoleClient.GetPolicy(context.Background(), got.Status.PolicyArn)
+	if err != nil {
+		t.Fatalf("4 get aws policy failed: %v", err)
+	}
+	if awsPolicy.Document.Statement[0].Action[0] != "s3:PutObject" {
+		t.Fatalf("4 aws policy should be updated, but got: %v", awsPolicy.Document)
+	}
+
+	// 5. deletion should be blocked while the policy is attached
+	if err := r.Delete(context.Background(), got); err != nil {
+		t.Fatalf("5 delete iam policy failed: %v", err)
+	}
+	got = reconcile()
+	if got == nil || got.Status.Condition != irsav1alpha1.IamPolicyInUse {
+		t.Fatalf("5 iam policy should be in use, but got: %v", got)
+	}
+
+	// 6. policy should be deleted after it is detached
+	if err := irsaReconciler.iamRoleClient.Delete(context.Background(), role.RoleArn); err != nil {
+		t.Fatalf("6 delete iam role failed: %v", err)
+	}
+	if got = reconcile(); got != nil {
+		t.Fatalf("6 iam policy should be deleted, but got: %v", got)
+	}
+	if _, err := r.iamRoleClient.GetPolicy(context.Background(), awsPolicy.PolicyArn); !aws.ErrIsNotFound(err) {
+		t.Fatalf("6 aws policy should be deleted, but get: %v", err)
+	}
+}
+
+func TestIamPolicyReconciler_resourceFrom(t *testing.T) {
+	policy := &irsav1alpha1.IamPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "policy",
+			Namespace:  "default",
+			Finalizers: []string{iamPolicyFinalizerName},
+		},
+		Spec: irsav1alpha1.IamPolicySpec{
+			Policy: irsav1alpha1.InlinePolicySpec{
+				Version: "2012-10-17",
+				Statement: []irsav1alpha1.StatementSpec{
+					{
+						ResourceFrom: []irsav1alpha1.ResourceSource{
+							{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "bucket"}, Key: "arn"}},
+						},
+						Action: []string{"s3:GetObject"},
+						Effect: "Allow",
+					},
+				},
+			},
+		},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "default"},
+		Data:       map[string]string{"arn": "arn:aws:s3:::bucket/*"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "queue", Namespace: "default"},
+		Data:       map[string][]byte{"arn": []byte("arn:aws:sqs:us-east-1:000000000000:queue")},
+	}
+	mic := aws.NewMockedIamClient()
+	r, _ := getIamPolicyReconciler(mic, policy, cm, secret)
+	key := types.NamespacedName{Namespace: policy.GetNamespace(), Name: policy.GetName()}
+	reconcile := func() *irsav1alpha1.IamPolicy {
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile iam policy failed: %v", err)
+		}
+		var got irsav1alpha1.IamPolicy
+		if err := r.Get(context.Background(), key, &got); err != nil {
+			t.Fatalf("Get iam policy failed: %v", err)
+		}
+		return &got
+	}
+
+	// 1. resources should be read from config map
+	got := reconcile()
+	if got.Status.Condition != irsav1alpha1.IamPolicySynced {
+		t.Fatalf("1 iam policy should be synced, but got: %v", got.Status)
+	}
+	awsPolicy, err := r.iamRoleClient.GetPolicy(context.Background(), got.Status.PolicyArn)
+	if err != nil {
+		t.Fatalf("1 get aws policy failed: %v", err)
+	}
+	if !reflect.DeepEqual([]string(awsPolicy.Document.Statement[0].Resource), []string{"arn:aws:s3:::bucket/*"}) {
+		t.Fatalf("1 resources should be read from config map, but got: %v", awsPolicy.Document)
+	}
+	if reqs := r.mapObjectToIamPolicy(cm); len(reqs) != 1 || reqs[0].NamespacedName != key {
+		t.Fatalf("1 config map should be mapped to iam policy, but got: %v", reqs)
+	}
+
+	// 2. resources should be read from secret
+	got.Spec.Policy.Statement[0].ResourceFrom = append(got.Spec.Policy.Statement[0].ResourceFrom,
+		irsav1alpha1.ResourceSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "queue"}, Key: "arn"}})
+	if err := r.Update(context.Background(), got); err != nil {
+		t.Fatalf("2 update iam policy failed: %v", err)
+	}
+	got = reconcile()
+	awsPolicy, err = r.iamRoleClient.GetPolicy(context.Background(), got.Status.PolicyArn)
+	if err != nil {
+		t.Fatalf("2 get aws policy failed: %v", err)
+	}
+	if !reflect.DeepEqual([]string(awsPolicy.Document.Statement[0].Resource), []string{"arn:aws:s3:::bucket/*", "arn:aws:sqs:us-east-1:000000000000:queue"}) {
+		t.Fatalf("2 resources should be read from secret, but got: %v", awsPolicy.Document)
+	}
+	if reqs := r.mapObjectToIamPolicy(secret); len(reqs) != 1 || reqs[0].NamespacedName != key {
+		t.Fatalf("2 secret should be mapped to iam policy, but got: %v", reqs)
+	}
+
+	// 3. iam policy should be failed if the key is missing, and the aws policy should be kept
+	got.Spec.Policy.Statement[0].ResourceFrom[1].SecretKeyRef.Key = "missing"
+	if err := r.Update(context.Background(), got); err != nil {
+		t.Fatalf("3 update iam policy failed: %v", err)
+	}
+	got = reconcile()
+	if got.Status.Condition != irsav1alpha1.IamPolicyFailed || !strings.Contains(got.Status.Reason, "Key missing is not found in secret queue") {
+		t.Fatalf("3 iam policy should be failed, but got: %v", got.Status)
+	}
+	kept, err := r.iamRoleClient.GetPolicy(context.Background(), got.Status.PolicyArn)
+	if err != nil {
+		t.Fatalf("3 get aws policy failed: %v", err)
+	}
+	if !reflect.DeepEqual(kept.Document, awsPolicy.Document) {
+		t.Fatalf("3 aws policy should not be changed, but got: %v", kept.Document)
+	}
+}
@@ -20,17 +20,25 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	gerrors "github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"domc.me/irsa-controller/api/v1alpha1"
 	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
@@ -39,11 +47,40 @@ import (
 )
 
 var (
-	ErrIamRoleNotCreated      = gerrors.New("Iam role has not been created")
-	ErrServiceAccountConflict = gerrors.New("ServiceAccount is already exists and not manged by irsa-controller")
-	ErrIamRoleConflict        = gerrors.New("Iam role is already exists and not manged by irsa-controller")
-	requeuePeriod             = time.Minute * 3
-	irsaAnnotationKey         = "eks.amazonaws.com/role-arn"
+	ErrIamRoleNotCreated       = gerrors.New("Iam role has not been created")
+	ErrServiceAccountConflict  = gerrors.New("ServiceAccount is already exists and not manged by irsa-controller")
+	ErrIamRoleConflict         = gerrors.New("Iam role is already exists and not manged by irsa-controller")
+	ErrServiceAccountForbidden = gerrors.New("ServiceAccount namespace does not trust the namespace of irsa")
+	ErrOutputConflict          = gerrors.New("Output is already exists and not manged by irsa-controller")
+	// ErrInlinePolicySourceConflict means more than one of inlinePolicy, inlinePolicyDocument and inlinePolicyFrom are set
+	ErrInlinePolicySourceConflict = gerrors.New("Only one of inlinePolicy, inlinePolicyDocument and inlinePolicyFrom can be set")
+	// ErrInlinePolicyNameConflict means the name of inline policies is duplicated or reserved by the default inline policy
+	ErrInlinePolicyNameConflict = gerrors.New("Name of inline policies must be unique and not be used by the default inline policy")
+	// ErrIamPolicyNotReady means the customer managed policy of referenced IamPolicy has not been created
+	ErrIamPolicyNotReady = gerrors.New("IamPolicy is not ready")
+	// ErrPolicyTemplateSourceConflict means templates are used with inlinePolicyDocument or inlinePolicyFrom
+	ErrPolicyTemplateSourceConflict = gerrors.New("Templates can only be merged into inlinePolicy, they cannot be used with inlinePolicyDocument or inlinePolicyFrom")
+	// ErrAccessSourceConflict means access is used with inlinePolicyDocument or inlinePolicyFrom
+	ErrAccessSourceConflict = gerrors.New("Access can only be merged into inlinePolicy, it cannot be used with inlinePolicyDocument or inlinePolicyFrom")
+	// ErrWildcardSubjectForbidden means irsa uses wildcard subject patterns but the controller does not allow them
+	ErrWildcardSubjectForbidden = gerrors.New("Wildcard subject patterns are not allowed by irsa-controller")
+	// ErrTrustPrincipalForbidden means irsa trusts an additional principal which is not allowed by the controller
+	ErrTrustPrincipalForbidden = gerrors.New("Trusted principal is not allowed by irsa-controller")
+	// ErrManagedPolicyInvalid means some managed policies cannot be attached to iam role
+	ErrManagedPolicyInvalid = gerrors.New("Managed policies are invalid")
+	requeuePeriod           = time.Minute * 3
+	irsaAnnotationKey       = "eks.amazonaws.com/role-arn"
+	// annotations of service account supported by amazon-eks-pod-identity-webhook
+	stsRegionalEndpointsAnnotationKey = "eks.amazonaws.com/sts-regional-endpoints"
+	tokenExpirationAnnotationKey      = "eks.amazonaws.com/token-expiration"
+	audienceAnnotationKey             = "eks.amazonaws.com/audience"
+	// irsaOwnerAnnotationKey records the owner irsa ( namespace/name ) of service account in other namespaces
+	irsaOwnerAnnotationKey = "irsa.domc.me/owner"
+	// irsaAdoptedByAnnotationKey records the irsa ( namespace/name ) which adopts the existing service account
+	irsaAdoptedByAnnotationKey = "irsa.domc.me/adopted-by"
+	// trustedNamespacesAnnotationKey is a comma separated list of namespaces on namespace,
+	// irsa in these namespaces can bind service accounts in the annotated namespace, "*" means all namespaces
+	trustedNamespacesAnnotationKey = "irsa.domc.me/trusted-namespaces"
 )
 
 // IamRoleServiceAccountReconciler reconciles a IamRoleServiceAccount object
@@ -52,24 +89,39 @@ type IamRoleServiceAccountReconciler struct {
 	scheme *runtime.Scheme
 
 	oidc string
+	// additionalOIDCProviders are the oidc providers of other clusters trusted by all of the iam roles
+	additionalOIDCProviders []irsav1alpha1.OIDCProvider
+	// allowWildcardSubjects allows irsa to trust service accounts by wildcard subject patterns
+	allowWildcardSubjects bool
+	// allowedTrustPrincipals are the principals irsa can trust by additional principals
+	allowedTrustPrincipals []string
 
 	iamRoleClient *aws.IamClient
 }
 
-func NewIamRoleServiceAccountReconciler(cli client.Client, scheme *runtime.Scheme, oidcProviderArn string, iamRoleClient *aws.IamClient) *IamRoleServiceAccountReconciler {
+func NewIamRoleServiceAccountReconciler(cli client.Client, scheme *runtime.Scheme, oidcProviderArn string, additionalOIDCProviders []irsav1alpha1.OIDCProvider, allowWildcardSubjects bool, allowedTrustPrincipals []string, iamRoleClient *aws.IamClient) *IamRoleServiceAccountReconciler {
 	return &IamRoleServiceAccountReconciler{
-		Client:        cli,
-		scheme:        scheme,
-		oidc:          oidcProviderArn,
-		iamRoleClient: iamRoleClient,
+		Client:                  cli,
+		scheme:                  scheme,
+		oidc:                    oidcProviderArn,
+		additionalOIDCProviders: additionalOIDCProviders,
+		allowWildcardSubjects:   allowWildcardSubjects,
+		allowedTrustPrincipals:  allowedTrustPrincipals,
+		iamRoleClient:           iamRoleClient,
 	}
 }
 
 //+kubebuilder:rbac:groups=irsa.domc.me,resources=iamroleserviceaccounts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=irsa.domc.me,resources=iamroleserviceaccounts/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=irsa.domc.me,resources=iamroleserviceaccounts/finalizers,verbs=update
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=iampolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=clusterpolicytemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=oidcproviderrotations,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=serviceaccounts/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -145,6 +197,15 @@ func (r *IamRoleServiceAccountReconciler) SetupWithManager(mgr ctrl.Manager) err
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&irsav1alpha1.IamRoleServiceAccount{}).
 		Owns(&corev1.ServiceAccount{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Watches(&source.Kind{Type: &corev1.ServiceAccount{}}, handler.EnqueueRequestsFromMapFunc(r.mapServiceAccountToIrsa)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.mapConfigMapToIrsa)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.mapSecretToIrsa)).
+		Watches(&source.Kind{Type: &irsav1alpha1.IamPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.mapIamPolicyToIrsa)).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.mapNamespaceToIrsa)).
+		Watches(&source.Kind{Type: &irsav1alpha1.ClusterPolicyTemplate{}}, handler.EnqueueRequestsFromMapFunc(r.mapClusterPolicyTemplateToIrsa)).
+		Watches(&source.Kind{Type: &irsav1alpha1.OIDCProviderRotation{}}, handler.EnqueueRequestsFromMapFunc(r.mapOIDCProviderRotationToIrsa)).
 		Complete(r)
 }
 
@@ -217,12 +278,14 @@ func (r *IamRoleServiceAccountReconciler) reconcile(ctx context.Context, irsa *i
 		return !updated, gerrors.Wrap(err, "Reconcile service account failed")
 	}
 
-	if irsa.Status.Condition != irsav1alpha1.IrsaOK {
-		updated := r.updateIrsaStatus(ctx, irsa, irsav1alpha1.IrsaOK, nil)
-		return !updated, nil
+	if err := r.reconcileOutputs(ctx, irsa); err != nil {
+		updated := r.updateIrsaStatus(ctx, irsa, irsav1alpha1.IrsaFailed, err)
+		return !updated, gerrors.Wrap(err, "Reconcile outputs failed")
 	}
 
-	return true, nil
+	// the status may be changed even if irsa is still ok, e.g. access statements
+	updated := r.updateIrsaStatus(ctx, irsa, irsav1alpha1.IrsaOK, nil)
+	return !updated, nil
 }
 
 // finalize returns hit rules, need requeue, errors
@@ -293,7 +356,22 @@ func (r *IamRoleServiceAccountReconciler) createExternalResources(ctx context.Co
 	}()
 
 	if roleName == "" {
-		roleArn, err = r.iamRoleClient.Create(ctx, r.oidc, irsa)
+		resolved, err := r.resolveIrsa(ctx, irsa)
+		if err != nil {
+			return gerrors.Wrap(err, "Resolve irsa failed")
+		}
+		var managedPolicies []string
+		if resolved.Spec.Policy != nil {
+			managedPolicies = resolved.Spec.Policy.ManagedPolicies
+		}
+		if err := r.checkManagedPolicies(ctx, irsa, managedPolicies); err != nil {
+			return err
+		}
+		vars, err := r.policyVariables(ctx, irsa)
+		if err != nil {
+			return err
+		}
+		roleArn, err = r.iamRoleClient.Create(ctx, r.oidc, resolved, vars)
 		if err != nil {
 			// if role already exists, check its tags, if its tag contains `irsa-controller: y` , update it. Else return error
 			if aws.ErrAlreadyExists(err) {
@@ -309,6 +387,8 @@ func (r *IamRoleServiceAccountReconciler) createExternalResources(ctx context.Co
 			return gerrors.Wrap(err, "Create iam role failed")
 		}
 		roleName = aws.RoleNameByArn(roleArn)
+	} else if err := r.checkTrust(ctx, irsa); err != nil {
+		return err
 	}
 
 	// update its trust entities
@@ -318,20 +398,62 @@ func (r *IamRoleServiceAccountReconciler) createExternalResources(ctx context.Co
 	}
 	roleArn = role.RoleArn
 
-	if !role.AssumeRolePolicy.IsAllowOIDC(r.oidc, irsa.GetNamespace(), irsa.GetName()) {
-		if err := r.iamRoleClient.AllowServiceAccountAccess(ctx, role, r.oidc, irsa.GetNamespace(), irsa.GetName()); err != nil {
-			return gerrors.Wrap(err, "Allow sa access iam role failed in create")
+	if err := r.allowServiceAccountsAccess(ctx, role, irsa); err != nil {
+		return gerrors.Wrap(err, "Allow sa access iam role failed in create")
+	}
+	if irsa.Spec.RoleName != "" {
+		if err := r.revokeRemovedServiceAccountsAccess(ctx, role, irsa); err != nil {
+			return err
 		}
+		return r.syncExternalRoleTags(ctx, role, irsa, false)
 	}
 
 	return nil
 }
 
+// reconcileServiceAccount reconciles all of the service accounts bound to irsa
+// and cleans the service accounts which are no longer bound to irsa
 func (r *IamRoleServiceAccountReconciler) reconcileServiceAccount(ctx context.Context, irsa *v1alpha1.IamRoleServiceAccount, dryRun bool) error {
+	var serviceAccounts []types.NamespacedName
+	// the service accounts are only trusted by the iam role in role-only mode
+	if irsa.CreateServiceAccounts() {
+		serviceAccounts = irsa.ServiceAccounts()
+	}
+	for _, key := range serviceAccounts {
+		if err := r.reconcileBoundServiceAccount(ctx, irsa, key, dryRun); err != nil {
+			return err
+		}
+	}
+
+	// only record the bound service accounts after they are created
+	if dryRun || irsa.Status.RoleArn == "" || irsa.Status.Condition != irsav1alpha1.IrsaOK {
+		return nil
+	}
+
+	bound := make([]string, 0, len(serviceAccounts))
+	for _, key := range serviceAccounts {
+		bound = append(bound, key.String())
+	}
+	for _, got := range irsa.Status.ServiceAccounts {
+		if slices.ContainsString(bound, got) {
+			continue
+		}
+		if err := r.deleteBoundServiceAccount(ctx, irsa, serviceAccountKeyFromString(got)); err != nil {
+			return err
+		}
+	}
+	if slices.Equal(irsa.Status.ServiceAccounts, bound) {
+		return nil
+	}
+	irsa.Status.ServiceAccounts = bound
+	return gerrors.Wrap(r.Status().Update(ctx, irsa), "Update bound service accounts failed")
+}
+
+func (r *IamRoleServiceAccountReconciler) reconcileBoundServiceAccount(ctx context.Context, irsa *v1alpha1.IamRoleServiceAccount, key types.NamespacedName, dryRun bool) error {
 	roleArn := irsa.Status.RoleArn
 	var sa corev1.ServiceAccount
-	namespace := irsa.GetNamespace()
-	saName := irsa.GetName()
+	namespace := key.Namespace
+	saName := key.Name
 	dryRunCreateOption := func(dry bool) []client.CreateOption {
 		if dry {
 			return []client.CreateOption{client.DryRunAll}
@@ -357,6 +479,10 @@ func (r *IamRoleServiceAccountReconciler) reconcileServiceAccount(ctx context.Co
 		return true
 	}
 
+	if err := r.checkServiceAccountNamespace(ctx, irsa, namespace); err != nil {
+		return err
+	}
+
 	err := r.Client.Get(ctx, types.NamespacedName{
 		Namespace: namespace,
 		Name:      saName,
@@ -368,11 +494,17 @@ func (r *IamRoleServiceAccountReconciler) reconcileServiceAccount(ctx context.Co
 	if sa.Annotations == nil {
 		sa.Annotations = make(map[string]string)
 	}
-	sa.Annotations[irsaAnnotationKey] = roleArn
 	if err != nil {
 		if errors.IsNotFound(err) {
-			if err := ctrl.SetControllerReference(irsa, &sa, r.scheme); err != nil {
-				return gerrors.Wrap(err, "Set controller reference failed")
+			applyServiceAccountTemplate(&sa, irsa.Spec.ServiceAccountTemplate)
+			sa.Annotations[irsaAnnotationKey] = roleArn
+			if namespace == irsa.GetNamespace() {
+				if err := ctrl.SetControllerReference(irsa, &sa, r.scheme); err != nil {
+					return gerrors.Wrap(err, "Set controller reference failed")
+				}
+			} else {
+				// owner reference cannot across namespaces
+				sa.Annotations[irsaOwnerAnnotationKey] = irsaKey(irsa)
 			}
 			if !shouldReconcile() {
 				// no need to reconcile if the status of irsa is not ok and is not dryRun
@@ -384,9 +516,17 @@ func (r *IamRoleServiceAccountReconciler) reconcileServiceAccount(ctx context.Co
 		return gerrors.Wrap(err, "Get service account failed")
 	}
 
-	if owned := r.serviceAccountNameIsOwnedByIrsa(&sa, irsa); !owned {
-		return ErrServiceAccountConflict
+	if owned := r.serviceAccountNameIsOwnedByIrsa(&sa, irsa); owned {
+		// correct the drift of service account created by irsa-controller
+		applyServiceAccountTemplate(&sa, irsa.Spec.ServiceAccountTemplate)
+	} else {
+		if !r.serviceAccountCanBeAdoptedByIrsa(&sa, irsa) {
+			return ErrServiceAccountConflict
+		}
+		// only manage the annotations of adopted service account
+		sa.Annotations[irsaAdoptedByAnnotationKey] = irsaKey(irsa)
 	}
+	sa.Annotations[irsaAnnotationKey] = roleArn
 
 	if !shouldReconcile() {
 		// no need to reconcile if the status of irsa is not ok and is not dryRun
@@ -394,28 +534,122 @@ func (r *IamRoleServiceAccountReconciler) reconcileServiceAccount(ctx context.Co
 	}
 
 	// no need to update if sa not changed
-	if originSa.GetAnnotations() != nil && originSa.GetAnnotations()[irsaAnnotationKey] == roleArn {
+	if equality.Semantic.DeepEqual(originSa, &sa) {
 		return nil
 	}
 
 	return r.Client.Update(ctx, &sa, dryRunUpdateOption(dryRun)...)
 }
 
+// applyServiceAccountTemplate sets the metadata and settings defined in template into service account
+func applyServiceAccountTemplate(sa *corev1.ServiceAccount, template *irsav1alpha1.ServiceAccountTemplateSpec) {
+	if template == nil {
+		return
+	}
+	if len(template.Labels) > 0 && sa.Labels == nil {
+		sa.Labels = make(map[string]string, len(template.Labels))
+	}
+	for k, v := range template.Labels {
+		sa.Labels[k] = v
+	}
+	if sa.Annotations == nil {
+		sa.Annotations = make(map[string]string)
+	}
+	for k, v := range template.Annotations {
+		sa.Annotations[k] = v
+	}
+	if template.StsRegionalEndpoints != nil {
+		sa.Annotations[stsRegionalEndpointsAnnotationKey] = strconv.FormatBool(*template.StsRegionalEndpoints)
+	}
+	if template.TokenExpiration != nil {
+		sa.Annotations[tokenExpirationAnnotationKey] = strconv.FormatInt(*template.TokenExpiration, 10)
+	}
+	if template.Audience != "" {
+		sa.Annotations[audienceAnnotationKey] = template.Audience
+	}
+	sa.ImagePullSecrets = template.ImagePullSecrets
+	sa.AutomountServiceAccountToken = template.AutomountServiceAccountToken
+}
+
+// checkServiceAccountNamespace checks whether irsa is allowed to bind service accounts in namespace
+func (r *IamRoleServiceAccountReconciler) checkServiceAccountNamespace(ctx context.Context, irsa *v1alpha1.IamRoleServiceAccount, namespace string) error {
+	if namespace == irsa.GetNamespace() {
+		return nil
+	}
+	var ns corev1.Namespace
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		if errors.IsNotFound(err) {
+			// a namespace which does not exist cannot trust irsa
+			return gerrors.Wrapf(ErrServiceAccountForbidden, "namespace %s does not exist", namespace)
+		}
+		return gerrors.Wrap(err, "Get namespace of service account failed")
+	}
+	for _, trusted := range strings.Split(ns.GetAnnotations()[trustedNamespacesAnnotationKey], ",") {
+		trusted = strings.TrimSpace(trusted)
+		if trusted == "*" || trusted == irsa.GetNamespace() {
+			return nil
+		}
+	}
+	return gerrors.Wrapf(ErrServiceAccountForbidden, "namespace %s", namespace)
+}
+
 func (r *IamRoleServiceAccountReconciler) serviceAccountNameIsOwnedByIrsa(sa *corev1.ServiceAccount, irsa *v1alpha1.IamRoleServiceAccount) bool {
 	for _, or := range sa.GetOwnerReferences() {
 		if or.UID == irsa.UID {
 			return true
 		}
 	}
+	if sa.GetNamespace() != irsa.GetNamespace() {
+		return sa.GetAnnotations()[irsaOwnerAnnotationKey] == irsaKey(irsa)
+	}
 	return false
 }
 
+// serviceAccountCanBeAdoptedByIrsa returns true if irsa adopts existing service accounts
+// and the service account is not owned or adopted by other irsa
+func (r *IamRoleServiceAccountReconciler) serviceAccountCanBeAdoptedByIrsa(sa *corev1.ServiceAccount, irsa *v1alpha1.IamRoleServiceAccount) bool {
+	if irsa.Spec.ServiceAccount == nil || !irsa.Spec.ServiceAccount.Adopt {
+		return false
+	}
+	for _, or := range sa.GetOwnerReferences() {
+		if or.Kind == "IamRoleServiceAccount" && or.APIVersion == irsav1alpha1.GroupVersion.String() {
+			return false
+		}
+	}
+	if _, ok := sa.GetAnnotations()[irsaOwnerAnnotationKey]; ok {
+		return false
+	}
+	adoptedBy, ok := sa.GetAnnotations()[irsaAdoptedByAnnotationKey]
+	return !ok || adoptedBy == irsaKey(irsa)
+}
+
+// deleteServiceAccount deletes all of the service accounts owned by irsa and releases the adopted ones
 func (r *IamRoleServiceAccountReconciler) deleteServiceAccount(ctx context.Context, irsa *v1alpha1.IamRoleServiceAccount) error {
+	keys := irsa.ServiceAccounts()
+	for _, got := range irsa.Status.ServiceAccounts {
+		key := serviceAccountKeyFromString(got)
+		found := false
+		for _, want := range keys {
+			if want == key {
+				found = true
+			}
+		}
+		if !found {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		if err := r.deleteBoundServiceAccount(ctx, irsa, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *IamRoleServiceAccountReconciler) deleteBoundServiceAccount(ctx context.Context, irsa *v1alpha1.IamRoleServiceAccount, key types.NamespacedName) error {
 	var sa corev1.ServiceAccount
-	err := r.Client.Get(ctx, types.NamespacedName{
-		Namespace: irsa.GetNamespace(),
-		Name:      irsa.GetName(),
-	}, &sa)
+	err := r.Client.Get(ctx, key, &sa)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return nil
@@ -424,7 +658,13 @@ func (r *IamRoleServiceAccountReconciler) deleteServiceAccount(ctx context.Conte
 	}
 	owned := r.serviceAccountNameIsOwnedByIrsa(&sa, irsa)
 	if !owned {
-		return nil
+		if sa.GetAnnotations()[irsaAdoptedByAnnotationKey] != irsaKey(irsa) {
+			return nil
+		}
+		// release the adopted service account
+		delete(sa.Annotations, irsaAnnotationKey)
+		delete(sa.Annotations, irsaAdoptedByAnnotationKey)
+		return gerrors.Wrap(r.Update(ctx, &sa), "Release adopted service account failed")
 	}
 
 	err = r.Delete(ctx, &sa)
@@ -434,9 +674,744 @@ func (r *IamRoleServiceAccountReconciler) deleteServiceAccount(ctx context.Conte
 	return nil
 }
 
+// allowServiceAccountsAccess makes all of the service accounts bound to irsa can assume the role,
+// the subjects are consolidated into one trust statement to save the quota of trust policy
+func (r *IamRoleServiceAccountReconciler) allowServiceAccountsAccess(ctx context.Context, role *aws.IamRole, irsa *irsav1alpha1.IamRoleServiceAccount) error {
+	oidcProviderArns, err := r.oidcProviderArns(ctx, irsa)
+	if err != nil {
+		return err
+	}
+	audience := aws.TrustAudience(irsa.Spec.Trust)
+	for _, oidcProviderArn := range oidcProviderArns {
+		var missing []types.NamespacedName
+		for _, sa := range irsa.ServiceAccounts() {
+			if !role.AssumeRolePolicy.IsAllowOIDC(oidcProviderArn, audience, sa.Namespace, sa.Name) {
+				missing = append(missing, sa)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if err := r.iamRoleClient.AllowServiceAccountsAccess(ctx, role, oidcProviderArn, audience, missing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeServiceAccountsAccess removes the subjects of service accounts bound to irsa from the trust policy of external role,
+// the subjects still used by other irsa sharing the role are kept
+func (r *IamRoleServiceAccountReconciler) revokeServiceAccountsAccess(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) error {
+	serviceAccounts := irsa.ServiceAccounts()
+	// the service accounts removed from spec but not revoked yet
+	for _, got := range irsa.Status.TrustedServiceAccounts {
+		key := serviceAccountKeyFromString(got)
+		if !serviceAccountsContain(serviceAccounts, key) {
+			serviceAccounts = append(serviceAccounts, key)
+		}
+	}
+	revoked, err := r.unusedServiceAccounts(ctx, irsa, serviceAccounts)
+	if err != nil || len(revoked) == 0 {
+		return err
+	}
+	role, err := r.iamRoleClient.Get(ctx, irsa.Spec.RoleName)
+	if err != nil {
+		if aws.ErrIsNotFound(err) {
+			return nil
+		}
+		return gerrors.Wrap(err, "Get role failed")
+	}
+	return r.revokeOIDCProvidersAccess(ctx, role, irsa, revoked)
+}
+
+// revokeRemovedServiceAccountsAccess removes the subjects of service accounts which are removed from the spec of irsa
+// from the trust policy of external role, then records the service accounts trusted for irsa in status.
+// The subjects still used by other irsa sharing the role are kept
+func (r *IamRoleServiceAccountReconciler) revokeRemovedServiceAccountsAccess(ctx context.Context, role *aws.IamRole, irsa *irsav1alpha1.IamRoleServiceAccount) error {
+	serviceAccounts := irsa.ServiceAccounts()
+	var removed []types.NamespacedName
+	for _, got := range irsa.Status.TrustedServiceAccounts {
+		key := serviceAccountKeyFromString(got)
+		if !serviceAccountsContain(serviceAccounts, key) {
+			removed = append(removed, key)
+		}
+	}
+	revoked, err := r.unusedServiceAccounts(ctx, irsa, removed)
+	if err != nil {
+		return err
+	}
+	if len(revoked) > 0 {
+		if err := r.revokeOIDCProvidersAccess(ctx, role, irsa, revoked); err != nil {
+			return gerrors.Wrap(err, "Revoke removed service accounts failed")
+		}
+	}
+	trusted := make([]string, 0, len(serviceAccounts))
+	for _, sa := range serviceAccounts {
+		trusted = append(trusted, sa.String())
+	}
+	irsa.Status.TrustedServiceAccounts = trusted
+	return nil
+}
+
+// unusedServiceAccounts returns the service accounts which are not used by other irsa sharing the role of irsa
+func (r *IamRoleServiceAccountReconciler) unusedServiceAccounts(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount, serviceAccounts []types.NamespacedName) ([]types.NamespacedName, error) {
+	if len(serviceAccounts) == 0 {
+		return nil, nil
+	}
+	var irsas irsav1alpha1.IamRoleServiceAccountList
+	if err := r.List(ctx, &irsas); err != nil {
+		return nil, gerrors.Wrap(err, "List irsa sharing the role failed")
+	}
+	inUse := map[types.NamespacedName]bool{}
+	for _, other := range irsas.Items {
+		if other.GetUID() == irsa.GetUID() || other.Spec.RoleName != irsa.Spec.RoleName || !other.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		for _, sa := range other.ServiceAccounts() {
+			inUse[sa] = true
+		}
+	}
+	var res []types.NamespacedName
+	for _, sa := range serviceAccounts {
+		if !inUse[sa] {
+			res = append(res, sa)
+		}
+	}
+	return res, nil
+}
+
+// revokeOIDCProvidersAccess removes the subjects of service accounts trusted by the oidc providers of irsa from the trust policy of role
+func (r *IamRoleServiceAccountReconciler) revokeOIDCProvidersAccess(ctx context.Context, role *aws.IamRole, irsa *irsav1alpha1.IamRoleServiceAccount, serviceAccounts []types.NamespacedName) error {
+	oidcProviderArns, err := r.oidcProviderArns(ctx, irsa)
+	if err != nil {
+		return err
+	}
+	for _, oidcProviderArn := range oidcProviderArns {
+		if err := r.iamRoleClient.RevokeServiceAccountsAccess(ctx, role, oidcProviderArn, serviceAccounts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func serviceAccountsContain(serviceAccounts []types.NamespacedName, key types.NamespacedName) bool {
+	for _, sa := range serviceAccounts {
+		if sa == key {
+			return true
+		}
+	}
+	return false
+}
+
+// oidcProviders returns the oidc providers of other clusters trusted by the role of irsa, which are the providers
+// in the trust of irsa, the additional providers of controller and the providers of this cluster being rotated
+func (r *IamRoleServiceAccountReconciler) oidcProviders(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) ([]irsav1alpha1.OIDCProvider, error) {
+	var providers []irsav1alpha1.OIDCProvider
+	if irsa.Spec.Trust != nil {
+		providers = append(providers, irsa.Spec.Trust.OIDCProviders...)
+	}
+	providers = append(providers, r.additionalOIDCProviders...)
+	var rotations irsav1alpha1.OIDCProviderRotationList
+	if err := r.List(ctx, &rotations); err != nil {
+		return nil, gerrors.Wrap(err, "List oidc provider rotations failed")
+	}
+	for _, rotation := range rotations.Items {
+		if rotation.Status.Phase == irsav1alpha1.OIDCProviderRotationFailed {
+			continue
+		}
+		// both of the oidc providers are trusted until the rotation is confirmed
+		if !rotation.Removing() {
+			providers = append(providers, irsav1alpha1.OIDCProvider{Arn: rotation.Spec.From, Cluster: r.iamRoleClient.ClusterName()})
+		}
+		providers = append(providers, irsav1alpha1.OIDCProvider{Arn: rotation.Spec.To, Cluster: r.iamRoleClient.ClusterName()})
+	}
+	var res []irsav1alpha1.OIDCProvider
+	seen := map[string]bool{r.oidc: true}
+	for _, provider := range providers {
+		if seen[provider.Arn] {
+			continue
+		}
+		seen[provider.Arn] = true
+		res = append(res, provider)
+	}
+	return res, nil
+}
+
+// oidcProviderArns returns the arns of the oidc provider of this cluster and the oidc providers returned by oidcProviders
+func (r *IamRoleServiceAccountReconciler) oidcProviderArns(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) ([]string, error) {
+	providers, err := r.oidcProviders(ctx, irsa)
+	if err != nil {
+		return nil, err
+	}
+	res := []string{r.oidc}
+	for _, provider := range providers {
+		res = append(res, provider.Arn)
+	}
+	return res, nil
+}
+
+// retiredOIDCProviderArns returns the arns of the oidc providers being removed by the confirmed rotations
+func (r *IamRoleServiceAccountReconciler) retiredOIDCProviderArns(ctx context.Context) ([]string, error) {
+	var rotations irsav1alpha1.OIDCProviderRotationList
+	if err := r.List(ctx, &rotations); err != nil {
+		return nil, gerrors.Wrap(err, "List oidc provider rotations failed")
+	}
+	var res []string
+	for _, rotation := range rotations.Items {
+		if rotation.Removing() && rotation.Spec.From != r.oidc {
+			res = append(res, rotation.Spec.From)
+		}
+	}
+	return res, nil
+}
+
+// resolveTrust sets the oidc providers into the trust of irsa
+func resolveTrust(irsa *irsav1alpha1.IamRoleServiceAccount, providers []irsav1alpha1.OIDCProvider) {
+	if len(providers) == 0 {
+		return
+	}
+	if irsa.Spec.Trust == nil {
+		irsa.Spec.Trust = &irsav1alpha1.TrustSpec{}
+	}
+	irsa.Spec.Trust.OIDCProviders = providers
+}
+
+// mapServiceAccountToIrsa returns the irsa which owns the service account in other namespace or adopts the service account
+func (r *IamRoleServiceAccountReconciler) mapServiceAccountToIrsa(obj client.Object) []reconcile.Request {
+	owner, ok := obj.GetAnnotations()[irsaOwnerAnnotationKey]
+	if !ok {
+		owner, ok = obj.GetAnnotations()[irsaAdoptedByAnnotationKey]
+	}
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: serviceAccountKeyFromString(owner)}}
+}
+
+func irsaKey(irsa *v1alpha1.IamRoleServiceAccount) string {
+	return types.NamespacedName{Namespace: irsa.GetNamespace(), Name: irsa.GetName()}.String()
+}
+
+func serviceAccountKeyFromString(s string) types.NamespacedName {
+	splits := strings.SplitN(s, string(types.Separator), 2)
+	if len(splits) != 2 {
+		return types.NamespacedName{Name: s}
+	}
+	return types.NamespacedName{Namespace: splits[0], Name: splits[1]}
+}
+
+// reconcileOutputs publishes the details of iam role to the outputs of irsa, and cleans the outputs which are no longer defined
+func (r *IamRoleServiceAccountReconciler) reconcileOutputs(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) error {
+	roleArn := irsa.Status.RoleArn
+	// role is not created, no need to publish
+	if roleArn == "" || irsa.Status.Condition != irsav1alpha1.IrsaOK {
+		return nil
+	}
+
+	wantConfigMaps := make(map[string]bool)
+	wantSecrets := make(map[string]bool)
+	for _, output := range irsa.Spec.Outputs {
+		keys := output.Keys
+		if keys == nil {
+			keys = &irsav1alpha1.OutputKeysSpec{}
+		}
+		data := map[string]string{
+			outputKey(keys.RoleArn, "roleArn"):           roleArn,
+			outputKey(keys.RoleName, "roleName"):         aws.RoleNameByArn(roleArn),
+			outputKey(keys.AccountID, "accountID"):       aws.AccountIDByArn(roleArn),
+			outputKey(keys.OIDCProvider, "oidcProvider"): r.oidc,
+		}
+		if output.Kind == irsav1alpha1.OutputSecret {
+			wantSecrets[output.Name] = true
+		} else {
+			wantConfigMaps[output.Name] = true
+		}
+		if err := r.reconcileOutput(ctx, irsa, output, data); err != nil {
+			return gerrors.Wrapf(err, "%s %s", output.Kind, output.Name)
+		}
+	}
+
+	var configMaps corev1.ConfigMapList
+	if err := r.List(ctx, &configMaps, client.InNamespace(irsa.GetNamespace())); err != nil {
+		return gerrors.Wrap(err, "List config maps failed")
+	}
+	for i := range configMaps.Items {
+		cm := &configMaps.Items[i]
+		if metav1.IsControlledBy(cm, irsa) && !wantConfigMaps[cm.GetName()] {
+			if err := r.Delete(ctx, cm); err != nil && !errors.IsNotFound(err) {
+				return gerrors.Wrap(err, "Delete config map failed")
+			}
+		}
+	}
+	var secrets corev1.SecretList
+	if err := r.List(ctx, &secrets, client.InNamespace(irsa.GetNamespace())); err != nil {
+		return gerrors.Wrap(err, "List secrets failed")
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if metav1.IsControlledBy(secret, irsa) && !wantSecrets[secret.GetName()] {
+			if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+				return gerrors.Wrap(err, "Delete secret failed")
+			}
+		}
+	}
+	return nil
+}
+
+func (r *IamRoleServiceAccountReconciler) reconcileOutput(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount, output irsav1alpha1.OutputSpec, data map[string]string) error {
+	objectMeta := metav1.ObjectMeta{
+		Namespace: irsa.GetNamespace(),
+		Name:      output.Name,
+	}
+	var obj client.Object
+	var setData func()
+	if output.Kind == irsav1alpha1.OutputSecret {
+		secret := &corev1.Secret{ObjectMeta: objectMeta}
+		obj = secret
+		setData = func() {
+			secret.Data = make(map[string][]byte, len(data))
+			for k, v := range data {
+				secret.Data[k] = []byte(v)
+			}
+		}
+	} else {
+		cm := &corev1.ConfigMap{ObjectMeta: objectMeta}
+		obj = cm
+		setData = func() {
+			cm.Data = data
+		}
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() error {
+		// object exists and is not created by irsa-controller
+		if obj.GetResourceVersion() != "" && !metav1.IsControlledBy(obj, irsa) {
+			return ErrOutputConflict
+		}
+		setData()
+		return ctrl.SetControllerReference(irsa, obj, r.scheme)
+	})
+	return err
+}
+
+func outputKey(key, defaultKey string) string {
+	if key == "" {
+		return defaultKey
+	}
+	return key
+}
+
+// resolveIrsa returns a copy of irsa whose policy sources are resolved and validated
+func (r *IamRoleServiceAccountReconciler) resolveIrsa(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) (*irsav1alpha1.IamRoleServiceAccount, error) {
+	if err := r.checkTrust(ctx, irsa); err != nil {
+		return nil, err
+	}
+	providers, err := r.oidcProviders(ctx, irsa)
+	if err != nil {
+		return nil, err
+	}
+	policy := irsa.Spec.Policy
+	if policy == nil {
+		if len(irsa.Spec.Access) == 0 {
+			irsa.Status.AccessStatements = nil
+			if _, err := r.renderIrsa(ctx, irsa); err != nil {
+				return nil, err
+			}
+			if len(providers) == 0 {
+				return irsa, nil
+			}
+			resolved := irsa.DeepCopy()
+			resolveTrust(resolved, providers)
+			return resolved, nil
+		}
+		// the statements of access are merged into the default inline policy
+		policy = &irsav1alpha1.PolicySpec{}
+	}
+	sources := 0
+	if policy.InlinePolicy != nil {
+		sources++
+	}
+	if policy.InlinePolicyDocument != "" {
+		sources++
+	}
+	if policy.InlinePolicyFrom != nil {
+		sources++
+	}
+	if sources > 1 {
+		return nil, ErrInlinePolicySourceConflict
+	}
+	if len(policy.Templates) > 0 && (policy.InlinePolicyDocument != "" || policy.InlinePolicyFrom != nil) {
+		return nil, ErrPolicyTemplateSourceConflict
+	}
+	if len(irsa.Spec.Access) > 0 && (policy.InlinePolicyDocument != "" || policy.InlinePolicyFrom != nil) {
+		return nil, ErrAccessSourceConflict
+	}
+	policyNames := map[string]struct{}{
+		r.iamRoleClient.InlinePolicyName(r.iamRoleClient.RoleName(irsa)): {},
+	}
+	for _, namedPolicy := range policy.InlinePolicies {
+		if _, ok := policyNames[namedPolicy.Name]; ok {
+			return nil, ErrInlinePolicyNameConflict
+		}
+		policyNames[namedPolicy.Name] = struct{}{}
+	}
+
+	resolved := irsa.DeepCopy()
+	resolveTrust(resolved, providers)
+	if resolved.Spec.Policy == nil {
+		resolved.Spec.Policy = policy
+	}
+	resolved.Spec.Policy.ManagedPolicies = nil
+	for _, managedPolicy := range policy.ManagedPolicies {
+		policyArn := aws.ManagedPolicyArn(r.oidc, managedPolicy)
+		if !slices.ContainsString(resolved.Spec.Policy.ManagedPolicies, policyArn) {
+			resolved.Spec.Policy.ManagedPolicies = append(resolved.Spec.Policy.ManagedPolicies, policyArn)
+		}
+	}
+	for _, ref := range policy.PolicyRefs {
+		var iamPolicy irsav1alpha1.IamPolicy
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: irsa.GetNamespace(), Name: ref.Name}, &iamPolicy); err != nil {
+			return nil, gerrors.Wrapf(err, "Get IamPolicy %s failed", ref.Name)
+		}
+		if iamPolicy.Status.PolicyArn == "" {
+			return nil, gerrors.Wrapf(ErrIamPolicyNotReady, "IamPolicy %s", ref.Name)
+		}
+		if !slices.ContainsString(resolved.Spec.Policy.ManagedPolicies, iamPolicy.Status.PolicyArn) {
+			resolved.Spec.Policy.ManagedPolicies = append(resolved.Spec.Policy.ManagedPolicies, iamPolicy.Status.PolicyArn)
+		}
+	}
+	if resolved.Spec.Policy.InlinePolicy != nil {
+		if err := resolveStatementResources(ctx, r.Client, irsa.GetNamespace(), resolved.Spec.Policy.InlinePolicy.Statement); err != nil {
+			return nil, err
+		}
+	}
+	for idx := range resolved.Spec.Policy.InlinePolicies {
+		if err := resolveStatementResources(ctx, r.Client, irsa.GetNamespace(), resolved.Spec.Policy.InlinePolicies[idx].Statement); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range policy.Templates {
+		var tpl irsav1alpha1.ClusterPolicyTemplate
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: ref.Name}, &tpl); err != nil {
+			return nil, gerrors.Wrapf(err, "Get ClusterPolicyTemplate %s failed", ref.Name)
+		}
+		statements, err := aws.RenderPolicyTemplate(&tpl, ref.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		if resolved.Spec.Policy.InlinePolicy == nil {
+			resolved.Spec.Policy.InlinePolicy = &irsav1alpha1.InlinePolicySpec{Version: "2012-10-17"}
+		}
+		resolved.Spec.Policy.InlinePolicy.Statement = append(resolved.Spec.Policy.InlinePolicy.Statement, statements...)
+	}
+	statements, err := aws.CompileAccess(r.oidc, irsa.Spec.Access)
+	if err != nil {
+		return nil, gerrors.Wrap(err, "Compile access failed")
+	}
+	irsa.Status.AccessStatements = statements
+	if len(statements) > 0 {
+		if resolved.Spec.Policy.InlinePolicy == nil {
+			resolved.Spec.Policy.InlinePolicy = &irsav1alpha1.InlinePolicySpec{Version: "2012-10-17"}
+		}
+		resolved.Spec.Policy.InlinePolicy.Statement = append(resolved.Spec.Policy.InlinePolicy.Statement, statements...)
+	}
+	if from := policy.InlinePolicyFrom; from != nil && from.ConfigMapKeyRef != nil {
+		ref := from.ConfigMapKeyRef
+		var cm corev1.ConfigMap
+		err := r.Client.Get(ctx, types.NamespacedName{Namespace: irsa.GetNamespace(), Name: ref.Name}, &cm)
+		if err != nil && !(errors.IsNotFound(err) && ref.Optional != nil && *ref.Optional) {
+			return nil, gerrors.Wrap(err, "Get inline policy config map failed")
+		}
+		doc, ok := cm.Data[ref.Key]
+		if !ok && (ref.Optional == nil || !*ref.Optional) {
+			return nil, fmt.Errorf("Key %s is not found in config map %s", ref.Key, ref.Name)
+		}
+		resolved.Spec.Policy.InlinePolicyFrom = nil
+		resolved.Spec.Policy.InlinePolicyDocument = doc
+	}
+	// the variables are only validated here, they are rendered in NewIamRole
+	rendered, err := r.renderIrsa(ctx, resolved)
+	if err != nil {
+		return nil, err
+	}
+	if doc := rendered.Spec.Policy.InlinePolicyDocument; doc != "" {
+		if _, err := aws.ParseRoleDocument(doc); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// checkTrust checks the namespaces of the bound service accounts and the guardrails of subject patterns, the namespaces must be
+// the namespace of irsa or trust it, and the wildcards must be allowed by controller. It also checks the additional principals
+// and oidc providers, the additional principals must be allowed by controller
+func (r *IamRoleServiceAccountReconciler) checkTrust(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) error {
+	// the service accounts are trusted by the iam role even if they are not created by irsa-controller
+	for _, key := range irsa.ServiceAccounts() {
+		if err := r.checkServiceAccountNamespace(ctx, irsa, key.Namespace); err != nil {
+			return err
+		}
+	}
+	if irsa.Spec.Trust == nil {
+		return nil
+	}
+	for _, pattern := range irsa.Spec.Trust.SubjectPatterns {
+		namespace, err := aws.ValidateSubjectPattern(pattern)
+		if err != nil {
+			return err
+		}
+		if strings.ContainsAny(pattern, "*?") && !r.allowWildcardSubjects {
+			return gerrors.Wrapf(ErrWildcardSubjectForbidden, "subject pattern %s", pattern)
+		}
+		if err := r.checkServiceAccountNamespace(ctx, irsa, namespace); err != nil {
+			return err
+		}
+	}
+	for _, principal := range irsa.Spec.Trust.AdditionalPrincipals {
+		if err := aws.ValidateTrustPrincipal(principal); err != nil {
+			return err
+		}
+		if value := aws.TrustPrincipalValue(principal); !slices.ContainsString(r.allowedTrustPrincipals, value) {
+			return gerrors.Wrapf(ErrTrustPrincipalForbidden, "principal %s", value)
+		}
+	}
+	for _, provider := range irsa.Spec.Trust.OIDCProviders {
+		if err := aws.ValidateOIDCProvider(provider); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveStatementResources reads the resource arns of statements from ConfigMaps or Secrets in namespace
+func resolveStatementResources(ctx context.Context, cli client.Client, namespace string, statements []irsav1alpha1.StatementSpec) error {
+	for idx := range statements {
+		statement := &statements[idx]
+		for _, from := range statement.ResourceFrom {
+			var value string
+			switch {
+			case from.ConfigMapKeyRef != nil && from.SecretKeyRef != nil:
+				return fmt.Errorf("Statement %d: only one of configMapKeyRef and secretKeyRef can be set in resourceFrom", idx)
+			case from.ConfigMapKeyRef != nil:
+				ref := from.ConfigMapKeyRef
+				optional := ref.Optional != nil && *ref.Optional
+				var cm corev1.ConfigMap
+				err := cli.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &cm)
+				if err != nil && !(errors.IsNotFound(err) && optional) {
+					return gerrors.Wrap(err, "Get resource config map failed")
+				}
+				v, ok := cm.Data[ref.Key]
+				if !ok && !optional {
+					return fmt.Errorf("Key %s is not found in config map %s", ref.Key, ref.Name)
+				}
+				value = v
+			case from.SecretKeyRef != nil:
+				ref := from.SecretKeyRef
+				optional := ref.Optional != nil && *ref.Optional
+				var secret corev1.Secret
+				err := cli.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret)
+				if err != nil && !(errors.IsNotFound(err) && optional) {
+					return gerrors.Wrap(err, "Get resource secret failed")
+				}
+				v, ok := secret.Data[ref.Key]
+				if !ok && !optional {
+					return fmt.Errorf("Key %s is not found in secret %s", ref.Key, ref.Name)
+				}
+				value = string(v)
+			}
+			for _, arn := range strings.FieldsFunc(value, func(c rune) bool { return c == ',' || unicode.IsSpace(c) }) {
+				if !slices.ContainsString(statement.Resource, arn) {
+					statement.Resource = append(statement.Resource, arn)
+				}
+			}
+		}
+		statement.ResourceFrom = nil
+	}
+	return nil
+}
+
+// statementsReferenceObject returns true if any statement reads resource arns from the ConfigMap or Secret
+func statementsReferenceObject(statements []irsav1alpha1.StatementSpec, obj client.Object) bool {
+	_, isSecret := obj.(*corev1.Secret)
+	for _, statement := range statements {
+		for _, from := range statement.ResourceFrom {
+			if !isSecret && from.ConfigMapKeyRef != nil && from.ConfigMapKeyRef.Name == obj.GetName() {
+				return true
+			}
+			if isSecret && from.SecretKeyRef != nil && from.SecretKeyRef.Name == obj.GetName() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// irsaReferencesObject returns true if the statements of irsa read resource arns from the ConfigMap or Secret
+func irsaReferencesObject(irsa *irsav1alpha1.IamRoleServiceAccount, obj client.Object) bool {
+	policy := irsa.Spec.Policy
+	if policy == nil {
+		return false
+	}
+	if policy.InlinePolicy != nil && statementsReferenceObject(policy.InlinePolicy.Statement, obj) {
+		return true
+	}
+	for _, namedPolicy := range policy.InlinePolicies {
+		if statementsReferenceObject(namedPolicy.Statement, obj) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyVariables returns the variables used to render the statements and tags of irsa
+func (r *IamRoleServiceAccountReconciler) policyVariables(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) (aws.PolicyVariables, error) {
+	var ns corev1.Namespace
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: irsa.GetNamespace()}, &ns); err != nil && !errors.IsNotFound(err) {
+		return nil, gerrors.Wrap(err, "Get namespace of irsa failed")
+	}
+	return aws.NewPolicyVariables(r.oidc, r.iamRoleClient.ClusterName(), irsa, ns.GetLabels()), nil
+}
+
+// renderIrsa returns a copy of irsa whose statements and tags are rendered,
+// returns error if any variable cannot be resolved
+func (r *IamRoleServiceAccountReconciler) renderIrsa(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) (*irsav1alpha1.IamRoleServiceAccount, error) {
+	vars, err := r.policyVariables(ctx, irsa)
+	if err != nil {
+		return nil, err
+	}
+	return vars.RenderIrsa(irsa)
+}
+
+// checkManagedPolicies checks whether the managed policies exist, and records the errors of each policy in status
+func (r *IamRoleServiceAccountReconciler) checkManagedPolicies(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount, policyArns []string) error {
+	var policyErrors []irsav1alpha1.ManagedPolicyError
+	var msgs []string
+	for _, policyArn := range policyArns {
+		if err := r.iamRoleClient.CheckPolicyExists(ctx, policyArn); err != nil {
+			policyErrors = append(policyErrors, irsav1alpha1.ManagedPolicyError{PolicyArn: policyArn, Error: err.Error()})
+			msgs = append(msgs, fmt.Sprintf("%s: %v", policyArn, err))
+		}
+	}
+	irsa.Status.ManagedPolicyErrors = policyErrors
+	if len(policyErrors) > 0 {
+		return gerrors.Wrap(ErrManagedPolicyInvalid, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// mapConfigMapToIrsa returns the irsa whose inline policy or resource arns are sourced from the config map
+func (r *IamRoleServiceAccountReconciler) mapConfigMapToIrsa(obj client.Object) []reconcile.Request {
+	var irsas irsav1alpha1.IamRoleServiceAccountList
+	if err := r.List(context.Background(), &irsas, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.Log.Error(err, "List irsa by config map failed")
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, irsa := range irsas.Items {
+		policy := irsa.Spec.Policy
+		if policy == nil {
+			continue
+		}
+		fromPolicy := policy.InlinePolicyFrom != nil && policy.InlinePolicyFrom.ConfigMapKeyRef != nil && policy.InlinePolicyFrom.ConfigMapKeyRef.Name == obj.GetName()
+		if fromPolicy || irsaReferencesObject(&irsa, obj) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: irsa.GetNamespace(), Name: irsa.GetName()}})
+		}
+	}
+	return reqs
+}
+
+// mapSecretToIrsa returns the irsa whose statements read resource arns from the secret
+func (r *IamRoleServiceAccountReconciler) mapSecretToIrsa(obj client.Object) []reconcile.Request {
+	var irsas irsav1alpha1.IamRoleServiceAccountList
+	if err := r.List(context.Background(), &irsas, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.Log.Error(err, "List irsa by secret failed")
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, irsa := range irsas.Items {
+		if irsaReferencesObject(&irsa, obj) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: irsa.GetNamespace(), Name: irsa.GetName()}})
+		}
+	}
+	return reqs
+}
+
+// mapOIDCProviderRotationToIrsa returns all of the irsa, the trust relationship of every iam role is changed by the rotation
+func (r *IamRoleServiceAccountReconciler) mapOIDCProviderRotationToIrsa(obj client.Object) []reconcile.Request {
+	var irsas irsav1alpha1.IamRoleServiceAccountList
+	if err := r.List(context.Background(), &irsas); err != nil {
+		log.Log.Error(err, "List irsa by oidc provider rotation failed")
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(irsas.Items))
+	for _, irsa := range irsas.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: irsa.GetNamespace(), Name: irsa.GetName()}})
+	}
+	return reqs
+}
+
+// mapNamespaceToIrsa returns all of the irsa in namespace, so that the variables of namespace labels can be rendered again
+func (r *IamRoleServiceAccountReconciler) mapNamespaceToIrsa(obj client.Object) []reconcile.Request {
+	var irsas irsav1alpha1.IamRoleServiceAccountList
+	if err := r.List(context.Background(), &irsas, client.InNamespace(obj.GetName())); err != nil {
+		log.Log.Error(err, "List irsa by namespace failed")
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(irsas.Items))
+	for _, irsa := range irsas.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: irsa.GetNamespace(), Name: irsa.GetName()}})
+	}
+	return reqs
+}
+
+// mapClusterPolicyTemplateToIrsa returns the irsa in all namespaces which references the ClusterPolicyTemplate
+func (r *IamRoleServiceAccountReconciler) mapClusterPolicyTemplateToIrsa(obj client.Object) []reconcile.Request {
+	var irsas irsav1alpha1.IamRoleServiceAccountList
+	if err := r.List(context.Background(), &irsas); err != nil {
+		log.Log.Error(err, "List irsa by cluster policy template failed")
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, irsa := range irsas.Items {
+		if irsa.Spec.Policy == nil {
+			continue
+		}
+		for _, ref := range irsa.Spec.Policy.Templates {
+			if ref.Name == obj.GetName() {
+				reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: irsa.GetNamespace(), Name: irsa.GetName()}})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
+// mapIamPolicyToIrsa returns the irsa which references the IamPolicy
+func (r *IamRoleServiceAccountReconciler) mapIamPolicyToIrsa(obj client.Object) []reconcile.Request {
+	var irsas irsav1alpha1.IamRoleServiceAccountList
+	if err := r.List(context.Background(), &irsas, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.Log.Error(err, "List irsa by iam policy failed")
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, irsa := range irsas.Items {
+		if irsa.Spec.Policy == nil {
+			continue
+		}
+		for _, ref := range irsa.Spec.Policy.PolicyRefs {
+			if ref.Name == obj.GetName() {
+				reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: irsa.GetNamespace(), Name: irsa.GetName()}})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
 func (r *IamRoleServiceAccountReconciler) updateExternalResourcesIfNeed(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) error {
 	// the role is created externally
 	if irsa.Spec.RoleName != "" {
+		// managed policies are not attached to the external role
+		irsa.Status.ManagedPolicyErrors = nil
 		return r.updateExternalIamRoleIfNeed(ctx, irsa)
 	}
 	roleArn := irsa.Status.RoleArn
@@ -450,7 +1425,29 @@ func (r *IamRoleServiceAccountReconciler) updateExternalResourcesIfNeed(ctx cont
 		return gerrors.Wrap(err, "Get iam role by roleName failed")
 	}
 
-	wantRole := aws.NewIamRole(r.oidc, irsa, r.iamRoleClient.GetAdditionalTags())
+	resolved, err := r.resolveIrsa(ctx, irsa)
+	if err != nil {
+		return gerrors.Wrap(err, "Resolve irsa failed")
+	}
+	vars, err := r.policyVariables(ctx, irsa)
+	if err != nil {
+		return err
+	}
+	wantRole, err := aws.NewIamRole(r.oidc, resolved, r.iamRoleClient.IrsaRoleTags(resolved), vars)
+	if err != nil {
+		return err
+	}
+	// fail early instead of getting LimitExceeded from aws
+	if err := wantRole.CheckPoliciesSize(r.iamRoleClient.TrustPolicySizeLimit()); err != nil {
+		return err
+	}
+
+	// validate the managed policies before attaching, avoid leaving a half-attached role,
+	// the errors in status are refreshed on every reconcile, so they are cleaned once the policies are attached or removed
+	attaches := slices.Difference(wantRole.ManagedPolicies, gotRole.ManagedPolicies)
+	if err := r.checkManagedPolicies(ctx, irsa, attaches); err != nil {
+		return err
+	}
 
 	// compare spec and iam role detail
 
@@ -462,31 +1459,7 @@ func (r *IamRoleServiceAccountReconciler) updateExternalResourcesIfNeed(ctx cont
 	// compare managedPolicies
 	if !slices.Equal(gotRole.ManagedPolicies, wantRole.ManagedPolicies) {
 		// update managed polices
-		var attaches, deAttaches []string
-		for _, want := range wantRole.ManagedPolicies {
-			found := false
-			for _, got := range gotRole.ManagedPolicies {
-				if want == got {
-					found = true
-				}
-			}
-
-			if !found {
-				attaches = append(attaches, want)
-			}
-		}
-		for _, got := range gotRole.ManagedPolicies {
-			found := false
-			for _, want := range wantRole.ManagedPolicies {
-				if got == want {
-					found = true
-				}
-			}
-
-			if !found {
-				deAttaches = append(deAttaches, got)
-			}
-		}
+		deAttaches := slices.Difference(gotRole.ManagedPolicies, wantRole.ManagedPolicies)
 
 		if err := r.iamRoleClient.AttachRolePolicy(ctx, roleName, attaches); err != nil {
 			return gerrors.Wrap(err, "Sync missing managed roles failed")
@@ -496,6 +1469,22 @@ func (r *IamRoleServiceAccountReconciler) updateExternalResourcesIfNeed(ctx cont
 		}
 	}
 
+	// create or update the owned managed policies before updating inline policy,
+	// so that the statements moved from inline policy are always granted
+	for idx, want := range wantRole.OwnedPolicies {
+		if idx >= len(gotRole.OwnedPolicies) || gotRole.OwnedPolicies[idx] == nil {
+			if err := r.iamRoleClient.CreateOwnedPolicy(ctx, roleArn, idx, want); err != nil {
+				return gerrors.Wrap(err, "Create owned managed policy failed")
+			}
+			continue
+		}
+		if !reflect.DeepEqual(gotRole.OwnedPolicies[idx], want) {
+			if err := r.iamRoleClient.UpdatePolicy(ctx, r.iamRoleClient.OwnedPolicyArn(roleArn, idx), want); err != nil {
+				return gerrors.Wrap(err, "Update owned managed policy failed")
+			}
+		}
+	}
+
 	if !reflect.DeepEqual(gotRole.InlinePolicy, wantRole.InlinePolicy) {
 		var err error
 		if wantRole.InlinePolicy == nil {
@@ -508,24 +1497,113 @@ func (r *IamRoleServiceAccountReconciler) updateExternalResourcesIfNeed(ctx cont
 		}
 	}
 
+	// sync named inline policies
+	for policyName, want := range wantRole.InlinePolicies {
+		if got, ok := gotRole.InlinePolicies[policyName]; ok && reflect.DeepEqual(got, want) {
+			continue
+		}
+		if err := r.iamRoleClient.PutInlinePolicy(ctx, roleName, policyName, want); err != nil {
+			return gerrors.Wrap(err, "Sync named inline policy failed")
+		}
+	}
+	for policyName := range gotRole.InlinePolicies {
+		if _, ok := wantRole.InlinePolicies[policyName]; ok {
+			continue
+		}
+		if err := r.iamRoleClient.DeleteNamedInlinePolicy(ctx, roleName, policyName); err != nil {
+			return gerrors.Wrap(err, "Delete overflow named inline policy failed")
+		}
+	}
+
+	for idx := len(wantRole.OwnedPolicies); idx < len(gotRole.OwnedPolicies); idx++ {
+		if gotRole.OwnedPolicies[idx] == nil {
+			continue
+		}
+		if err := r.iamRoleClient.DeleteOwnedPolicy(ctx, roleArn, idx); err != nil {
+			return gerrors.Wrap(err, "Delete overflow owned managed policy failed")
+		}
+	}
+
 	if !reflect.DeepEqual(gotRole.AssumeRolePolicy, wantRole.AssumeRolePolicy) {
-		assumeRolePolicy := aws.NewAssumeRolePolicy(r.oidc, irsa.GetNamespace(), irsa.GetName())
-		err = r.iamRoleClient.UpdateAssumePolicy(ctx, roleName, &assumeRolePolicy)
+		err = r.iamRoleClient.UpdateAssumePolicy(ctx, roleName, wantRole.AssumeRolePolicy)
 		if err != nil {
 			return gerrors.Wrap(err, "Sync assume role policy failed")
 		}
 	}
 
-	if !reflect.DeepEqual(gotRole.Tags, wantRole.Tags) {
-		err = r.iamRoleClient.UpdateTags(ctx, roleName, wantRole.Tags)
-		if err != nil {
-			return gerrors.Wrap(err, "Sync iam role tag failed")
+	// the cluster tags added by the controllers of other clusters sharing the role are not compared
+	for k, v := range wantRole.Tags {
+		if got, ok := gotRole.Tags[k]; !ok || got != v {
+			if err := r.iamRoleClient.UpdateTags(ctx, roleName, wantRole.Tags); err != nil {
+				return gerrors.Wrap(err, "Sync iam role tag failed")
+			}
+			break
+		}
+	}
+	// remove the clusters which no longer depend on the role, only the cluster tags added by this controller are removed
+	var staleClusters []string
+	for k, v := range gotRole.Tags {
+		if _, ok := wantRole.Tags[k]; !ok && r.iamRoleClient.IsOwnedClusterTag(k, v) {
+			staleClusters = append(staleClusters, k)
 		}
 	}
+	if err := r.iamRoleClient.RemoveTags(ctx, roleName, staleClusters); err != nil {
+		return gerrors.Wrap(err, "Remove stale cluster tags failed")
+	}
 
 	return nil
 }
 
+// syncExternalRoleTags adds the cluster tags of irsa sharing the external role additively, and removes the cluster tags
+// added by this controller which are no longer wanted by any irsa, the tags added by others are never removed
+func (r *IamRoleServiceAccountReconciler) syncExternalRoleTags(ctx context.Context, role *aws.IamRole, irsa *irsav1alpha1.IamRoleServiceAccount, deleted bool) error {
+	var irsas irsav1alpha1.IamRoleServiceAccountList
+	if err := r.List(ctx, &irsas); err != nil {
+		return gerrors.Wrap(err, "List irsa sharing the role failed")
+	}
+	sharing := make([]irsav1alpha1.IamRoleServiceAccount, 0, len(irsas.Items)+1)
+	if !deleted {
+		sharing = append(sharing, *irsa)
+	}
+	for _, other := range irsas.Items {
+		if other.GetUID() == irsa.GetUID() || other.Spec.RoleName != irsa.Spec.RoleName || !other.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		sharing = append(sharing, other)
+	}
+	want := map[string]string{}
+	for i := range sharing {
+		providers, err := r.oidcProviders(ctx, &sharing[i])
+		if err != nil {
+			return err
+		}
+		withProviders := sharing[i].DeepCopy()
+		withProviders.Spec.Trust = &irsav1alpha1.TrustSpec{OIDCProviders: providers}
+		for k, v := range r.iamRoleClient.ClusterTags(withProviders) {
+			want[k] = v
+		}
+	}
+	missing := map[string]string{}
+	for k, v := range want {
+		// the tag added by others already records the cluster
+		if _, ok := role.Tags[k]; !ok {
+			missing[k] = v
+		}
+	}
+	if len(missing) > 0 {
+		if err := r.iamRoleClient.UpdateTags(ctx, role.RoleName, missing); err != nil {
+			return gerrors.Wrap(err, "Add cluster tags to external role failed")
+		}
+	}
+	var stale []string
+	for k, v := range role.Tags {
+		if _, ok := want[k]; !ok && r.iamRoleClient.IsOwnedClusterTag(k, v) {
+			stale = append(stale, k)
+		}
+	}
+	return gerrors.Wrap(r.iamRoleClient.RemoveTags(ctx, role.RoleName, stale), "Remove stale cluster tags of external role failed")
+}
+
 // updateExternalIamRoleIfNeed checks the role can be assumed by oidc
 // if not let it can be accessed, or do nothing
 func (r *IamRoleServiceAccountReconciler) updateExternalIamRoleIfNeed(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) error {
@@ -534,14 +1612,30 @@ func (r *IamRoleServiceAccountReconciler) updateExternalIamRoleIfNeed(ctx contex
 	if roleName == "" {
 		return nil
 	}
+	if err := r.checkTrust(ctx, irsa); err != nil {
+		return err
+	}
 
 	role, err := r.iamRoleClient.Get(ctx, roleName)
 	if err != nil {
 		return gerrors.Wrap(err, "Get role failed")
 	}
-	if !role.AssumeRolePolicy.IsAllowOIDC(r.oidc, irsa.GetNamespace(), irsa.GetName()) {
-		if err := r.iamRoleClient.AllowServiceAccountAccess(ctx, role, r.oidc, irsa.GetNamespace(), irsa.GetName()); err != nil {
-			return gerrors.Wrap(err, "Allow sa access iam role failed in update")
+	if err := r.allowServiceAccountsAccess(ctx, role, irsa); err != nil {
+		return gerrors.Wrap(err, "Allow sa access iam role failed in update")
+	}
+	if err := r.revokeRemovedServiceAccountsAccess(ctx, role, irsa); err != nil {
+		return err
+	}
+	if err := r.syncExternalRoleTags(ctx, role, irsa, false); err != nil {
+		return err
+	}
+	retired, err := r.retiredOIDCProviderArns(ctx)
+	if err != nil {
+		return err
+	}
+	for _, oidcProviderArn := range retired {
+		if err := r.iamRoleClient.RevokeServiceAccountsAccess(ctx, role, oidcProviderArn, irsa.ServiceAccounts()); err != nil {
+			return gerrors.Wrap(err, "Revoke retired oidc provider failed")
 		}
 	}
 	return nil
@@ -551,8 +1645,18 @@ func (r *IamRoleServiceAccountReconciler) deleteExternalResources(ctx context.Co
 	l := log.FromContext(ctx)
 	// check if need to delete aws iam role
 	if irsa.Spec.RoleName != "" {
-		l.V(5).Info("ARN is specified in spec, no need to delete")
-		return nil
+		l.V(5).Info("ARN is specified in spec, no need to delete, revoke the access of service accounts")
+		if err := r.revokeServiceAccountsAccess(ctx, irsa); err != nil {
+			return err
+		}
+		role, err := r.iamRoleClient.Get(ctx, irsa.Spec.RoleName)
+		if err != nil {
+			if aws.ErrIsNotFound(err) {
+				return nil
+			}
+			return gerrors.Wrap(err, "Get role failed")
+		}
+		return r.syncExternalRoleTags(ctx, role, irsa, true)
 	}
 	roleArn := irsa.Status.RoleArn
 	if roleArn == "" {
@@ -576,12 +1680,7 @@ func (r *IamRoleServiceAccountReconciler) updateIrsaStatus(ctx context.Context,
 	if reconcileErr != nil {
 		newReason = reconcileErr.Error()
 	}
-	compare := func(a, b string) bool {
-		// ignore request id
-		flag := "request id"
-		return strings.Split(a, flag)[0] == strings.Split(b, flag)[0]
-	}
-	if from == condition && compare(irsa.Status.Reason, newReason) {
+	if from == condition && sameReason(irsa.Status.Reason, newReason) && !r.statusChanged(ctx, irsa) {
 		return false
 	}
 	l.Info("Updating status ...", "msg", newReason)
@@ -593,3 +1692,18 @@ func (r *IamRoleServiceAccountReconciler) updateIrsaStatus(ctx context.Context,
 	}
 	return err == nil
 }
+
+// statusChanged returns true if the status of irsa, e.g. access statements, is different from the one stored in cluster
+func (r *IamRoleServiceAccountReconciler) statusChanged(ctx context.Context, irsa *irsav1alpha1.IamRoleServiceAccount) bool {
+	var origin irsav1alpha1.IamRoleServiceAccount
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(irsa), &origin); err != nil {
+		return false
+	}
+	return !equality.Semantic.DeepEqual(origin.Status, irsa.Status)
+}
+
+// sameReason compares the reasons of failure ignoring the request id of aws
+func sameReason(a, b string) bool {
+	flag := "request id"
+	return strings.Split(a, flag)[0] == strings.Split(b, flag)[0]
+}
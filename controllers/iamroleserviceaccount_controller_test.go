@@ -20,19 +20,24 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
 
 	"domc.me/irsa-controller/api/v1alpha1"
 	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
 	"domc.me/irsa-controller/pkg/aws"
+	"domc.me/irsa-controller/pkg/utils/slices"
 	goAws "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
+	gerrors "github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -48,9 +53,9 @@ func getReconciler(mic *aws.MockedIamClient, objs ...runtime.Object) *IamRoleSer
 	fakeClient := fake.NewFakeClientWithScheme(scheme, objs...)
 
 	oidc := "test"
-	iamRoleClient := aws.NewIamClientWithIamAPI("test", "test", []string{}, mic)
+	iamRoleClient := aws.NewIamClientWithAPI("test", "test", []string{}, mic, aws.NewMockedStsClient("000000000000"))
 
-	r := NewIamRoleServiceAccountReconciler(fakeClient, scheme, oidc, iamRoleClient)
+	r := NewIamRoleServiceAccountReconciler(fakeClient, scheme, oidc, nil, false, nil, iamRoleClient)
 	return r
 }
 
@@ -119,9 +124,22 @@ func TestIamRoleServiceAccountReconciler_updateExternalResourcesIfNeed(t *testin
 	if err != nil {
 		t.Fatalf("Get external role failed: %v", err)
 	}
-	if !gotExternalRole.AssumeRolePolicy.IsAllowOIDC(oidc, irsa.GetNamespace(), irsa.GetName()) {
+	if !gotExternalRole.AssumeRolePolicy.IsAllowOIDC(oidc, aws.DefaultAudience, irsa.GetNamespace(), irsa.GetName()) {
 		t.Fatalf("External role should allow oidc, but not")
 	}
+
+	// 2. external iam role allow irsa access with the audience of irsa
+	irsa.Spec.Trust = &irsav1alpha1.TrustSpec{Audience: "vault"}
+	if err := r.updateExternalIamRoleIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("2 updateExternalIamRoleIfNeed failed: %v", err)
+	}
+	gotExternalRole, err = r.iamRoleClient.Get(context.Background(), irsa.Spec.RoleName)
+	if err != nil {
+		t.Fatalf("2 Get external role failed: %v", err)
+	}
+	if !gotExternalRole.AssumeRolePolicy.IsAllowOIDC(oidc, "vault", irsa.GetNamespace(), irsa.GetName()) {
+		t.Fatalf("2 External role should allow oidc with audience vault, but got: %v", gotExternalRole.AssumeRolePolicy)
+	}
 	// r.iamRoleClient.Create(context.Background(), oidc, irsa*irsav1alpha1.IamRoleServiceAccount)
 
 }
@@ -238,7 +256,7 @@ func TestIamRoleServiceAccountReconciler_createExternalResources(t *testing.T) {
 	if err != nil {
 		t.Fatalf("1 get iam role failed: %v", err)
 	}
-	if !role.IsManagedByIrsaController() || !role.AssumeRolePolicy.IsAllowOIDC("test", irsa.GetNamespace(), irsa.GetName()) {
+	if !role.IsManagedByIrsaController() || !role.AssumeRolePolicy.IsAllowOIDC("test", aws.DefaultAudience, irsa.GetNamespace(), irsa.GetName()) {
 		t.Fatal("1 role should be assumed by irsa, but not")
 	}
 
@@ -260,7 +278,7 @@ func TestIamRoleServiceAccountReconciler_createExternalResources(t *testing.T) {
 		t.Fatalf("2 get iam role failed: %v", err)
 	}
 	// should not add irsa tag
-	if externalRole.IsManagedByIrsaController() || !externalRole.AssumeRolePolicy.IsAllowOIDC("test", irsa.GetNamespace(), irsa.GetName()) {
+	if externalRole.IsManagedByIrsaController() || !externalRole.AssumeRolePolicy.IsAllowOIDC("test", aws.DefaultAudience, irsa.GetNamespace(), irsa.GetName()) {
 		t.Fatal("2 role should be assumed by irsa and not managed by irsa, but not")
 	}
 
@@ -351,6 +369,232 @@ func TestIamRoleServiceAccountReconciler_reconcileServiceAccount(t *testing.T) {
 	}
 }
 
+func TestIamRoleServiceAccountReconciler_reconcileBoundServiceAccount(t *testing.T) {
+	roleArn := "arn:aws:iam::000000000000:role/mock-role"
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			ServiceAccounts: []irsav1alpha1.ServiceAccountRef{
+				{Name: "app"},
+				{Name: "app", Namespace: "trusted"},
+			},
+		},
+		Status: irsav1alpha1.IamRoleServiceAccountStatus{
+			RoleArn:   roleArn,
+			Condition: irsav1alpha1.IrsaOK,
+		},
+	}
+	trusted := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "trusted",
+			Annotations: map[string]string{
+				trustedNamespacesAnnotationKey: "kube-system, default",
+			},
+		},
+	}
+	untrusted := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "untrusted",
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa, trusted, untrusted)
+
+	// 1. all of the service accounts should be created
+	if err := r.reconcileServiceAccount(context.Background(), irsa, false); err != nil {
+		t.Fatalf("1 reconcileServiceAccount failed: %v", err)
+	}
+	for _, key := range irsa.ServiceAccounts() {
+		sa := &corev1.ServiceAccount{}
+		if err := r.Get(context.Background(), key, sa); err != nil {
+			t.Fatalf("1 get sa %s failed: %v", key, err)
+		}
+		if !r.serviceAccountNameIsOwnedByIrsa(sa, irsa) || sa.Annotations[irsaAnnotationKey] != roleArn {
+			t.Fatalf("1 service account %s should be owned by irsa and be assumed, but not", key)
+		}
+	}
+	if want := []string{"default/app", "trusted/app"}; !slices.Equal(irsa.Status.ServiceAccounts, want) {
+		t.Fatalf("1 bound service accounts got: %v, want: %v", irsa.Status.ServiceAccounts, want)
+	}
+	if reqs := r.mapServiceAccountToIrsa(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{irsaOwnerAnnotationKey: "default/irsa"}}}); len(reqs) != 1 || reqs[0].NamespacedName != (types.NamespacedName{Namespace: "default", Name: "irsa"}) {
+		t.Fatalf("1 service account in other namespace should be mapped to irsa, but got: %v", reqs)
+	}
+
+	// 2. namespace does not trust irsa
+	irsa.Spec.ServiceAccounts = append(irsa.Spec.ServiceAccounts, irsav1alpha1.ServiceAccountRef{Name: "app", Namespace: "untrusted"})
+	err := r.reconcileServiceAccount(context.Background(), irsa, true)
+	if !gerrors.Is(err, ErrServiceAccountForbidden) {
+		t.Fatalf("2 untrusted namespace should get forbidden err, but get: %v", err)
+	}
+
+	// 3. service accounts no longer bound should be deleted
+	irsa.Spec.ServiceAccounts = []irsav1alpha1.ServiceAccountRef{{Name: "app"}}
+	if err := r.reconcileServiceAccount(context.Background(), irsa, false); err != nil {
+		t.Fatalf("3 reconcileServiceAccount failed: %v", err)
+	}
+	err = r.Get(context.Background(), types.NamespacedName{Namespace: "trusted", Name: "app"}, &corev1.ServiceAccount{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("3 service account should be deleted, but get: %v", err)
+	}
+	if want := []string{"default/app"}; !slices.Equal(irsa.Status.ServiceAccounts, want) {
+		t.Fatalf("3 bound service accounts got: %v, want: %v", irsa.Status.ServiceAccounts, want)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_serviceAccountCanBeAdoptedByIrsa(t *testing.T) {
+	roleArn := "arn:aws:iam::000000000000:role/mock-role"
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Status: irsav1alpha1.IamRoleServiceAccountStatus{
+			RoleArn:   roleArn,
+			Condition: irsav1alpha1.IrsaOK,
+		},
+	}
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "Helm",
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa, sa)
+
+	// 1. existing service account should not be adopted by default
+	err := r.reconcileServiceAccount(context.Background(), irsa, true)
+	if err != ErrServiceAccountConflict {
+		t.Fatalf("1 service account exists should get conflict err, but get: %v", err)
+	}
+
+	// 2. existing service account should be adopted in adoption mode
+	irsa.Spec.ServiceAccount = &irsav1alpha1.ServiceAccountSpec{Adopt: true}
+	if err := r.reconcileServiceAccount(context.Background(), irsa, false); err != nil {
+		t.Fatalf("2 reconcileServiceAccount failed: %v", err)
+	}
+	gotSA := &corev1.ServiceAccount{}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: sa.GetNamespace(), Name: sa.GetName()}, gotSA); err != nil {
+		t.Fatalf("2 get sa failed: %v", err)
+	}
+	if r.serviceAccountNameIsOwnedByIrsa(gotSA, irsa) || len(gotSA.OwnerReferences) != 0 {
+		t.Fatalf("2 adopted service account should not be owned by irsa")
+	}
+	if gotSA.Annotations[irsaAnnotationKey] != roleArn || gotSA.Annotations[irsaAdoptedByAnnotationKey] != "default/irsa" {
+		t.Fatalf("2 adopted service account should be annotated, but got: %v", gotSA.Annotations)
+	}
+
+	// 3. service account adopted by other irsa cannot be adopted
+	other := irsa.DeepCopy()
+	other.Name = "other"
+	if r.serviceAccountCanBeAdoptedByIrsa(gotSA, other) {
+		t.Fatalf("3 service account adopted by other irsa should not be adopted")
+	}
+
+	// 4. adopted service account should be released but not deleted
+	if err := r.deleteServiceAccount(context.Background(), irsa); err != nil {
+		t.Fatalf("4 deleteServiceAccount failed: %v", err)
+	}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: sa.GetNamespace(), Name: sa.GetName()}, gotSA); err != nil {
+		t.Fatalf("4 adopted service account should not be deleted, but get: %v", err)
+	}
+	if _, ok := gotSA.Annotations[irsaAnnotationKey]; ok {
+		t.Fatalf("4 role annotation of adopted service account should be removed, but got: %v", gotSA.Annotations)
+	}
+	if gotSA.Labels["app.kubernetes.io/managed-by"] != "Helm" {
+		t.Fatalf("4 labels of adopted service account should be kept, but got: %v", gotSA.Labels)
+	}
+}
+
+func Test_applyServiceAccountTemplate(t *testing.T) {
+	roleArn := "arn:aws:iam::000000000000:role/mock-role"
+	automount := false
+	regional := true
+	expiration := int64(3600)
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			ServiceAccountTemplate: &irsav1alpha1.ServiceAccountTemplateSpec{
+				Labels: map[string]string{
+					"team": "a",
+				},
+				Annotations: map[string]string{
+					"key":             "value",
+					irsaAnnotationKey: "overridden",
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{
+					{Name: "registry"},
+				},
+				AutomountServiceAccountToken: &automount,
+				StsRegionalEndpoints:         &regional,
+				TokenExpiration:              &expiration,
+				Audience:                     "sts.amazonaws.com",
+			},
+		},
+		Status: irsav1alpha1.IamRoleServiceAccountStatus{
+			RoleArn:   roleArn,
+			Condition: irsav1alpha1.IrsaOK,
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa)
+	key := types.NamespacedName{Namespace: irsa.GetNamespace(), Name: irsa.GetName()}
+	check := func(step string) {
+		sa := &corev1.ServiceAccount{}
+		if err := r.Get(context.Background(), key, sa); err != nil {
+			t.Fatalf("%s get sa failed: %v", step, err)
+		}
+		wantAnnotations := map[string]string{
+			"key":                             "value",
+			irsaAnnotationKey:                 roleArn,
+			stsRegionalEndpointsAnnotationKey: "true",
+			tokenExpirationAnnotationKey:      "3600",
+			audienceAnnotationKey:             "sts.amazonaws.com",
+		}
+		if !reflect.DeepEqual(sa.Annotations, wantAnnotations) {
+			t.Fatalf("%s annotations got: %v, want: %v", step, sa.Annotations, wantAnnotations)
+		}
+		if sa.Labels["team"] != "a" || len(sa.ImagePullSecrets) != 1 || sa.ImagePullSecrets[0].Name != "registry" {
+			t.Fatalf("%s service account is not set by template: %v", step, sa)
+		}
+		if sa.AutomountServiceAccountToken == nil || *sa.AutomountServiceAccountToken {
+			t.Fatalf("%s automountServiceAccountToken should be false", step)
+		}
+	}
+
+	// 1. service account should be created by template
+	if err := r.reconcileServiceAccount(context.Background(), irsa, false); err != nil {
+		t.Fatalf("1 reconcileServiceAccount failed: %v", err)
+	}
+	check("1")
+
+	// 2. drift should be corrected
+	sa := &corev1.ServiceAccount{}
+	if err := r.Get(context.Background(), key, sa); err != nil {
+		t.Fatalf("2 get sa failed: %v", err)
+	}
+	sa.Labels["team"] = "b"
+	sa.Annotations[tokenExpirationAnnotationKey] = "86400"
+	sa.ImagePullSecrets = nil
+	sa.AutomountServiceAccountToken = nil
+	if err := r.Update(context.Background(), sa); err != nil {
+		t.Fatalf("2 update sa failed: %v", err)
+	}
+	if err := r.reconcileServiceAccount(context.Background(), irsa, false); err != nil {
+		t.Fatalf("2 reconcileServiceAccount failed: %v", err)
+	}
+	check("2")
+}
+
 func TestIamRoleServiceAccountReconciler_finalize(t *testing.T) {
 	irsa := &irsav1alpha1.IamRoleServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
@@ -484,3 +728,1233 @@ func TestIamRoleServiceAccountReconciler_reconcile(t *testing.T) {
 		t.Fatalf("4 irsa status should be updated to ok, but got %v", irsa.Status.Condition)
 	}
 }
+
+func TestIamRoleServiceAccountReconciler_reconcileRoleOnly(t *testing.T) {
+	create := false
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			ServiceAccounts: []irsav1alpha1.ServiceAccountRef{
+				{Name: "app", Namespace: "other-cluster-namespace"},
+			},
+			ServiceAccount: &irsav1alpha1.ServiceAccountSpec{
+				Create: &create,
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa)
+
+	// the namespace of service account must trust irsa even if it is not created
+	if _, err := r.resolveIrsa(context.Background(), irsa); !gerrors.Is(err, ErrServiceAccountForbidden) {
+		t.Fatalf("resolveIrsa should get forbidden err if namespace does not trust irsa, but get: %v", err)
+	}
+	if err := r.Create(context.Background(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "other-cluster-namespace",
+		Annotations: map[string]string{trustedNamespacesAnnotationKey: "default"},
+	}}); err != nil {
+		t.Fatalf("create namespace failed: %v", err)
+	}
+
+	// submitted -> pending -> progressing -> synced
+	for i := 0; i < 3; i++ {
+		if _, err := r.reconcile(context.Background(), irsa); err != nil {
+			t.Fatalf("%d reconcile failed: %v", i, err)
+		}
+	}
+	if irsa.Status.Condition != v1alpha1.IrsaOK || irsa.Status.RoleArn == "" {
+		t.Fatalf("irsa should be synced with role arn, but got %v", irsa.Status)
+	}
+	if _, err := r.reconcile(context.Background(), irsa); err != nil {
+		t.Fatalf("reconcile synced irsa failed: %v", err)
+	}
+
+	role, err := r.iamRoleClient.Get(context.Background(), aws.RoleNameByArn(irsa.Status.RoleArn))
+	if err != nil {
+		t.Fatalf("get iam role failed: %v", err)
+	}
+	if !role.AssumeRolePolicy.IsAllowOIDC("test", aws.DefaultAudience, "other-cluster-namespace", "app") {
+		t.Fatalf("role should be assumed by the configured service account, but not")
+	}
+	var sas corev1.ServiceAccountList
+	if err := r.List(context.Background(), &sas); err != nil {
+		t.Fatalf("list service accounts failed: %v", err)
+	}
+	if len(sas.Items) != 0 || len(irsa.Status.ServiceAccounts) != 0 {
+		t.Fatalf("service accounts should not be created in role-only mode, but got: %v", sas.Items)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_reconcileOutputs(t *testing.T) {
+	roleArn := "arn:aws:iam::000000000000:role/mock-role"
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Outputs: []irsav1alpha1.OutputSpec{
+				{Name: "irsa-outputs"},
+				{Kind: irsav1alpha1.OutputSecret, Name: "irsa-outputs", Keys: &irsav1alpha1.OutputKeysSpec{RoleArn: "ROLE_ARN"}},
+			},
+		},
+		Status: irsav1alpha1.IamRoleServiceAccountStatus{
+			RoleArn:   roleArn,
+			Condition: irsav1alpha1.IrsaOK,
+		},
+	}
+	conflict := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "conflict",
+			Namespace: "default",
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa, conflict)
+	key := types.NamespacedName{Namespace: "default", Name: "irsa-outputs"}
+
+	// 1. outputs should be published
+	if err := r.reconcileOutputs(context.Background(), irsa); err != nil {
+		t.Fatalf("1 reconcileOutputs failed: %v", err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), key, cm); err != nil {
+		t.Fatalf("1 get config map failed: %v", err)
+	}
+	wantData := map[string]string{
+		"roleArn":      roleArn,
+		"roleName":     "mock-role",
+		"accountID":    "000000000000",
+		"oidcProvider": "test",
+	}
+	if !reflect.DeepEqual(cm.Data, wantData) || !metav1.IsControlledBy(cm, irsa) {
+		t.Fatalf("1 config map got: %v, want: %v", cm.Data, wantData)
+	}
+	secret := &corev1.Secret{}
+	if err := r.Get(context.Background(), key, secret); err != nil {
+		t.Fatalf("1 get secret failed: %v", err)
+	}
+	if string(secret.Data["ROLE_ARN"]) != roleArn || string(secret.Data["accountID"]) != "000000000000" {
+		t.Fatalf("1 secret got: %v", secret.Data)
+	}
+
+	// 2. drift should be corrected
+	cm.Data["roleArn"] = "changed"
+	if err := r.Update(context.Background(), cm); err != nil {
+		t.Fatalf("2 update config map failed: %v", err)
+	}
+	if err := r.reconcileOutputs(context.Background(), irsa); err != nil {
+		t.Fatalf("2 reconcileOutputs failed: %v", err)
+	}
+	if err := r.Get(context.Background(), key, cm); err != nil {
+		t.Fatalf("2 get config map failed: %v", err)
+	}
+	if !reflect.DeepEqual(cm.Data, wantData) {
+		t.Fatalf("2 config map got: %v, want: %v", cm.Data, wantData)
+	}
+
+	// 3. existing config map not created by irsa-controller cannot be used
+	irsa.Spec.Outputs = append(irsa.Spec.Outputs, irsav1alpha1.OutputSpec{Name: "conflict"})
+	if err := r.reconcileOutputs(context.Background(), irsa); !gerrors.Is(err, ErrOutputConflict) {
+		t.Fatalf("3 reconcileOutputs should get conflict err, but get: %v", err)
+	}
+
+	// 4. outputs no longer defined should be deleted
+	irsa.Spec.Outputs = irsa.Spec.Outputs[:1]
+	if err := r.reconcileOutputs(context.Background(), irsa); err != nil {
+		t.Fatalf("4 reconcileOutputs failed: %v", err)
+	}
+	if err := r.Get(context.Background(), key, secret); !errors.IsNotFound(err) {
+		t.Fatalf("4 secret should be deleted, but get: %v", err)
+	}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "conflict"}, cm); err != nil {
+		t.Fatalf("4 config map not created by irsa-controller should be kept, but get: %v", err)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_resolveIrsa(t *testing.T) {
+	doc := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Policy: &irsav1alpha1.PolicySpec{
+				InlinePolicyFrom: &irsav1alpha1.InlinePolicySource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "policy"},
+						Key:                  "policy.json",
+					},
+				},
+			},
+		},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"policy.json": doc,
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa, cm)
+
+	// 1. inline policy should be resolved from config map
+	resolved, err := r.resolveIrsa(context.Background(), irsa)
+	if err != nil {
+		t.Fatalf("1 resolveIrsa failed: %v", err)
+	}
+	if resolved.Spec.Policy.InlinePolicyDocument != doc || resolved.Spec.Policy.InlinePolicyFrom != nil || irsa.Spec.Policy.InlinePolicyDocument != "" {
+		t.Fatalf("1 inline policy should be resolved into a copy of irsa, but got: %v", resolved.Spec.Policy)
+	}
+	if reqs := r.mapConfigMapToIrsa(cm); len(reqs) != 1 || reqs[0].Name != irsa.GetName() {
+		t.Fatalf("1 config map should be mapped to irsa, but got: %v", reqs)
+	}
+
+	// 2. invalid document should be rejected
+	cm.Data["policy.json"] = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Resource":"*"}]}`
+	if err := r.Update(context.Background(), cm); err != nil {
+		t.Fatalf("2 update config map failed: %v", err)
+	}
+	if _, err := r.resolveIrsa(context.Background(), irsa); err == nil {
+		t.Fatalf("2 invalid inline policy document should be rejected")
+	}
+
+	// 3. only one inline policy source can be set
+	irsa.Spec.Policy.InlinePolicyDocument = doc
+	if _, err := r.resolveIrsa(context.Background(), irsa); err != ErrInlinePolicySourceConflict {
+		t.Fatalf("3 resolveIrsa should get source conflict err, but get: %v", err)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_syncNamedInlinePolicies(t *testing.T) {
+	statement := []irsav1alpha1.StatementSpec{
+		{
+			Resource: []string{"*"},
+			Action:   []string{"s3:GetObject"},
+			Effect:   "Allow",
+		},
+	}
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Policy: &irsav1alpha1.PolicySpec{
+				InlinePolicies: []irsav1alpha1.NamedInlinePolicySpec{
+					{Name: "s3", InlinePolicySpec: irsav1alpha1.InlinePolicySpec{Statement: statement}},
+					{Name: "sqs", InlinePolicySpec: irsav1alpha1.InlinePolicySpec{Statement: statement}},
+				},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa)
+
+	// 1. named inline policies should be created with iam role
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("1 create external resource failed: %v", err)
+	}
+	role, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("1 get iam role failed: %v", err)
+	}
+	if len(role.InlinePolicies) != 2 || role.InlinePolicies["s3"] == nil || role.InlinePolicies["sqs"] == nil || role.InlinePolicy != nil {
+		t.Fatalf("1 role should have two named inline policies, but got: %v", role.InlinePolicies)
+	}
+
+	// 2. removed named inline policy should be deleted and changed one should be updated
+	irsa.Spec.Policy.InlinePolicies = irsa.Spec.Policy.InlinePolicies[:1]
+	irsa.Spec.Policy.InlinePolicies[0].Statement = append(statement, irsav1alpha1.StatementSpec{
+		Resource: []string{"*"},
+		Action:   []string{"s3:PutObject"},
+		Effect:   "Allow",
+	})
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("2 update external resource failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("2 get iam role failed: %v", err)
+	}
+	if len(role.InlinePolicies) != 1 || role.InlinePolicies["s3"] == nil || len(role.InlinePolicies["s3"].Statement) != 2 {
+		t.Fatalf("2 role should have one updated named inline policy, but got: %v", role.InlinePolicies)
+	}
+
+	// 3. name of default inline policy cannot be used
+	irsa.Spec.Policy.InlinePolicies[0].Name = r.iamRoleClient.InlinePolicyName(r.iamRoleClient.RoleName(irsa))
+	if _, err := r.resolveIrsa(context.Background(), irsa); err != ErrInlinePolicyNameConflict {
+		t.Fatalf("3 resolveIrsa should get name conflict err, but get: %v", err)
+	}
+
+	// 4. name of inline policies should be unique
+	irsa.Spec.Policy.InlinePolicies = append(irsa.Spec.Policy.InlinePolicies, irsa.Spec.Policy.InlinePolicies[0])
+	irsa.Spec.Policy.InlinePolicies[0].Name = "s3"
+	irsa.Spec.Policy.InlinePolicies[1].Name = "s3"
+	if _, err := r.resolveIrsa(context.Background(), irsa); err != ErrInlinePolicyNameConflict {
+		t.Fatalf("4 resolveIrsa should get name conflict err, but get: %v", err)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_oversizedInlinePolicy(t *testing.T) {
+	var statement []irsav1alpha1.StatementSpec
+	for i := 0; i < 5; i++ {
+		statement = append(statement, irsav1alpha1.StatementSpec{
+			Resource: []string{fmt.Sprintf("arn:aws:s3:::%d-%s", i, strings.Repeat("a", 2900))},
+			Action:   []string{"s3:GetObject"},
+			Effect:   "Allow",
+		})
+	}
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Policy: &irsav1alpha1.PolicySpec{
+				InlinePolicy: &irsav1alpha1.InlinePolicySpec{
+					Version:   "2012-10-17",
+					Statement: statement,
+				},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa)
+
+	// 1. oversized inline policy should fail early with size and limit
+	err := r.createExternalResources(context.Background(), irsa)
+	var sizeErr *aws.PolicySizeExceededError
+	if !gerrors.As(err, &sizeErr) || sizeErr.Limit != aws.InlinePoliciesSizeLimit || sizeErr.Size <= sizeErr.Limit {
+		t.Fatalf("1 create external resource should get size exceeded err, but get: %v", err)
+	}
+	if _, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa)); !aws.ErrIsNotFound(err) {
+		t.Fatalf("1 iam role should not be created, but get: %v", err)
+	}
+
+	// 2. statements should be split into owned managed policies
+	irsa.Spec.Policy.OversizeStrategy = irsav1alpha1.OversizeSplitManagedPolicies
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("2 create external resource failed: %v", err)
+	}
+	role, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("2 get iam role failed: %v", err)
+	}
+	if role.InlinePolicy != nil || len(role.OwnedPolicies) != 3 || len(role.ManagedPolicies) != 0 {
+		t.Fatalf("2 inline policy should be split into 3 managed policies, but got: %v", role.OwnedPolicies)
+	}
+
+	// 3. owned managed policies should be updated and pruned
+	irsa.Spec.Policy.InlinePolicy.Statement = append([]irsav1alpha1.StatementSpec{}, statement[:4]...)
+	irsa.Spec.Policy.InlinePolicy.Statement[3].Action = []string{"s3:PutObject"}
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("3 update external resource failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("3 get iam role failed: %v", err)
+	}
+	if role.InlinePolicy != nil || len(role.OwnedPolicies) != 2 || role.OwnedPolicies[1].Statement[1].Action[0] != "s3:PutObject" {
+		t.Fatalf("3 inline policy should be split into 2 managed policies, but got: %v", role.OwnedPolicies)
+	}
+
+	// 4. statements should be moved back to inline policy if it is not oversized
+	irsa.Spec.Policy.InlinePolicy.Statement = statement[:1]
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("4 update external resource failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("4 get iam role failed: %v", err)
+	}
+	if role.InlinePolicy == nil || len(role.OwnedPolicies) != 0 {
+		t.Fatalf("4 managed policies should be moved back to inline policy, but got: %v", role.OwnedPolicies)
+	}
+	if _, err := mic.GetPolicyWithContext(context.Background(), &iam.GetPolicyInput{
+		PolicyArn: goAws.String(r.iamRoleClient.OwnedPolicyArn(role.RoleArn, 0)),
+	}); !aws.ErrIsNotFound(err) {
+		t.Fatalf("4 owned managed policy should be deleted, but get: %v", err)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_managedPolicyMode(t *testing.T) {
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Policy: &irsav1alpha1.PolicySpec{
+				Mode: irsav1alpha1.PolicyModeManaged,
+				InlinePolicy: &irsav1alpha1.InlinePolicySpec{
+					Version: "2012-10-17",
+					Statement: []irsav1alpha1.StatementSpec{
+						{
+							Resource: []string{"*"},
+							Action:   []string{"s3:GetObject"},
+							Effect:   "Allow",
+						},
+					},
+				},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa)
+
+	// 1. statements should be created as an owned managed policy
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("1 create external resource failed: %v", err)
+	}
+	role, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("1 get iam role failed: %v", err)
+	}
+	if role.InlinePolicy != nil || len(role.OwnedPolicies) != 1 {
+		t.Fatalf("1 statements should be created as a managed policy, but got: %v", role.OwnedPolicies)
+	}
+	policyArn := r.iamRoleClient.OwnedPolicyArn(role.RoleArn, 0)
+
+	// 2. new versions should be created on change, and old versions should be pruned
+	for i := 0; i < 7; i++ {
+		irsa.Spec.Policy.InlinePolicy.Statement[0].Action = []string{fmt.Sprintf("s3:Action%d", i)}
+		if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+			t.Fatalf("2 update external resource %d failed: %v", i, err)
+		}
+	}
+	versions, err := mic.ListPolicyVersionsWithContext(context.Background(), &iam.ListPolicyVersionsInput{
+		PolicyArn: goAws.String(policyArn),
+	})
+	if err != nil {
+		t.Fatalf("2 list policy versions failed: %v", err)
+	}
+	if len(versions.Versions) != 5 || *versions.Versions[len(versions.Versions)-1].VersionId != "v8" {
+		t.Fatalf("2 policy should keep the latest 5 versions, but got: %v", versions.Versions)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("2 get iam role failed: %v", err)
+	}
+	if role.OwnedPolicies[0].Statement[0].Action[0] != "s3:Action6" {
+		t.Fatalf("2 default version of policy should be the latest, but got: %v", role.OwnedPolicies[0])
+	}
+
+	// 3. statements should be moved back to inline policy in inline mode
+	irsa.Spec.Policy.Mode = irsav1alpha1.PolicyModeInline
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("3 update external resource failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("3 get iam role failed: %v", err)
+	}
+	if role.InlinePolicy == nil || len(role.OwnedPolicies) != 0 {
+		t.Fatalf("3 statements should be moved back to inline policy, but got: %v", role.OwnedPolicies)
+	}
+	if _, err := mic.GetPolicyWithContext(context.Background(), &iam.GetPolicyInput{PolicyArn: goAws.String(policyArn)}); !aws.ErrIsNotFound(err) {
+		t.Fatalf("3 owned managed policy should be deleted, but get: %v", err)
+	}
+
+	// 4. policies named like owned policies but not tagged as owned should never be taken or deleted
+	roleName := r.iamRoleClient.RoleName(irsa)
+	policyDocument := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["*"]}]}`
+	var userPolicyArns []string
+	for _, idx := range []int{0, 99999999} {
+		policyOut, err := mic.CreatePolicyWithContext(context.Background(), &iam.CreatePolicyInput{
+			PolicyName:     goAws.String(r.iamRoleClient.OwnedPolicyName(roleName, idx)),
+			PolicyDocument: goAws.String(policyDocument),
+		})
+		if err != nil {
+			t.Fatalf("4 create user policy %d failed: %v", idx, err)
+		}
+		if _, err := mic.AttachRolePolicyWithContext(context.Background(), &iam.AttachRolePolicyInput{
+			RoleName:  goAws.String(roleName),
+			PolicyArn: policyOut.Policy.Arn,
+		}); err != nil {
+			t.Fatalf("4 attach user policy %d failed: %v", idx, err)
+		}
+		userPolicyArns = append(userPolicyArns, *policyOut.Policy.Arn)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), roleName)
+	if err != nil {
+		t.Fatalf("4 get iam role failed: %v", err)
+	}
+	if len(role.OwnedPolicies) != 0 || len(role.ManagedPolicies) != len(userPolicyArns) {
+		t.Fatalf("4 user policies should be reported as managed policies, but got owned: %v, managed: %v", role.OwnedPolicies, role.ManagedPolicies)
+	}
+	if err := r.iamRoleClient.Delete(context.Background(), role.RoleArn); err != nil {
+		t.Fatalf("4 delete iam role failed: %v", err)
+	}
+	for _, arn := range userPolicyArns {
+		if _, err := mic.GetPolicyWithContext(context.Background(), &iam.GetPolicyInput{PolicyArn: goAws.String(arn)}); err != nil {
+			t.Fatalf("4 user policy %s should not be deleted, but get: %v", arn, err)
+		}
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_checkManagedPolicies(t *testing.T) {
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Policy: &irsav1alpha1.PolicySpec{
+				ManagedPolicies: []string{"AmazonS3ReadOnlyAccess", "service-role/AmazonEC2RoleforSSM"},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa)
+	customPolicy, err := mic.CreatePolicyWithContext(context.Background(), &iam.CreatePolicyInput{
+		PolicyName:     goAws.String("custom"),
+		PolicyDocument: goAws.String(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"*","Resource":"*"}]}`),
+	})
+	if err != nil {
+		t.Fatalf("Create custom policy failed: %v", err)
+	}
+
+	// 1. names of aws managed policies should be resolved to the arns in the partition of oidc provider
+	r.oidc = "arn:aws-cn:iam::000000000000:oidc-provider/test"
+	resolved, err := r.resolveIrsa(context.Background(), irsa)
+	if err != nil {
+		t.Fatalf("1 resolveIrsa failed: %v", err)
+	}
+	want := []string{"arn:aws-cn:iam::aws:policy/AmazonS3ReadOnlyAccess", "arn:aws-cn:iam::aws:policy/service-role/AmazonEC2RoleforSSM"}
+	if !reflect.DeepEqual(resolved.Spec.Policy.ManagedPolicies, want) {
+		t.Fatalf("1 managed policies should be resolved to %v, but got: %v", want, resolved.Spec.Policy.ManagedPolicies)
+	}
+
+	// 2. nonexistent managed policies should be reported before creating iam role
+	irsa.Spec.Policy.ManagedPolicies = []string{"AmazonS3ReadOnlyAccess", *customPolicy.Policy.Arn}
+	err = r.createExternalResources(context.Background(), irsa)
+	if !gerrors.Is(err, ErrManagedPolicyInvalid) {
+		t.Fatalf("2 create external resources should get invalid managed policy err, but get: %v", err)
+	}
+	if len(irsa.Status.ManagedPolicyErrors) != 1 || irsa.Status.ManagedPolicyErrors[0].PolicyArn != "arn:aws-cn:iam::aws:policy/AmazonS3ReadOnlyAccess" {
+		t.Fatalf("2 errors of managed policies should be recorded in status, but got: %v", irsa.Status.ManagedPolicyErrors)
+	}
+	if _, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa)); !aws.ErrIsNotFound(err) {
+		t.Fatalf("2 iam role should not be created, but get: %v", err)
+	}
+
+	// 3. existing managed policies should be attached
+	irsa.Spec.Policy.ManagedPolicies = []string{*customPolicy.Policy.Arn}
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("3 create external resources failed: %v", err)
+	}
+	if len(irsa.Status.ManagedPolicyErrors) != 0 {
+		t.Fatalf("3 errors of managed policies should be cleaned, but got: %v", irsa.Status.ManagedPolicyErrors)
+	}
+
+	// 4. nonexistent managed policies should not be attached when updating
+	irsa.Spec.Policy.ManagedPolicies = append(irsa.Spec.Policy.ManagedPolicies, "AmazonS3ReadOnlyAccess")
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); !gerrors.Is(err, ErrManagedPolicyInvalid) {
+		t.Fatalf("4 update external resources should get invalid managed policy err, but get: %v", err)
+	}
+	role, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("4 get iam role failed: %v", err)
+	}
+	if !reflect.DeepEqual(role.ManagedPolicies, []string{*customPolicy.Policy.Arn}) {
+		t.Fatalf("4 iam role should not be attached with nonexistent policy, but got: %v", role.ManagedPolicies)
+	}
+
+	// 5. errors of managed policies should be cleaned once the nonexistent policy is removed, even if only the inline policy changes
+	irsa.Spec.Policy.ManagedPolicies = []string{*customPolicy.Policy.Arn}
+	irsa.Spec.Policy.InlinePolicy = &irsav1alpha1.InlinePolicySpec{
+		Version: "2012-10-17",
+		Statement: []irsav1alpha1.StatementSpec{
+			{Resource: []string{"*"}, Action: []string{"s3:ListAllMyBuckets"}, Effect: "Allow"},
+		},
+	}
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("5 update external resources failed: %v", err)
+	}
+	if len(irsa.Status.ManagedPolicyErrors) != 0 {
+		t.Fatalf("5 errors of managed policies should be cleaned, but got: %v", irsa.Status.ManagedPolicyErrors)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_policyVariables(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "default",
+			Labels: map[string]string{"team": "data"},
+		},
+	}
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Tags: map[string]string{"team": "${namespace.labels.team}"},
+			Policy: &irsav1alpha1.PolicySpec{
+				InlinePolicy: &irsav1alpha1.InlinePolicySpec{
+					Version: "2012-10-17",
+					Statement: []irsav1alpha1.StatementSpec{
+						{
+							Resource: []string{"arn:aws:s3:::${cluster}-${namespace}/${labels.missing}"},
+							Action:   []string{"s3:GetObject"},
+							Effect:   "Allow",
+						},
+					},
+				},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, ns, irsa)
+
+	// 1. unresolved variables should be rejected
+	if _, err := r.resolveIrsa(context.Background(), irsa); err == nil {
+		t.Fatalf("1 resolveIrsa should failed with unresolved variables")
+	}
+
+	// 2. variables should be rendered in the iam role
+	irsa.Spec.Policy.InlinePolicy.Statement[0].Resource = []string{"arn:aws:s3:::${cluster}-${namespace}/*"}
+	if _, err := r.resolveIrsa(context.Background(), irsa); err != nil {
+		t.Fatalf("2 resolveIrsa failed: %v", err)
+	}
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("2 create external resources failed: %v", err)
+	}
+	role, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("2 get iam role failed: %v", err)
+	}
+	if got := role.InlinePolicy.Statement[0].Resource[0]; got != "arn:aws:s3:::test-default/*" {
+		t.Fatalf("2 variables in statement should be rendered, but got: %v", got)
+	}
+	if role.Tags["team"] != "data" {
+		t.Fatalf("2 variables in tags should be rendered, but got: %v", role.Tags)
+	}
+
+	// 3. iam role should not be updated again after rendering
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("3 update external resources failed: %v", err)
+	}
+	got, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("3 get iam role failed: %v", err)
+	}
+	if !reflect.DeepEqual(got.InlinePolicy, role.InlinePolicy) {
+		t.Fatalf("3 inline policy should not be changed, but got: %v", got.InlinePolicy)
+	}
+
+	// 4. namespace should be mapped to its irsa
+	if reqs := r.mapNamespaceToIrsa(ns); len(reqs) != 1 || reqs[0].Name != irsa.GetName() {
+		t.Fatalf("4 namespace should be mapped to irsa, but got: %v", reqs)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_policyTemplates(t *testing.T) {
+	tpl := &irsav1alpha1.ClusterPolicyTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "sqs-consumer"},
+		Spec: irsav1alpha1.ClusterPolicyTemplateSpec{
+			Parameters: []irsav1alpha1.TemplateParameter{
+				{Name: "queue", Required: true},
+			},
+			Statement: []irsav1alpha1.StatementSpec{
+				{
+					Resource: []string{"arn:aws:sqs:*:*:${namespace}-${params.queue}"},
+					Action:   []string{"sqs:ReceiveMessage"},
+					Effect:   "Allow",
+				},
+			},
+		},
+	}
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Policy: &irsav1alpha1.PolicySpec{
+				InlinePolicy: &irsav1alpha1.InlinePolicySpec{
+					Version: "2012-10-17",
+					Statement: []irsav1alpha1.StatementSpec{
+						{
+							Resource: []string{"*"},
+							Action:   []string{"s3:ListAllMyBuckets"},
+							Effect:   "Allow",
+						},
+					},
+				},
+				Templates: []irsav1alpha1.PolicyTemplateRef{
+					{Name: tpl.GetName()},
+				},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, tpl, irsa)
+
+	// 1. required arguments should be checked
+	if _, err := r.resolveIrsa(context.Background(), irsa); err == nil {
+		t.Fatalf("1 resolveIrsa should failed without required arguments")
+	}
+
+	// 2. templates cannot be used with raw json policy
+	irsa.Spec.Policy.Templates[0].Arguments = []irsav1alpha1.TemplateArgument{{Name: "queue", TemplateValue: irsav1alpha1.TemplateValue{Value: "jobs"}}}
+	withDocument := irsa.DeepCopy()
+	withDocument.Spec.Policy.InlinePolicy = nil
+	withDocument.Spec.Policy.InlinePolicyDocument = `{"Version":"2012-10-17","Statement":[]}`
+	if _, err := r.resolveIrsa(context.Background(), withDocument); !gerrors.Is(err, ErrPolicyTemplateSourceConflict) {
+		t.Fatalf("2 resolveIrsa should get template source conflict err, but get: %v", err)
+	}
+
+	// 3. rendered statements should be merged into the inline policy
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("3 create external resources failed: %v", err)
+	}
+	role, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("3 get iam role failed: %v", err)
+	}
+	if len(role.InlinePolicy.Statement) != 2 || role.InlinePolicy.Statement[1].Resource[0] != "arn:aws:sqs:*:*:default-jobs" {
+		t.Fatalf("3 template should be merged into inline policy, but got: %v", role.InlinePolicy.Statement)
+	}
+	if len(irsa.Spec.Policy.InlinePolicy.Statement) != 1 {
+		t.Fatalf("3 spec of irsa should not be modified, but got: %v", irsa.Spec.Policy.InlinePolicy.Statement)
+	}
+
+	// 4. changes of template should be synced to dependents
+	if reqs := r.mapClusterPolicyTemplateToIrsa(tpl); len(reqs) != 1 || reqs[0].Name != irsa.GetName() {
+		t.Fatalf("4 template should be mapped to irsa, but got: %v", reqs)
+	}
+	tpl.Spec.Statement[0].Action = append(tpl.Spec.Statement[0].Action, "sqs:DeleteMessage")
+	if err := r.Update(context.Background(), tpl); err != nil {
+		t.Fatalf("4 update template failed: %v", err)
+	}
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("4 update external resources failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("4 get iam role failed: %v", err)
+	}
+	if !reflect.DeepEqual(role.InlinePolicy.Statement[1].Action, aws.StringList{"sqs:ReceiveMessage", "sqs:DeleteMessage"}) {
+		t.Fatalf("4 inline policy should be updated, but got: %v", role.InlinePolicy.Statement[1].Action)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_access(t *testing.T) {
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessS3, Mode: irsav1alpha1.AccessReadWrite, Bucket: "${namespace}-data"},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa)
+
+	// 1. access should be compiled into the inline policy without policy spec
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("1 create external resources failed: %v", err)
+	}
+	role, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("1 get iam role failed: %v", err)
+	}
+	if role.InlinePolicy == nil || len(role.InlinePolicy.Statement) != 2 || role.InlinePolicy.Statement[1].Resource[0] != "arn:aws:s3:::default-data/*" {
+		t.Fatalf("1 access should be compiled into inline policy, but got: %v", role.InlinePolicy)
+	}
+	if len(irsa.Status.AccessStatements) != 2 {
+		t.Fatalf("1 access statements should be shown in status, but got: %v", irsa.Status.AccessStatements)
+	}
+
+	// 2. access cannot be used with raw json policy
+	withDocument := irsa.DeepCopy()
+	withDocument.Spec.Policy = &irsav1alpha1.PolicySpec{InlinePolicyDocument: `{"Version":"2012-10-17","Statement":[]}`}
+	if _, err := r.resolveIrsa(context.Background(), withDocument); !gerrors.Is(err, ErrAccessSourceConflict) {
+		t.Fatalf("2 resolveIrsa should get access source conflict err, but get: %v", err)
+	}
+
+	// 3. status should be updated when irsa is still ok
+	irsa.Status.Condition = irsav1alpha1.IrsaOK
+	if err := r.Status().Update(context.Background(), irsa); err != nil {
+		t.Fatalf("3 update status failed: %v", err)
+	}
+	irsa.Spec.Access[0].Mode = irsav1alpha1.AccessRead
+	if _, err := r.resolveIrsa(context.Background(), irsa); err != nil {
+		t.Fatalf("3 resolveIrsa failed: %v", err)
+	}
+	if !r.updateIrsaStatus(context.Background(), irsa, irsav1alpha1.IrsaOK, nil) {
+		t.Fatalf("3 status should be updated")
+	}
+	var got irsav1alpha1.IamRoleServiceAccount
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(irsa), &got); err != nil {
+		t.Fatalf("3 get irsa failed: %v", err)
+	}
+	if !reflect.DeepEqual(got.Status.AccessStatements[1].Action, []string{"s3:GetObject"}) {
+		t.Fatalf("3 access statements should be updated, but got: %v", got.Status.AccessStatements)
+	}
+	if r.updateIrsaStatus(context.Background(), irsa, irsav1alpha1.IrsaOK, nil) {
+		t.Fatalf("3 status should not be updated again")
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_resourceFrom(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "default"},
+		Data:       map[string]string{"arn": "arn:aws:s3:::bucket-a/*"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "queue", Namespace: "default"},
+		Data:       map[string][]byte{"arn": []byte("arn:aws:sqs:us-east-1:000000000000:queue-a, arn:aws:sqs:us-east-1:000000000000:queue-b")},
+	}
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Policy: &irsav1alpha1.PolicySpec{
+				InlinePolicy: &irsav1alpha1.InlinePolicySpec{
+					Version: "2012-10-17",
+					Statement: []irsav1alpha1.StatementSpec{
+						{
+							ResourceFrom: []irsav1alpha1.ResourceSource{{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: cm.GetName()}, Key: "arn"}}},
+							Action:       []string{"s3:GetObject"},
+							Effect:       "Allow",
+						},
+						{
+							ResourceFrom: []irsav1alpha1.ResourceSource{{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: secret.GetName()}, Key: "arn"}}},
+							Action:       []string{"sqs:SendMessage"},
+							Effect:       "Allow",
+						},
+					},
+				},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, cm, secret, irsa)
+
+	// 1. resource arns should be read from config map and secret
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("1 create external resources failed: %v", err)
+	}
+	role, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("1 get iam role failed: %v", err)
+	}
+	if !reflect.DeepEqual(role.InlinePolicy.Statement[0].Resource, aws.StringList{"arn:aws:s3:::bucket-a/*"}) {
+		t.Fatalf("1 resource should be read from config map, but got: %v", role.InlinePolicy.Statement[0].Resource)
+	}
+	if len(role.InlinePolicy.Statement[1].Resource) != 2 {
+		t.Fatalf("1 resources should be read from secret, but got: %v", role.InlinePolicy.Statement[1].Resource)
+	}
+
+	// 2. referenced objects should be mapped to irsa
+	if reqs := r.mapConfigMapToIrsa(cm); len(reqs) != 1 {
+		t.Fatalf("2 config map should be mapped to irsa, but got: %v", reqs)
+	}
+	if reqs := r.mapSecretToIrsa(secret); len(reqs) != 1 {
+		t.Fatalf("2 secret should be mapped to irsa, but got: %v", reqs)
+	}
+	if reqs := r.mapSecretToIrsa(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: cm.GetName(), Namespace: "default"}}); len(reqs) != 0 {
+		t.Fatalf("2 secret with the name of config map should not be mapped to irsa, but got: %v", reqs)
+	}
+
+	// 3. policy should be updated when the referenced object is changed
+	cm.Data["arn"] = "arn:aws:s3:::bucket-b/*"
+	if err := r.Update(context.Background(), cm); err != nil {
+		t.Fatalf("3 update config map failed: %v", err)
+	}
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("3 update external resources failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("3 get iam role failed: %v", err)
+	}
+	if !reflect.DeepEqual(role.InlinePolicy.Statement[0].Resource, aws.StringList{"arn:aws:s3:::bucket-b/*"}) {
+		t.Fatalf("3 resource should be updated, but got: %v", role.InlinePolicy.Statement[0].Resource)
+	}
+
+	// 4. missing key should be reported
+	delete(cm.Data, "arn")
+	if err := r.Update(context.Background(), cm); err != nil {
+		t.Fatalf("4 update config map failed: %v", err)
+	}
+	if _, err := r.resolveIrsa(context.Background(), irsa); err == nil {
+		t.Fatalf("4 resolveIrsa should failed if the key is missing")
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_trustSubjectPatterns(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "team-a",
+			Annotations: map[string]string{trustedNamespacesAnnotationKey: "default"},
+		},
+	}
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Trust: &irsav1alpha1.TrustSpec{
+				SubjectPatterns: []string{"system:serviceaccount:team-a:*"},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, ns, irsa)
+
+	// 1. wildcard subjects should be forbidden by default
+	if _, err := r.resolveIrsa(context.Background(), irsa); !gerrors.Is(err, ErrWildcardSubjectForbidden) {
+		t.Fatalf("1 resolveIrsa should get wildcard subject forbidden err, but get: %v", err)
+	}
+
+	// 2. namespace of subject pattern should trust the namespace of irsa
+	r.allowWildcardSubjects = true
+	irsa.Spec.Trust.SubjectPatterns = []string{"system:serviceaccount:team-b:*"}
+	if _, err := r.resolveIrsa(context.Background(), irsa); err == nil {
+		t.Fatalf("2 resolveIrsa should failed if the namespace does not trust irsa")
+	}
+
+	// 3. role should trust the service accounts matched by subject pattern with audience
+	irsa.Spec.Trust.SubjectPatterns = []string{"system:serviceaccount:team-a:*"}
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("3 create external resources failed: %v", err)
+	}
+	role, err := r.iamRoleClient.Get(context.Background(), r.iamRoleClient.RoleName(irsa))
+	if err != nil {
+		t.Fatalf("3 get iam role failed: %v", err)
+	}
+	if !role.AssumeRolePolicy.IsAllowOIDC(r.oidc, aws.DefaultAudience, "team-a", "worker") || !role.AssumeRolePolicy.IsAllowOIDC(r.oidc, aws.DefaultAudience, irsa.GetNamespace(), irsa.GetName()) {
+		t.Fatalf("3 iam role should trust service accounts, but got: %v", role.AssumeRolePolicy)
+	}
+	if role.AssumeRolePolicy.IsAllowOIDC(r.oidc, aws.DefaultAudience, "team-b", "worker") {
+		t.Fatalf("3 iam role should not trust other namespaces")
+	}
+	for _, st := range role.AssumeRolePolicy.Statement {
+		if !reflect.DeepEqual(st.Condition["StringEquals"]["test:aud"], aws.ConditionValues{aws.DefaultAudience}) {
+			t.Fatalf("3 audience should be enforced, but got: %v", st.Condition)
+		}
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_trustAdditionalPrincipals(t *testing.T) {
+	ciRole := "arn:aws:iam::000000000000:role/ci"
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Trust: &irsav1alpha1.TrustSpec{
+				AdditionalPrincipals: []irsav1alpha1.TrustPrincipal{{AWS: ciRole, Service: "ec2.amazonaws.com"}},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa)
+	trustCI := func(doc *aws.AssumeRoleDocument) bool {
+		for _, st := range doc.Statement {
+			if st.Principal.AWS == ciRole && st.Action == aws.AssumeRoleAction {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 1. invalid principals should be rejected
+	if _, err := r.resolveIrsa(context.Background(), irsa); err == nil {
+		t.Fatalf("1 resolveIrsa should failed with invalid principal")
+	}
+	irsa.Spec.Trust.AdditionalPrincipals[0].Service = ""
+	if _, err := r.resolveIrsa(context.Background(), irsa); !gerrors.Is(err, ErrTrustPrincipalForbidden) {
+		t.Fatalf("1 resolveIrsa should get forbidden err if principal is not allowed, but get: %v", err)
+	}
+	r.allowedTrustPrincipals = []string{ciRole, "*"}
+	irsa.Spec.Trust.AdditionalPrincipals[0].AWS = "*"
+	if _, err := r.resolveIrsa(context.Background(), irsa); err == nil {
+		t.Fatalf("1 resolveIrsa should failed with wildcard principal")
+	}
+	irsa.Spec.Trust.AdditionalPrincipals[0].AWS = ciRole
+
+	// 2. additional principals should be merged into the trust relationship
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("2 create external resources failed: %v", err)
+	}
+	roleName := r.iamRoleClient.RoleName(irsa)
+	role, err := r.iamRoleClient.Get(context.Background(), roleName)
+	if err != nil {
+		t.Fatalf("2 get iam role failed: %v", err)
+	}
+	if !trustCI(role.AssumeRolePolicy) || !role.AssumeRolePolicy.IsAllowOIDC(r.oidc, aws.DefaultAudience, irsa.GetNamespace(), irsa.GetName()) {
+		t.Fatalf("2 iam role should trust service account and additional principals, but got: %v", role.AssumeRolePolicy)
+	}
+
+	// 3. drift of trust relationship should be reconciled
+	drifted := aws.NewAssumeRolePolicy(r.oidc, irsa.GetNamespace(), irsa.GetName())
+	if err := r.iamRoleClient.UpdateAssumePolicy(context.Background(), roleName, &drifted); err != nil {
+		t.Fatalf("3 update assume policy failed: %v", err)
+	}
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("3 update external resources failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), roleName)
+	if err != nil {
+		t.Fatalf("3 get iam role failed: %v", err)
+	}
+	if !trustCI(role.AssumeRolePolicy) {
+		t.Fatalf("3 additional principals should be restored, but got: %v", role.AssumeRolePolicy)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_sharedExternalRole(t *testing.T) {
+	externalRoleName := "shared-role"
+	newIrsa := func(name, uid string) *irsav1alpha1.IamRoleServiceAccount {
+		return &irsav1alpha1.IamRoleServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       types.UID(uid),
+			},
+			Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+				RoleName: externalRoleName,
+			},
+		}
+	}
+	irsaA, irsaB := newIrsa("a", "uid-a"), newIrsa("b", "uid-b")
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsaA, irsaB)
+	if _, err := mic.CreateRole(&iam.CreateRoleInput{
+		RoleName:                 goAws.String(externalRoleName),
+		AssumeRolePolicyDocument: goAws.String(`{"Version":"2012-10-17","Statement":[]}`),
+	}); err != nil {
+		t.Fatalf("Create external role failed: %v", err)
+	}
+	getTrust := func() *aws.AssumeRoleDocument {
+		role, err := r.iamRoleClient.Get(context.Background(), externalRoleName)
+		if err != nil {
+			t.Fatalf("Get external role failed: %v", err)
+		}
+		return role.AssumeRolePolicy
+	}
+
+	// 1. irsa sharing the external role should be consolidated into one statement
+	for _, irsa := range []*irsav1alpha1.IamRoleServiceAccount{irsaA, irsaB} {
+		if err := r.updateExternalIamRoleIfNeed(context.Background(), irsa); err != nil {
+			t.Fatalf("1 updateExternalIamRoleIfNeed failed: %v", err)
+		}
+	}
+	trust := getTrust()
+	if len(trust.Statement) != 1 {
+		t.Fatalf("1 trust relationship should have one statement, but got: %v", trust.Statement)
+	}
+	if !trust.IsAllowOIDC(r.oidc, aws.DefaultAudience, "default", "a") || !trust.IsAllowOIDC(r.oidc, aws.DefaultAudience, "default", "b") {
+		t.Fatalf("1 external role should trust both service accounts, but got: %v", trust.Statement)
+	}
+
+	// 2. service account still used by other irsa should not be revoked
+	if err := r.Create(context.Background(), &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "default", UID: "uid-c"},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			RoleName:        externalRoleName,
+			ServiceAccounts: []irsav1alpha1.ServiceAccountRef{{Name: "a"}},
+		},
+	}); err != nil {
+		t.Fatalf("2 create irsa failed: %v", err)
+	}
+	if err := r.Delete(context.Background(), irsaA); err != nil {
+		t.Fatalf("2 delete irsa failed: %v", err)
+	}
+	if err := r.deleteExternalResources(context.Background(), irsaA); err != nil {
+		t.Fatalf("2 deleteExternalResources failed: %v", err)
+	}
+	if !getTrust().IsAllowOIDC(r.oidc, aws.DefaultAudience, "default", "a") {
+		t.Fatalf("2 service account used by other irsa should be kept")
+	}
+
+	// 3. service account of deleted irsa should be revoked, the others should be kept
+	if err := r.deleteExternalResources(context.Background(), irsaB); err != nil {
+		t.Fatalf("3 deleteExternalResources failed: %v", err)
+	}
+	trust = getTrust()
+	if trust.IsAllowOIDC(r.oidc, aws.DefaultAudience, "default", "b") || !trust.IsAllowOIDC(r.oidc, aws.DefaultAudience, "default", "a") {
+		t.Fatalf("3 only the subject of deleted irsa should be revoked, but got: %v", trust.Statement)
+	}
+
+	// 4. service account removed from spec should be revoked
+	var irsaC irsav1alpha1.IamRoleServiceAccount
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "c"}, &irsaC); err != nil {
+		t.Fatalf("4 get irsa failed: %v", err)
+	}
+	if err := r.updateExternalIamRoleIfNeed(context.Background(), &irsaC); err != nil {
+		t.Fatalf("4 updateExternalIamRoleIfNeed failed: %v", err)
+	}
+	irsaC.Spec.ServiceAccounts = []irsav1alpha1.ServiceAccountRef{{Name: "d"}}
+	if err := r.updateExternalIamRoleIfNeed(context.Background(), &irsaC); err != nil {
+		t.Fatalf("4 updateExternalIamRoleIfNeed failed: %v", err)
+	}
+	trust = getTrust()
+	if trust.IsAllowOIDC(r.oidc, "", "default", "a") || !trust.IsAllowOIDC(r.oidc, aws.DefaultAudience, "default", "d") {
+		t.Fatalf("4 removed service account should be revoked, but got: %v", trust.Statement)
+	}
+	if !reflect.DeepEqual(irsaC.Status.TrustedServiceAccounts, []string{"default/d"}) {
+		t.Fatalf("4 trusted service accounts should be recorded, but got: %v", irsaC.Status.TrustedServiceAccounts)
+	}
+
+	// 5. service account removed from spec but still used by other irsa should be kept
+	if err := r.Create(context.Background(), &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "e", Namespace: "default", UID: "uid-e"},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			RoleName:        externalRoleName,
+			ServiceAccounts: []irsav1alpha1.ServiceAccountRef{{Name: "d"}},
+		},
+	}); err != nil {
+		t.Fatalf("5 create irsa failed: %v", err)
+	}
+	irsaC.Spec.ServiceAccounts = []irsav1alpha1.ServiceAccountRef{{Name: "f"}}
+	if err := r.updateExternalIamRoleIfNeed(context.Background(), &irsaC); err != nil {
+		t.Fatalf("5 updateExternalIamRoleIfNeed failed: %v", err)
+	}
+	trust = getTrust()
+	if !trust.IsAllowOIDC(r.oidc, aws.DefaultAudience, "default", "d") || !trust.IsAllowOIDC(r.oidc, aws.DefaultAudience, "default", "f") {
+		t.Fatalf("5 service account used by other irsa should be kept, but got: %v", trust.Statement)
+	}
+}
+
+func TestIamRoleServiceAccountReconciler_oidcProviders(t *testing.T) {
+	greenOidc := "arn:aws:iam::000000000000:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/GREEN"
+	canaryOidc := "arn:aws:iam::000000000000:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/CANARY"
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "irsa",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Trust: &irsav1alpha1.TrustSpec{
+				OIDCProviders: []irsav1alpha1.OIDCProvider{{Arn: greenOidc}},
+			},
+		},
+	}
+	mic := aws.NewMockedIamClient()
+	r := getReconciler(mic, irsa)
+	r.additionalOIDCProviders = []irsav1alpha1.OIDCProvider{{Arn: canaryOidc, Cluster: "canary"}}
+
+	// 1. oidc provider without cluster should be rejected
+	if _, err := r.resolveIrsa(context.Background(), irsa); err == nil {
+		t.Fatalf("1 resolveIrsa should failed with invalid oidc provider")
+	}
+
+	// 2. iam role should trust all of the oidc providers and record the clusters in tags
+	irsa.Spec.Trust.OIDCProviders[0].Cluster = "green"
+	if err := r.createExternalResources(context.Background(), irsa); err != nil {
+		t.Fatalf("2 create external resources failed: %v", err)
+	}
+	roleName := r.iamRoleClient.RoleName(irsa)
+	role, err := r.iamRoleClient.Get(context.Background(), roleName)
+	if err != nil {
+		t.Fatalf("2 get iam role failed: %v", err)
+	}
+	for _, oidc := range []string{r.oidc, greenOidc, canaryOidc} {
+		if !role.AssumeRolePolicy.IsAllowOIDC(oidc, aws.DefaultAudience, irsa.GetNamespace(), irsa.GetName()) {
+			t.Fatalf("2 iam role should trust oidc provider %s, but got: %v", oidc, role.AssumeRolePolicy)
+		}
+	}
+	for _, cluster := range []string{"test", "green", "canary"} {
+		if _, ok := role.Tags[aws.ClusterTagKey(cluster)]; !ok {
+			t.Fatalf("2 iam role should be tagged with cluster %s, but got: %v", cluster, role.Tags)
+		}
+	}
+
+	// 3. removed oidc provider should be untrusted and its cluster tag should be removed
+	irsa.Spec.Trust.OIDCProviders = nil
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("3 update external resources failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), roleName)
+	if err != nil {
+		t.Fatalf("3 get iam role failed: %v", err)
+	}
+	if role.AssumeRolePolicy.IsAllowOIDC(greenOidc, aws.DefaultAudience, irsa.GetNamespace(), irsa.GetName()) {
+		t.Fatalf("3 iam role should not trust removed oidc provider, but got: %v", role.AssumeRolePolicy)
+	}
+	if _, ok := role.Tags[aws.ClusterTagKey("green")]; ok {
+		t.Fatalf("3 cluster tag of removed oidc provider should be removed, but got: %v", role.Tags)
+	}
+	if _, ok := role.Tags[aws.ClusterTagKey("canary")]; !ok {
+		t.Fatalf("3 cluster tag of additional oidc provider should be kept, but got: %v", role.Tags)
+	}
+
+	// 4. cluster tag added by the controller of other cluster should be kept
+	if _, err := mic.TagRole(&iam.TagRoleInput{
+		RoleName: goAws.String(roleName),
+		Tags:     []*iam.Tag{{Key: goAws.String(aws.ClusterTagKey("blue")), Value: goAws.String("blue")}},
+	}); err != nil {
+		t.Fatalf("4 tag iam role failed: %v", err)
+	}
+	if err := r.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+		t.Fatalf("4 update external resources failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), roleName)
+	if err != nil {
+		t.Fatalf("4 get iam role failed: %v", err)
+	}
+	if role.Tags[aws.ClusterTagKey("blue")] != "blue" {
+		t.Fatalf("4 cluster tag added by other cluster should be kept, but got: %v", role.Tags)
+	}
+
+	// 5. external iam role should trust all of the oidc providers and be tagged additively
+	external := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "external", Namespace: "default"},
+		Spec:       irsav1alpha1.IamRoleServiceAccountSpec{RoleName: "external-role"},
+	}
+	if _, err := mic.CreateRole(&iam.CreateRoleInput{
+		RoleName:                 goAws.String(external.Spec.RoleName),
+		AssumeRolePolicyDocument: goAws.String(`{"Version":"2012-10-17","Statement":[]}`),
+		Tags:                     []*iam.Tag{{Key: goAws.String(aws.ClusterTagKey("blue")), Value: goAws.String("blue")}},
+	}); err != nil {
+		t.Fatalf("5 create external role failed: %v", err)
+	}
+	if err := r.updateExternalIamRoleIfNeed(context.Background(), external); err != nil {
+		t.Fatalf("5 updateExternalIamRoleIfNeed failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), external.Spec.RoleName)
+	if err != nil {
+		t.Fatalf("5 get external role failed: %v", err)
+	}
+	if !role.AssumeRolePolicy.IsAllowOIDC(r.oidc, aws.DefaultAudience, "default", "external") || !role.AssumeRolePolicy.IsAllowOIDC(canaryOidc, aws.DefaultAudience, "default", "external") {
+		t.Fatalf("5 external role should trust all of the oidc providers, but got: %v", role.AssumeRolePolicy)
+	}
+	for _, cluster := range []string{"test", "canary", "blue"} {
+		if _, ok := role.Tags[aws.ClusterTagKey(cluster)]; !ok {
+			t.Fatalf("5 external role should be tagged with cluster %s, but got: %v", cluster, role.Tags)
+		}
+	}
+
+	// 6. only the cluster tags added by this controller should be removed from external role
+	if err := r.deleteExternalResources(context.Background(), external); err != nil {
+		t.Fatalf("6 deleteExternalResources failed: %v", err)
+	}
+	role, err = r.iamRoleClient.Get(context.Background(), external.Spec.RoleName)
+	if err != nil {
+		t.Fatalf("6 get external role failed: %v", err)
+	}
+	wantTags := map[string]string{aws.ClusterTagKey("blue"): "blue"}
+	if !reflect.DeepEqual(role.Tags, wantTags) {
+		t.Fatalf("6 external role tags = %v, want %v", role.Tags, wantTags)
+	}
+}
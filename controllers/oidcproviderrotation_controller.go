@@ -0,0 +1,255 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	gerrors "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"domc.me/irsa-controller/pkg/aws"
+)
+
+var (
+	ErrOIDCProviderNotRotated = gerrors.New("The oidcProviderArn of controller has not been updated to the new oidc provider")
+)
+
+// OIDCProviderRotationReconciler reconciles a OIDCProviderRotation object
+type OIDCProviderRotationReconciler struct {
+	client.Client
+	scheme *runtime.Scheme
+
+	oidc string
+
+	iamRoleClient *aws.IamClient
+}
+
+func NewOIDCProviderRotationReconciler(cli client.Client, scheme *runtime.Scheme, oidcProviderArn string, iamRoleClient *aws.IamClient) *OIDCProviderRotationReconciler {
+	return &OIDCProviderRotationReconciler{
+		Client:        cli,
+		scheme:        scheme,
+		oidc:          oidcProviderArn,
+		iamRoleClient: iamRoleClient,
+	}
+}
+
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=oidcproviderrotations,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=oidcproviderrotations/status,verbs=get;update;patch
+
+// Reconcile moves the rotation forward once the trust relationships of all of the iam roles are updated,
+// the trust relationships are updated by IamRoleServiceAccountReconciler
+func (r *OIDCProviderRotationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+	l.Info("Syncing the status of oidc provider rotation")
+
+	rotation := new(irsav1alpha1.OIDCProviderRotation)
+	if err := r.Client.Get(ctx, req.NamespacedName, rotation); err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("OIDCProviderRotation is deleted, ignore events")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Get oidc provider rotation failed")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	err := r.reconcile(ctx, rotation)
+	r.updateRotationStatus(ctx, rotation, err)
+	if err != nil {
+		l.Error(err, "Reconcile oidc provider rotation failed")
+		return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+	}
+	if rotation.Status.Phase != irsav1alpha1.OIDCProviderRotationCompleted {
+		// the iam roles are updated by other controller, check the progress periodically
+		return ctrl.Result{RequeueAfter: requeuePeriod}, nil
+	}
+	l.Info("The oidc provider rotation is completed")
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OIDCProviderRotationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&irsav1alpha1.OIDCProviderRotation{}).
+		Watches(&source.Kind{Type: &irsav1alpha1.IamRoleServiceAccount{}}, handler.EnqueueRequestsFromMapFunc(r.mapIrsaToRotation)).
+		Complete(r)
+}
+
+// reconcile sets the phase of rotation and the progress of current phase.
+// The phase moves to AwaitingConfirmation once all of the iam roles trust the new oidc provider,
+// and moves to Removing after confirmation, then moves to Completed once none of the iam roles trusts the old oidc provider
+func (r *OIDCProviderRotationReconciler) reconcile(ctx context.Context, rotation *irsav1alpha1.OIDCProviderRotation) error {
+	spec := rotation.Spec
+	for _, arn := range []string{spec.From, spec.To} {
+		if err := aws.ValidateOIDCProvider(irsav1alpha1.OIDCProvider{Arn: arn, Cluster: r.iamRoleClient.ClusterName()}); err != nil {
+			rotation.Status.Phase = irsav1alpha1.OIDCProviderRotationFailed
+			return err
+		}
+	}
+	if spec.From == spec.To {
+		rotation.Status.Phase = irsav1alpha1.OIDCProviderRotationFailed
+		return fmt.Errorf("From and To of rotation are the same oidc provider")
+	}
+
+	phase := rotation.Status.Phase
+	switch phase {
+	case irsav1alpha1.OIDCProviderRotationCompleted:
+		return nil
+	case "", irsav1alpha1.OIDCProviderRotationFailed:
+		phase = irsav1alpha1.OIDCProviderRotationAdding
+	case irsav1alpha1.OIDCProviderRotationAwaitingConfirmation:
+		if spec.Confirmed {
+			// the old oidc provider cannot be removed while it is still used by controller
+			if r.oidc != spec.To {
+				return gerrors.Wrapf(ErrOIDCProviderNotRotated, "oidcProviderArn: %s", r.oidc)
+			}
+			phase = irsav1alpha1.OIDCProviderRotationRemoving
+		}
+	}
+	rotation.Status.Phase = phase
+
+	roles, pending, err := r.pendingRoles(ctx, rotation)
+	if err != nil {
+		return err
+	}
+	rotation.Status.Roles = roles
+	rotation.Status.UpdatedRoles = roles - len(pending)
+	rotation.Status.PendingRoles = pending
+	if len(pending) > 0 {
+		return nil
+	}
+	switch phase {
+	case irsav1alpha1.OIDCProviderRotationAdding:
+		rotation.Status.Phase = irsav1alpha1.OIDCProviderRotationAwaitingConfirmation
+	case irsav1alpha1.OIDCProviderRotationRemoving:
+		rotation.Status.Phase = irsav1alpha1.OIDCProviderRotationCompleted
+	}
+	return nil
+}
+
+// pendingRoles returns the count of iam roles bound to irsa and the names of iam roles not updated in the current phase,
+// the role is updated if all of its service accounts are trusted by the new oidc provider when adding,
+// or none of them are trusted by the old oidc provider when removing
+func (r *OIDCProviderRotationReconciler) pendingRoles(ctx context.Context, rotation *irsav1alpha1.OIDCProviderRotation) (int, []string, error) {
+	var irsas irsav1alpha1.IamRoleServiceAccountList
+	if err := r.List(ctx, &irsas); err != nil {
+		return 0, nil, gerrors.Wrap(err, "List irsa failed")
+	}
+	// the service accounts of each role with the audience enforced by irsa
+	type trustedServiceAccount struct {
+		types.NamespacedName
+		audience string
+	}
+	serviceAccounts := map[string][]trustedServiceAccount{}
+	for _, irsa := range irsas.Items {
+		if !irsa.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		roleName := irsa.Spec.RoleName
+		if roleName == "" {
+			roleName = aws.RoleNameByArn(irsa.Status.RoleArn)
+		}
+		if roleName == "" {
+			continue
+		}
+		audience := aws.TrustAudience(irsa.Spec.Trust)
+		for _, sa := range irsa.ServiceAccounts() {
+			serviceAccounts[roleName] = append(serviceAccounts[roleName], trustedServiceAccount{NamespacedName: sa, audience: audience})
+		}
+	}
+	removing := rotation.Status.Phase == irsav1alpha1.OIDCProviderRotationRemoving
+	roles := 0
+	var pending []string
+	for roleName, sas := range serviceAccounts {
+		role, err := r.iamRoleClient.Get(ctx, roleName)
+		if err != nil {
+			// the role has not been created, there is no trust relationship to rotate
+			if aws.ErrIsNotFound(err) {
+				continue
+			}
+			return 0, nil, gerrors.Wrapf(err, "Get iam role %s failed", roleName)
+		}
+		roles++
+		for _, sa := range sas {
+			updated := role.AssumeRolePolicy.IsAllowOIDC(rotation.Spec.To, sa.audience, sa.Namespace, sa.Name)
+			if removing {
+				// the old oidc provider must not be trusted by the statements of any audience
+				updated = !role.AssumeRolePolicy.IsAllowOIDC(rotation.Spec.From, "", sa.Namespace, sa.Name)
+			}
+			if !updated {
+				pending = append(pending, roleName)
+				break
+			}
+		}
+	}
+	sort.Strings(pending)
+	return roles, pending, nil
+}
+
+// mapIrsaToRotation returns the rotations in progress, so that the progress is refreshed once the iam role is updated
+func (r *OIDCProviderRotationReconciler) mapIrsaToRotation(obj client.Object) []reconcile.Request {
+	var rotations irsav1alpha1.OIDCProviderRotationList
+	if err := r.List(context.Background(), &rotations); err != nil {
+		log.Log.Error(err, "List oidc provider rotation by irsa failed")
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, rotation := range rotations.Items {
+		if rotation.Status.Phase != irsav1alpha1.OIDCProviderRotationCompleted {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: rotation.GetName()}})
+		}
+	}
+	return reqs
+}
+
+// updateRotationStatus returns true if the status is updated
+func (r *OIDCProviderRotationReconciler) updateRotationStatus(ctx context.Context, rotation *irsav1alpha1.OIDCProviderRotation, reconcileErr error) bool {
+	l := log.FromContext(ctx)
+	var origin irsav1alpha1.OIDCProviderRotation
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(rotation), &origin); err != nil {
+		l.Error(err, "Get oidc provider rotation failed")
+		return false
+	}
+	rotation.Status.Reason = ""
+	if reconcileErr != nil {
+		rotation.Status.Reason = reconcileErr.Error()
+	}
+	// ignore the request id in reason, avoid updating status in loop
+	if sameReason(origin.Status.Reason, rotation.Status.Reason) {
+		origin.Status.Reason = rotation.Status.Reason
+	}
+	if equality.Semantic.DeepEqual(origin.Status, rotation.Status) {
+		return false
+	}
+	if err := r.Status().Update(ctx, rotation); err != nil {
+		l.Error(err, "Update status failed", "to", rotation.Status.Phase)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"domc.me/irsa-controller/pkg/aws"
+	goAws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestOIDCProviderRotationReconciler_Reconcile(t *testing.T) {
+	from := "arn:aws:iam::000000000000:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/OLD"
+	to := "arn:aws:iam::000000000000:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/NEW"
+	managed := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "managed", Namespace: "default"},
+	}
+	external := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "external", Namespace: "default"},
+		Spec:       irsav1alpha1.IamRoleServiceAccountSpec{RoleName: "external-role"},
+	}
+	rotation := &irsav1alpha1.OIDCProviderRotation{
+		ObjectMeta: metav1.ObjectMeta{Name: "rotation"},
+		Spec:       irsav1alpha1.OIDCProviderRotationSpec{From: from, To: to},
+	}
+	mic := aws.NewMockedIamClient()
+	irsaReconciler := getReconciler(mic, managed, external)
+	irsaReconciler.oidc = from
+	r := NewOIDCProviderRotationReconciler(irsaReconciler.Client, irsaReconciler.scheme, from, irsaReconciler.iamRoleClient)
+
+	if _, err := mic.CreateRole(&iam.CreateRoleInput{
+		RoleName:                 goAws.String(external.Spec.RoleName),
+		AssumeRolePolicyDocument: goAws.String(`{"Version":"2012-10-17","Statement":[]}`),
+	}); err != nil {
+		t.Fatalf("Create external role failed: %v", err)
+	}
+	if err := irsaReconciler.updateExternalIamRoleIfNeed(context.Background(), external); err != nil {
+		t.Fatalf("Allow external role failed: %v", err)
+	}
+	if err := irsaReconciler.createExternalResources(context.Background(), managed); err != nil {
+		t.Fatalf("Create managed role failed: %v", err)
+	}
+	managed.Status.RoleArn = "arn:aws:iam::000000000000:role/" + irsaReconciler.iamRoleClient.RoleName(managed)
+	if err := irsaReconciler.Status().Update(context.Background(), managed); err != nil {
+		t.Fatalf("Update status of managed irsa failed: %v", err)
+	}
+
+	// the rotation is started after the roles are created
+	if err := r.Create(context.Background(), rotation); err != nil {
+		t.Fatalf("Create oidc provider rotation failed: %v", err)
+	}
+	key := types.NamespacedName{Name: rotation.GetName()}
+	reconcile := func() *irsav1alpha1.OIDCProviderRotation {
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile oidc provider rotation failed: %v", err)
+		}
+		var got irsav1alpha1.OIDCProviderRotation
+		if err := r.Get(context.Background(), key, &got); err != nil {
+			t.Fatalf("Get oidc provider rotation failed: %v", err)
+		}
+		return &got
+	}
+	syncRoles := func() {
+		for _, irsa := range []*irsav1alpha1.IamRoleServiceAccount{managed, external} {
+			if err := irsaReconciler.updateExternalResourcesIfNeed(context.Background(), irsa); err != nil {
+				t.Fatalf("Update iam role of irsa %s failed: %v", irsa.GetName(), err)
+			}
+		}
+	}
+	trust := func(roleName string) *aws.AssumeRoleDocument {
+		role, err := irsaReconciler.iamRoleClient.Get(context.Background(), roleName)
+		if err != nil {
+			t.Fatalf("Get iam role failed: %v", err)
+		}
+		return role.AssumeRolePolicy
+	}
+
+	// 1. roles not trusting the new oidc provider should be pending
+	got := reconcile()
+	if got.Status.Phase != irsav1alpha1.OIDCProviderRotationAdding || got.Status.Roles != 2 || len(got.Status.PendingRoles) != 2 {
+		t.Fatalf("1 rotation should be adding with 2 pending roles, but got: %+v", got.Status)
+	}
+
+	// 2. rotation should wait for confirmation once all of the roles trust both oidc providers
+	syncRoles()
+	got = reconcile()
+	if got.Status.Phase != irsav1alpha1.OIDCProviderRotationAwaitingConfirmation || got.Status.UpdatedRoles != 2 {
+		t.Fatalf("2 rotation should be awaiting confirmation, but got: %+v", got.Status)
+	}
+	if !trust(irsaReconciler.iamRoleClient.RoleName(managed)).IsAllowOIDC(from, aws.DefaultAudience, "default", "managed") || !trust(external.Spec.RoleName).IsAllowOIDC(from, aws.DefaultAudience, "default", "external") {
+		t.Fatalf("2 roles should still trust the old oidc provider")
+	}
+
+	// 3. confirmation should be blocked while controller still uses the old oidc provider
+	got.Spec.Confirmed = true
+	if err := r.Update(context.Background(), got); err != nil {
+		t.Fatalf("3 confirm rotation failed: %v", err)
+	}
+	got = reconcile()
+	if got.Status.Phase != irsav1alpha1.OIDCProviderRotationAwaitingConfirmation || got.Status.Reason == "" {
+		t.Fatalf("3 rotation should not be confirmed before controller is updated, but got: %+v", got.Status)
+	}
+
+	// 4. old oidc provider should be removed from all of the roles after confirmation
+	r.oidc, irsaReconciler.oidc = to, to
+	got = reconcile()
+	if got.Status.Phase != irsav1alpha1.OIDCProviderRotationRemoving || len(got.Status.PendingRoles) != 2 {
+		t.Fatalf("4 rotation should be removing with 2 pending roles, but got: %+v", got.Status)
+	}
+	syncRoles()
+	got = reconcile()
+	if got.Status.Phase != irsav1alpha1.OIDCProviderRotationCompleted || got.Status.UpdatedRoles != 2 {
+		t.Fatalf("4 rotation should be completed, but got: %+v", got.Status)
+	}
+	if doc := trust(irsaReconciler.iamRoleClient.RoleName(managed)); doc.IsAllowOIDC(from, aws.DefaultAudience, "default", "managed") || !doc.IsAllowOIDC(to, aws.DefaultAudience, "default", "managed") {
+		t.Fatalf("4 managed role should only trust the new oidc provider, but got: %v", doc)
+	}
+	if doc := trust(external.Spec.RoleName); doc.IsAllowOIDC(from, aws.DefaultAudience, "default", "external") || !doc.IsAllowOIDC(to, aws.DefaultAudience, "default", "external") {
+		t.Fatalf("4 external role should only trust the new oidc provider, but got: %v", doc)
+	}
+}
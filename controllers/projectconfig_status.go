@@ -0,0 +1,177 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	gerrors "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"domc.me/irsa-controller/pkg/aws"
+)
+
+const (
+	// ProjectConfigName is the name of ProjectConfig mirroring the configuration of controller
+	ProjectConfigName = "irsa-controller"
+	// statusReportPeriod is the interval to report the health of controller
+	statusReportPeriod = time.Minute
+	// throttledPeriod is how long aws api calls are regarded as throttled after the last throttled call
+	throttledPeriod = time.Minute * 5
+)
+
+// ProjectConfigStatusReporter mirrors the configuration of controller to a ProjectConfig and reports
+// the health of controller to its status periodically
+type ProjectConfigStatusReporter struct {
+	client.Client
+
+	config    *irsav1alpha1.ProjectConfig
+	key       types.NamespacedName
+	readiness *aws.ReadinessChecker
+	stats     *aws.APIStats
+	now       func() time.Time
+}
+
+func NewProjectConfigStatusReporter(cli client.Client, config *irsav1alpha1.ProjectConfig, namespace string, readiness *aws.ReadinessChecker, stats *aws.APIStats) *ProjectConfigStatusReporter {
+	return &ProjectConfigStatusReporter{
+		Client:    cli,
+		config:    config,
+		key:       types.NamespacedName{Namespace: namespace, Name: ProjectConfigName},
+		readiness: readiness,
+		stats:     stats,
+		now:       time.Now,
+	}
+}
+
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=projectconfigs,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=irsa.domc.me,resources=projectconfigs/status,verbs=get;update;patch
+
+// Start implements manager.Runnable
+func (r *ProjectConfigStatusReporter) Start(ctx context.Context) error {
+	l := log.FromContext(ctx).WithValues("projectconfig", r.key)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.report(ctx); err != nil {
+			l.Error(err, "Report the status of controller failed")
+		}
+	}, statusReportPeriod)
+	return nil
+}
+
+// report mirrors the configuration of controller and updates the status of ProjectConfig
+func (r *ProjectConfigStatusReporter) report(ctx context.Context) error {
+	pc := new(irsav1alpha1.ProjectConfig)
+	if err := r.Client.Get(ctx, r.key, pc); err != nil {
+		if !errors.IsNotFound(err) {
+			return gerrors.Wrap(err, "Get project config failed")
+		}
+		pc.Namespace, pc.Name = r.key.Namespace, r.key.Name
+		pc.ProjectConfigSpec = r.mirroredSpec()
+		if err := r.Client.Create(ctx, pc); err != nil {
+			return gerrors.Wrap(err, "Create project config failed")
+		}
+	} else if spec := r.mirroredSpec(); !equality.Semantic.DeepEqual(pc.ProjectConfigSpec, spec) {
+		pc.ProjectConfigSpec = spec
+		if err := r.Client.Update(ctx, pc); err != nil {
+			return gerrors.Wrap(err, "Update project config failed")
+		}
+	}
+
+	status, err := r.status(ctx)
+	if err != nil {
+		return err
+	}
+	// avoid writing to api server on every report
+	if !statusChanged(pc.Status, status) {
+		return nil
+	}
+	pc.Status = status
+	return gerrors.Wrap(r.Client.Status().Update(ctx, pc), "Update the status of project config failed")
+}
+
+// mirroredSpec returns the configuration of controller without credentials
+func (r *ProjectConfigStatusReporter) mirroredSpec() irsav1alpha1.ProjectConfigSpec {
+	spec := r.config.ProjectConfigSpec.DeepCopy()
+	if spec.AWSConfig != nil {
+		spec.AWSConfig.SecretAccessKey = ""
+	}
+	return *spec
+}
+
+// statusChanged returns true if the status is changed, the timestamps are ignored since they change on every report
+func statusChanged(got, want irsav1alpha1.ProjectConfigStatus) bool {
+	got.LastUpdateTime, want.LastUpdateTime = nil, nil
+	got.LastAWSSuccessTime, want.LastAWSSuccessTime = nil, nil
+	return !equality.Semantic.DeepEqual(got, want)
+}
+
+// statusTime returns t in the precision of seconds as it is stored, so that the status can be compared with the stored one
+func statusTime(t time.Time) *metav1.Time {
+	st := metav1.NewTime(t).Rfc3339Copy()
+	return &st
+}
+
+func (r *ProjectConfigStatusReporter) status(ctx context.Context) (irsav1alpha1.ProjectConfigStatus, error) {
+	now := r.now()
+	status := irsav1alpha1.ProjectConfigStatus{
+		OIDCProviderArn: r.config.OIDCProviderArn,
+		LastUpdateTime:  statusTime(now),
+	}
+
+	if err := r.readiness.CheckContext(ctx); err != nil {
+		status.Reason = err.Error()
+	} else {
+		status.Ready = true
+	}
+	status.AccountID = r.readiness.Account()
+
+	irsaList := new(irsav1alpha1.IamRoleServiceAccountList)
+	if err := r.Client.List(ctx, irsaList); err != nil {
+		return status, gerrors.Wrap(err, "List IamRoleServiceAccounts failed")
+	}
+	if len(irsaList.Items) > 0 {
+		status.IrsaConditions = make(map[string]int)
+	}
+	for _, irsa := range irsaList.Items {
+		condition := string(irsa.Status.Condition)
+		if irsa.Status.Condition == irsav1alpha1.IrsaSubmitted {
+			condition = "Submitted"
+		}
+		status.IrsaConditions[condition]++
+	}
+
+	stats := r.stats.Snapshot()
+	if !stats.LastSuccessTime.IsZero() {
+		status.LastAWSSuccessTime = statusTime(stats.LastSuccessTime)
+	}
+	status.Throttling = irsav1alpha1.AWSThrottlingStatus{
+		ThrottledRequests: stats.ThrottledRequests,
+		RetriedRequests:   stats.RetriedRequests,
+	}
+	if !stats.LastThrottledTime.IsZero() {
+		status.Throttling.LastThrottledTime = statusTime(stats.LastThrottledTime)
+		status.Throttling.Throttled = now.Sub(stats.LastThrottledTime) < throttledPeriod
+	}
+	return status, nil
+}
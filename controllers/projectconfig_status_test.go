@@ -0,0 +1,121 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"domc.me/irsa-controller/pkg/aws"
+	goAws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestProjectConfigStatusReporter_report(t *testing.T) {
+	newIrsa := func(name string, condition irsav1alpha1.IrsaCondition) *irsav1alpha1.IamRoleServiceAccount {
+		return &irsav1alpha1.IamRoleServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Status:     irsav1alpha1.IamRoleServiceAccountStatus{Condition: condition},
+		}
+	}
+	mic := aws.NewMockedIamClient()
+	output, err := mic.CreateOpenIDConnectProviderWithContext(context.Background(), &iam.CreateOpenIDConnectProviderInput{Url: goAws.String("https://oidc.example.com/cluster")})
+	if err != nil {
+		t.Fatalf("Create oidc provider failed: %v", err)
+	}
+	providerArn := goAws.StringValue(output.OpenIDConnectProviderArn)
+	irsaReconciler := getReconciler(mic, newIrsa("a", irsav1alpha1.IrsaOK), newIrsa("b", irsav1alpha1.IrsaOK), newIrsa("c", irsav1alpha1.IrsaFailed), newIrsa("d", irsav1alpha1.IrsaSubmitted))
+
+	config := &irsav1alpha1.ProjectConfig{ProjectConfigSpec: irsav1alpha1.ProjectConfigSpec{
+		Cluster:         "test",
+		OIDCProviderArn: providerArn,
+		AWSConfig:       &irsav1alpha1.AWSConfigSpec{AccessKeyID: "id", SecretAccessKey: "secret"},
+	}}
+	stats := &aws.APIStats{}
+	readiness := aws.NewReadinessCheckerWithAPI(mic, aws.NewMockedStsClient("000000000000"), providerArn, time.Minute)
+	r := NewProjectConfigStatusReporter(irsaReconciler.Client, config, "irsa-controller-system", readiness, stats)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	stats.Handler().Fn(&request.Request{})
+	stats.Handler().Fn(&request.Request{Error: awserr.New("Throttling", "Rate exceeded", nil), RetryCount: 3})
+
+	if err := r.report(context.Background()); err != nil {
+		t.Fatalf("1 report failed: %v", err)
+	}
+	pc := new(irsav1alpha1.ProjectConfig)
+	if err := r.Client.Get(context.Background(), types.NamespacedName{Namespace: "irsa-controller-system", Name: ProjectConfigName}, pc); err != nil {
+		t.Fatalf("2 get project config failed: %v", err)
+	}
+	if pc.Cluster != "test" || pc.OIDCProviderArn != providerArn {
+		t.Errorf("3 config is not mirrored, got %+v", pc.ProjectConfigSpec)
+	}
+	if pc.AWSConfig.SecretAccessKey != "" || config.AWSConfig.SecretAccessKey != "secret" {
+		t.Errorf("4 secret access key should be removed from the mirrored config only")
+	}
+	status := pc.Status
+	if !status.Ready || status.Reason != "" || status.AccountID != "000000000000" || status.OIDCProviderArn != providerArn {
+		t.Errorf("5 readiness is not reported, got %+v", status)
+	}
+	wantConditions := map[string]int{"Synced": 2, "Failed": 1, "Submitted": 1}
+	for condition, count := range wantConditions {
+		if status.IrsaConditions[condition] != count {
+			t.Errorf("6 count of %s irsa = %d, want %d", condition, status.IrsaConditions[condition], count)
+		}
+	}
+	if status.LastAWSSuccessTime == nil {
+		t.Errorf("7 last aws success time is not reported")
+	}
+	if !status.Throttling.Throttled || status.Throttling.ThrottledRequests != 1 || status.Throttling.RetriedRequests != 1 {
+		t.Errorf("8 throttling is not reported, got %+v", status.Throttling)
+	}
+
+	now = now.Add(throttledPeriod + time.Second)
+	readiness = aws.NewReadinessCheckerWithAPI(mic, aws.NewMockedStsClient("000000000000"), providerArn+"-missing", time.Minute)
+	r.readiness = readiness
+	if err := r.report(context.Background()); err != nil {
+		t.Fatalf("9 report failed: %v", err)
+	}
+	if err := r.Client.Get(context.Background(), types.NamespacedName{Namespace: "irsa-controller-system", Name: ProjectConfigName}, pc); err != nil {
+		t.Fatalf("10 get project config failed: %v", err)
+	}
+	if pc.Status.Ready || pc.Status.Reason == "" {
+		t.Errorf("11 the reason of failed readiness check is not reported, got %+v", pc.Status)
+	}
+	if pc.Status.Throttling.Throttled {
+		t.Errorf("12 throttling should be recovered after %v", throttledPeriod)
+	}
+
+	// the status should not be written if only the timestamps are changed
+	resourceVersion := pc.ResourceVersion
+	now = now.Add(statusReportPeriod)
+	stats.Handler().Fn(&request.Request{})
+	if err := r.report(context.Background()); err != nil {
+		t.Fatalf("13 report failed: %v", err)
+	}
+	if err := r.Client.Get(context.Background(), types.NamespacedName{Namespace: "irsa-controller-system", Name: ProjectConfigName}, pc); err != nil {
+		t.Fatalf("14 get project config failed: %v", err)
+	}
+	if pc.ResourceVersion != resourceVersion {
+		t.Errorf("15 unchanged status should not be updated, resource version %s -> %s", resourceVersion, pc.ResourceVersion)
+	}
+}
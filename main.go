@@ -17,7 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"time"
 
@@ -27,10 +31,14 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"domc.me/irsa-controller/api/v1alpha1"
 	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
@@ -44,6 +52,14 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 
 	syncPeriod = time.Hour * 1
+	// publishPeriod is the interval to check the rotation of service account signing keys
+	publishPeriod = time.Minute
+	// readinessTTL is the duration to cache the result of checking aws
+	readinessTTL = time.Second * 30
+	// awsReadinessPath is the path of metrics server serving the result of aws readiness check
+	awsReadinessPath = "/readyz/aws"
+	// startupTimeout bounds the calls to aws and api server before starting the manager
+	startupTimeout = time.Minute * 2
 )
 
 func init() {
@@ -88,7 +104,8 @@ func main() {
 	}
 	setupLog.Info("read config from config.yaml", "value", ctrlConfig)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
+	restConfig := ctrl.GetConfigOrDie()
+	mgr, err := ctrl.NewManager(restConfig, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
@@ -99,12 +116,84 @@ func main() {
 		os.Exit(1)
 	}
 
-	irsar := controllers.NewIamRoleServiceAccountReconciler(mgr.GetClient(), mgr.GetScheme(), ctrlConfig.OIDCProviderArn, aws.NewIamClient(ctrlConfig.Cluster, ctrlConfig.IamRolePrefix, ctrlConfig.AdditionalTags, aws.NewAWSConfigFromSpec(ctrlConfig.AWSConfig)))
+	iamClient := aws.NewIamClient(ctrlConfig.Cluster, ctrlConfig.IamRolePrefix, ctrlConfig.AdditionalTags, aws.NewAWSConfigFromSpec(ctrlConfig.AWSConfig))
+	iamClient.SetTrustPolicySizeLimit(ctrlConfig.TrustPolicySizeLimit)
+
+	// the manager is not started yet, a hanging issuer or endpoint must not block the startup forever
+	startupCtx, cancelStartup := context.WithTimeout(context.Background(), startupTimeout)
+
+	if ctrlConfig.PublishOIDCDiscovery() {
+		cs := kubernetes.NewForConfigOrDie(restConfig)
+		publisher := aws.NewDiscoveryPublisher(aws.NewAWSConfigFromSpec(ctrlConfig.AWSConfig), ctrlConfig.OIDCDiscovery)
+		// publish before ensuring the oidc provider, which reads the discovery document from the issuer
+		if err := publishOIDCDiscovery(startupCtx, cs, publisher); err != nil {
+			setupLog.Error(err, "unable to publish the oidc discovery documents")
+			os.Exit(1)
+		}
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			wait.UntilWithContext(ctx, func(ctx context.Context) {
+				if err := publishOIDCDiscovery(ctx, cs, publisher); err != nil {
+					setupLog.Error(err, "unable to publish the oidc discovery documents")
+				}
+			}, publishPeriod)
+			return nil
+		})); err != nil {
+			setupLog.Error(err, "unable to set up oidc discovery publishing")
+			os.Exit(1)
+		}
+	}
+
+	if ctrlConfig.ManageOIDCProvider() {
+		providerArn, err := ensureOIDCProvider(startupCtx, iamClient, &ctrlConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to ensure the oidc provider")
+			os.Exit(1)
+		}
+		if ctrlConfig.OIDCProviderArn != "" && ctrlConfig.OIDCProviderArn != providerArn {
+			setupLog.Error(fmt.Errorf("oidcProviderArn %s does not match the managed oidc provider %s", ctrlConfig.OIDCProviderArn, providerArn), "invalid config")
+			os.Exit(1)
+		}
+		ctrlConfig.OIDCProviderArn = providerArn
+		setupLog.Info("ensured the oidc provider", "arn", ctrlConfig.OIDCProviderArn)
+
+		// keep the thumbprint and client ids up to date when the certificate of issuer is renewed
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			wait.UntilWithContext(ctx, func(ctx context.Context) {
+				if _, err := ensureOIDCProvider(ctx, iamClient, &ctrlConfig); err != nil {
+					setupLog.Error(err, "unable to ensure the oidc provider")
+				}
+			}, syncPeriod)
+			return nil
+		})); err != nil {
+			setupLog.Error(err, "unable to set up oidc provider sync")
+			os.Exit(1)
+		}
+	} else if ctrlConfig.OIDCProviderArn == "" {
+		ctrlConfig.OIDCProviderArn, err = discoverOIDCProviderArn(startupCtx, restConfig, &ctrlConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to discover the oidc provider, set oidcProviderArn in the config file")
+			os.Exit(1)
+		}
+		setupLog.Info("discovered the oidc provider", "arn", ctrlConfig.OIDCProviderArn)
+	}
+	cancelStartup()
+
+	irsar := controllers.NewIamRoleServiceAccountReconciler(mgr.GetClient(), mgr.GetScheme(), ctrlConfig.OIDCProviderArn, ctrlConfig.AdditionalOIDCProviders, ctrlConfig.AllowWildcardSubjects, ctrlConfig.AllowedTrustPrincipals, iamClient)
 
 	if err = irsar.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "IamRoleServiceAccount")
 		os.Exit(1)
 	}
+	iamPolicyReconciler := controllers.NewIamPolicyReconciler(mgr.GetClient(), mgr.GetScheme(), iamClient)
+	if err = iamPolicyReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "IamPolicy")
+		os.Exit(1)
+	}
+	rotationReconciler := controllers.NewOIDCProviderRotationReconciler(mgr.GetClient(), mgr.GetScheme(), ctrlConfig.OIDCProviderArn, iamClient)
+	if err = rotationReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "OIDCProviderRotation")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -115,6 +204,37 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	readinessChecker := aws.NewReadinessChecker(aws.NewAWSConfigFromSpec(ctrlConfig.AWSConfig), ctrlConfig.OIDCProviderArn, readinessTTL)
+	// log the result only when it changes, readyz is probed much more often than the result is refreshed
+	readinessChecker.OnChange(func(err error) {
+		if err != nil {
+			setupLog.Error(err, "aws is not ready")
+		} else {
+			setupLog.Info("aws is ready")
+		}
+	})
+	// not fatal if the initial check fails, the credentials may be provisioned after starting, e.g. by irsa itself
+	_ = readinessChecker.Check(&http.Request{})
+	if err := mgr.AddReadyzCheck("aws", readinessChecker.Check); err != nil {
+		setupLog.Error(err, "unable to set up aws ready check")
+		os.Exit(1)
+	}
+	// the reason of failed checks is withheld from the response of readyz, serve it beside the metrics
+	if err := mgr.AddMetricsExtraHandler(awsReadinessPath, readinessChecker); err != nil {
+		setupLog.Error(err, "unable to set up aws readiness handler")
+		os.Exit(1)
+	}
+
+	// the health of controller is reported to the ProjectConfig in the namespace of controller
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		reporter := controllers.NewProjectConfigStatusReporter(mgr.GetClient(), &ctrlConfig, namespace, readinessChecker, aws.DefaultAPIStats)
+		if err := mgr.Add(reporter); err != nil {
+			setupLog.Error(err, "unable to set up project config status reporter")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("POD_NAMESPACE is not set, the status of controller is not reported")
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -122,3 +242,73 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// ensureOIDCProvider creates or updates the iam oidc provider of the configured issuer with its current thumbprint
+func ensureOIDCProvider(ctx context.Context, iamClient *aws.IamClient, ctrlConfig *v1alpha1.ProjectConfig) (string, error) {
+	thumbprint, err := aws.OIDCThumbprint(ctx, ctrlConfig.OIDCProvider.IssuerURL)
+	if err != nil {
+		return "", err
+	}
+	clientIDs := ctrlConfig.OIDCProvider.ClientIDs
+	if len(clientIDs) == 0 {
+		clientIDs = []string{aws.DefaultAudience}
+	}
+	return iamClient.EnsureOIDCProvider(ctx, ctrlConfig.OIDCProvider.IssuerURL, clientIDs, thumbprint)
+}
+
+// discoverOIDCProviderArn finds the iam oidc provider of the service account issuer read from api server
+func discoverOIDCProviderArn(ctx context.Context, restConfig *rest.Config, ctrlConfig *v1alpha1.ProjectConfig) (string, error) {
+	cs, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return "", err
+	}
+	issuer, err := serviceAccountIssuer(ctx, cs)
+	if err != nil {
+		return "", err
+	}
+	return aws.DiscoverOIDCProviderArn(ctx, aws.NewAWSConfigFromSpec(ctrlConfig.AWSConfig), issuer)
+}
+
+// publishOIDCDiscovery publishes the discovery document and json web key set of the service account issuer read from api server
+func publishOIDCDiscovery(ctx context.Context, cs kubernetes.Interface, publisher *aws.DiscoveryPublisher) error {
+	openidConfig, err := openidConfiguration(ctx, cs)
+	if err != nil {
+		return err
+	}
+	jwks, err := cs.Discovery().RESTClient().Get().AbsPath("/openid/v1/jwks").DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("Get json web key set of api server failed: %v", err)
+	}
+	uploaded, err := publisher.Publish(ctx, openidConfig, jwks)
+	if err != nil {
+		return err
+	}
+	if uploaded {
+		setupLog.Info("published the oidc discovery documents")
+	}
+	return nil
+}
+
+// openidConfiguration returns the openid configuration of api server
+func openidConfiguration(ctx context.Context, cs kubernetes.Interface) ([]byte, error) {
+	body, err := cs.Discovery().RESTClient().Get().AbsPath("/.well-known/openid-configuration").DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Get openid configuration of api server failed: %v", err)
+	}
+	return body, nil
+}
+
+// serviceAccountIssuer returns the service account issuer in the openid configuration of api server
+func serviceAccountIssuer(ctx context.Context, cs kubernetes.Interface) (string, error) {
+	body, err := openidConfiguration(ctx, cs)
+	if err != nil {
+		return "", err
+	}
+	var openidConfig struct {
+		Issuer string `json:"issuer"`
+	}
+	if err := json.Unmarshal(body, &openidConfig); err != nil || openidConfig.Issuer == "" {
+		return "", fmt.Errorf("Issuer is not found in openid configuration of api server: %s", body)
+	}
+	return openidConfig.Issuer, nil
+}
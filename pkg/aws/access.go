@@ -0,0 +1,246 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+// accessTarget determines the arn of resources in access intent
+type accessTarget struct {
+	partition      string
+	region         string
+	accountID      string
+	allowWildcards bool
+}
+
+// checkWildcards returns error if the name or arn of resource contains wildcards which are not allowed
+func (t accessTarget) checkWildcards(kind, nameOrArn string) error {
+	if !t.allowWildcards && strings.ContainsAny(nameOrArn, "*?") {
+		return errors.Errorf("%s %s contains wildcards, set allowWildcards to grant the access to all matched resources", kind, nameOrArn)
+	}
+	return nil
+}
+
+// arn returns nameOrArn if it is an arn, otherwise returns the arn of resource in the account and region of oidc provider,
+// returns error if the region is unknown or wildcards are not allowed
+func (t accessTarget) arn(service, resourceFormat, nameOrArn string) (string, error) {
+	if err := t.checkWildcards(service, nameOrArn); err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(nameOrArn, "arn:") {
+		return nameOrArn, nil
+	}
+	if t.region == "" {
+		return "", errors.Errorf("region is required for %s %s given by name", service, nameOrArn)
+	}
+	if err := t.checkWildcards("region", t.region); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("arn:%s:%s:%s:%s:%s", t.partition, service, t.region, t.accountID, fmt.Sprintf(resourceFormat, nameOrArn)), nil
+}
+
+// accessRule grants the actions on the resources returned by resources
+type accessRule struct {
+	actions   []string
+	resources func(t accessTarget, access *irsav1alpha1.AccessSpec) ([]string, irsav1alpha1.StatementConditionSpec, error)
+}
+
+func s3BucketResources(t accessTarget, access *irsav1alpha1.AccessSpec) ([]string, irsav1alpha1.StatementConditionSpec, error) {
+	if access.Bucket == "" {
+		return nil, nil, errors.New("bucket is required")
+	}
+	if err := t.checkWildcards("bucket", access.Bucket); err != nil {
+		return nil, nil, err
+	}
+	var condition irsav1alpha1.StatementConditionSpec
+	if access.Prefix != "" {
+		condition = irsav1alpha1.StatementConditionSpec{"StringLike": {"s3:prefix": access.Prefix + "*"}}
+	}
+	return []string{fmt.Sprintf("arn:%s:s3:::%s", t.partition, access.Bucket)}, condition, nil
+}
+
+func s3ObjectResources(t accessTarget, access *irsav1alpha1.AccessSpec) ([]string, irsav1alpha1.StatementConditionSpec, error) {
+	if access.Bucket == "" {
+		return nil, nil, errors.New("bucket is required")
+	}
+	if err := t.checkWildcards("bucket", access.Bucket); err != nil {
+		return nil, nil, err
+	}
+	return []string{fmt.Sprintf("arn:%s:s3:::%s/%s*", t.partition, access.Bucket, access.Prefix)}, nil, nil
+}
+
+func sqsQueueResources(t accessTarget, access *irsav1alpha1.AccessSpec) ([]string, irsav1alpha1.StatementConditionSpec, error) {
+	if access.Queue == "" {
+		return nil, nil, errors.New("queue is required")
+	}
+	queue, err := t.arn("sqs", "%s", access.Queue)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{queue}, nil, nil
+}
+
+func snsTopicResources(t accessTarget, access *irsav1alpha1.AccessSpec) ([]string, irsav1alpha1.StatementConditionSpec, error) {
+	if access.Topic == "" {
+		return nil, nil, errors.New("topic is required")
+	}
+	topic, err := t.arn("sns", "%s", access.Topic)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{topic}, nil, nil
+}
+
+func secretResources(t accessTarget, access *irsav1alpha1.AccessSpec) ([]string, irsav1alpha1.StatementConditionSpec, error) {
+	if access.Secret == "" {
+		return nil, nil, errors.New("secret is required")
+	}
+	// secretsmanager appends 6 random characters to the arn of secret
+	secret, err := t.arn("secretsmanager", "secret:%s-??????", access.Secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{secret}, nil, nil
+}
+
+func dynamodbTableResources(t accessTarget, access *irsav1alpha1.AccessSpec) ([]string, irsav1alpha1.StatementConditionSpec, error) {
+	if access.Table == "" {
+		return nil, nil, errors.New("table is required")
+	}
+	table, err := t.arn("dynamodb", "table/%s", access.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{table, table + "/index/*"}, nil, nil
+}
+
+var (
+	s3ReadRules = []accessRule{
+		{actions: []string{"s3:ListBucket"}, resources: s3BucketResources},
+		{actions: []string{"s3:GetObject"}, resources: s3ObjectResources},
+	}
+	s3WriteRules = []accessRule{
+		{actions: []string{"s3:PutObject", "s3:DeleteObject", "s3:AbortMultipartUpload"}, resources: s3ObjectResources},
+	}
+	dynamodbReadRules = []accessRule{
+		{actions: []string{"dynamodb:DescribeTable", "dynamodb:GetItem", "dynamodb:BatchGetItem", "dynamodb:Query", "dynamodb:Scan", "dynamodb:ConditionCheckItem"}, resources: dynamodbTableResources},
+	}
+	dynamodbWriteRules = []accessRule{
+		{actions: []string{"dynamodb:DescribeTable", "dynamodb:PutItem", "dynamodb:UpdateItem", "dynamodb:DeleteItem", "dynamodb:BatchWriteItem"}, resources: dynamodbTableResources},
+	}
+)
+
+// accessCatalog is the built-in catalog of services, it maps the modes of service to the rules granting least privileges
+var accessCatalog = map[irsav1alpha1.AccessService]map[irsav1alpha1.AccessMode][]accessRule{
+	irsav1alpha1.AccessS3: {
+		irsav1alpha1.AccessRead:      s3ReadRules,
+		irsav1alpha1.AccessWrite:     s3WriteRules,
+		irsav1alpha1.AccessReadWrite: append(append([]accessRule{}, s3ReadRules...), s3WriteRules...),
+	},
+	irsav1alpha1.AccessSQS: {
+		irsav1alpha1.AccessConsume: {
+			{actions: []string{"sqs:ReceiveMessage", "sqs:DeleteMessage", "sqs:ChangeMessageVisibility", "sqs:GetQueueAttributes", "sqs:GetQueueUrl"}, resources: sqsQueueResources},
+		},
+		irsav1alpha1.AccessProduce: {
+			{actions: []string{"sqs:SendMessage", "sqs:GetQueueAttributes", "sqs:GetQueueUrl"}, resources: sqsQueueResources},
+		},
+	},
+	irsav1alpha1.AccessSNS: {
+		irsav1alpha1.AccessPublish: {
+			{actions: []string{"sns:Publish"}, resources: snsTopicResources},
+		},
+	},
+	irsav1alpha1.AccessSecretsManager: {
+		irsav1alpha1.AccessRead: {
+			{actions: []string{"secretsmanager:GetSecretValue", "secretsmanager:DescribeSecret"}, resources: secretResources},
+		},
+	},
+	irsav1alpha1.AccessDynamoDB: {
+		irsav1alpha1.AccessRead:      dynamodbReadRules,
+		irsav1alpha1.AccessWrite:     dynamodbWriteRules,
+		irsav1alpha1.AccessReadWrite: append(append([]accessRule{}, dynamodbReadRules...), dynamodbWriteRules...),
+	},
+}
+
+// CompileAccess compiles the access intents to least-privilege statements, resources given by name are in the account of oidc provider,
+// and in the region of access or the region of EKS oidc provider.
+// The statements with the same resources and conditions are merged, and their actions are sorted, so the result is deterministic
+func CompileAccess(oidcProviderArn string, access []irsav1alpha1.AccessSpec) ([]irsav1alpha1.StatementSpec, error) {
+	var statements []irsav1alpha1.StatementSpec
+	for idx := range access {
+		a := &access[idx]
+		rules, ok := accessCatalog[a.Service][a.Mode]
+		if !ok {
+			return nil, errors.Errorf("access %d: mode %s is not supported by service %s", idx, a.Mode, a.Service)
+		}
+		t := accessTarget{
+			partition:      PartitionByArn(oidcProviderArn),
+			region:         a.Region,
+			accountID:      AccountIDByArn(oidcProviderArn),
+			allowWildcards: a.AllowWildcards,
+		}
+		if t.region == "" {
+			t.region = RegionByOIDCProviderArn(oidcProviderArn)
+		}
+		for _, rule := range rules {
+			resources, condition, err := rule.resources(t, a)
+			if err != nil {
+				return nil, errors.Wrapf(err, "access %d", idx)
+			}
+			statements = mergeStatement(statements, irsav1alpha1.StatementSpec{
+				Resource:  resources,
+				Action:    rule.actions,
+				Effect:    string(StatementAllow),
+				Condition: condition,
+			})
+		}
+	}
+	return statements, nil
+}
+
+// mergeStatement merges the actions of statement into the statement with the same resources and condition
+func mergeStatement(statements []irsav1alpha1.StatementSpec, statement irsav1alpha1.StatementSpec) []irsav1alpha1.StatementSpec {
+	for idx := range statements {
+		s := &statements[idx]
+		if reflect.DeepEqual(s.Resource, statement.Resource) && reflect.DeepEqual(s.Condition, statement.Condition) {
+			s.Action = sortedUnion(s.Action, statement.Action)
+			return statements
+		}
+	}
+	statement.Action = sortedUnion(nil, statement.Action)
+	return append(statements, statement)
+}
+
+func sortedUnion(a, b []string) []string {
+	set := make(map[string]struct{}, len(a)+len(b))
+	res := make([]string, 0, len(a)+len(b))
+	for _, s := range append(append([]string{}, a...), b...) {
+		if _, ok := set[s]; !ok {
+			set[s] = struct{}{}
+			res = append(res, s)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+)
+
+func TestCompileAccess(t *testing.T) {
+	oidc := "arn:aws:iam::000000000000:oidc-provider/oidc.eks.us-west-2.amazonaws.com/id/ABC"
+	tests := []struct {
+		name    string
+		oidc    string
+		access  []irsav1alpha1.AccessSpec
+		want    []irsav1alpha1.StatementSpec
+		wantErr bool
+	}{
+		{
+			name: "s3 read with prefix",
+			oidc: oidc,
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessS3, Mode: irsav1alpha1.AccessRead, Bucket: "foo", Prefix: "bar/"},
+			},
+			want: []irsav1alpha1.StatementSpec{
+				{
+					Resource:  []string{"arn:aws:s3:::foo"},
+					Action:    []string{"s3:ListBucket"},
+					Effect:    "Allow",
+					Condition: irsav1alpha1.StatementConditionSpec{"StringLike": {"s3:prefix": "bar/*"}},
+				},
+				{
+					Resource: []string{"arn:aws:s3:::foo/bar/*"},
+					Action:   []string{"s3:GetObject"},
+					Effect:   "Allow",
+				},
+			},
+		},
+		{
+			name: "statements of the same resources are merged",
+			oidc: oidc,
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessSQS, Mode: irsav1alpha1.AccessConsume, Queue: "jobs"},
+				{Service: irsav1alpha1.AccessSQS, Mode: irsav1alpha1.AccessProduce, Queue: "arn:aws:sqs:us-west-2:000000000000:jobs"},
+			},
+			want: []irsav1alpha1.StatementSpec{
+				{
+					Resource: []string{"arn:aws:sqs:us-west-2:000000000000:jobs"},
+					Action:   []string{"sqs:ChangeMessageVisibility", "sqs:DeleteMessage", "sqs:GetQueueAttributes", "sqs:GetQueueUrl", "sqs:ReceiveMessage", "sqs:SendMessage"},
+					Effect:   "Allow",
+				},
+			},
+		},
+		{
+			name: "secrets and tables",
+			oidc: "arn:aws-cn:iam::000000000000:oidc-provider/test",
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessSecretsManager, Mode: irsav1alpha1.AccessRead, Secret: "db", Region: "cn-north-1"},
+				{Service: irsav1alpha1.AccessDynamoDB, Mode: irsav1alpha1.AccessRead, Table: "users", Region: "cn-north-1"},
+			},
+			want: []irsav1alpha1.StatementSpec{
+				{
+					Resource: []string{"arn:aws-cn:secretsmanager:cn-north-1:000000000000:secret:db-??????"},
+					Action:   []string{"secretsmanager:DescribeSecret", "secretsmanager:GetSecretValue"},
+					Effect:   "Allow",
+				},
+				{
+					Resource: []string{"arn:aws-cn:dynamodb:cn-north-1:000000000000:table/users", "arn:aws-cn:dynamodb:cn-north-1:000000000000:table/users/index/*"},
+					Action:   []string{"dynamodb:BatchGetItem", "dynamodb:ConditionCheckItem", "dynamodb:DescribeTable", "dynamodb:GetItem", "dynamodb:Query", "dynamodb:Scan"},
+					Effect:   "Allow",
+				},
+			},
+		},
+		{
+			name: "resources given by arn need no region",
+			oidc: "arn:aws:iam::000000000000:oidc-provider/test",
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessSNS, Mode: irsav1alpha1.AccessPublish, Topic: "arn:aws:sns:eu-west-1:000000000000:events"},
+			},
+			want: []irsav1alpha1.StatementSpec{
+				{
+					Resource: []string{"arn:aws:sns:eu-west-1:000000000000:events"},
+					Action:   []string{"sns:Publish"},
+					Effect:   "Allow",
+				},
+			},
+		},
+		{
+			name: "region is required for resources given by name outside eks",
+			oidc: "arn:aws:iam::000000000000:oidc-provider/test",
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessSQS, Mode: irsav1alpha1.AccessConsume, Queue: "jobs"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcards are rejected by default",
+			oidc: oidc,
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessS3, Mode: irsav1alpha1.AccessRead, Bucket: "*"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcards in arn are rejected by default",
+			oidc: oidc,
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessDynamoDB, Mode: irsav1alpha1.AccessRead, Table: "arn:aws:dynamodb:us-west-2:000000000000:table/*"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcards in region are rejected by default",
+			oidc: oidc,
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessSQS, Mode: irsav1alpha1.AccessConsume, Queue: "jobs", Region: "*"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcards are allowed explicitly",
+			oidc: oidc,
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessSQS, Mode: irsav1alpha1.AccessProduce, Queue: "jobs-?", AllowWildcards: true},
+			},
+			want: []irsav1alpha1.StatementSpec{
+				{
+					Resource: []string{"arn:aws:sqs:us-west-2:000000000000:jobs-?"},
+					Action:   []string{"sqs:GetQueueAttributes", "sqs:GetQueueUrl", "sqs:SendMessage"},
+					Effect:   "Allow",
+				},
+			},
+		},
+		{
+			name: "unsupported mode",
+			oidc: oidc,
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessSNS, Mode: irsav1alpha1.AccessRead, Topic: "events"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing resource",
+			oidc: oidc,
+			access: []irsav1alpha1.AccessSpec{
+				{Service: irsav1alpha1.AccessS3, Mode: irsav1alpha1.AccessWrite},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompileAccess(tt.oidc, tt.access)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompileAccess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CompileAccess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -21,42 +21,81 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"domc.me/irsa-controller/api/v1alpha1"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
 )
 
+// maxPolicyVersions is the max count of versions of a customer managed policy
+const maxPolicyVersions = 5
+
 type IamClient struct {
 	prefix         string
 	clusterName    string
 	additionalTags map[string]string
 	iamClient      iamiface.IAMAPI
+	stsClient      stsiface.STSAPI
+
+	trustPolicySizeLimit int
+
+	// callerArn caches the arn of the aws credentials, which determines the partition and account of customer managed policies
+	mu        sync.Mutex
+	callerArn string
 }
 
 func NewIamClient(clusterName, iamRolePrefix string, additionalTagsArgs []string, config *AWSConfig) *IamClient {
+	session := newSession()
+	return NewIamClientWithAPI(clusterName, iamRolePrefix, additionalTagsArgs, iam.New(session, newAWSConfig(config)), sts.New(session, newAWSConfig(config)))
+}
+
+func newAWSConfig(config *AWSConfig) *aws.Config {
 	awsconf := aws.NewConfig()
 	if config != nil {
 		awsconf = aws.NewConfig().WithEndpoint(config.Endpoint).WithRegion(config.Region).WithDisableSSL(config.DisableSSL).WithCredentials(credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey, ""))
 	}
-	session := session.New()
-	return NewIamClientWithIamAPI(clusterName, iamRolePrefix, additionalTagsArgs, iam.New(session, awsconf))
+	return awsconf
 }
 
 func NewIamClientWithIamAPI(clusterName, iamRolePrefix string, additionalTagsArgs []string, iamClient iamiface.IAMAPI) *IamClient {
+	return NewIamClientWithAPI(clusterName, iamRolePrefix, additionalTagsArgs, iamClient, nil)
+}
+
+func NewIamClientWithAPI(clusterName, iamRolePrefix string, additionalTagsArgs []string, iamClient iamiface.IAMAPI, stsClient stsiface.STSAPI) *IamClient {
 	return &IamClient{
 		prefix:         iamRolePrefix,
 		clusterName:    clusterName,
 		iamClient:      iamClient,
+		stsClient:      stsClient,
 		additionalTags: parseAdditionalTagsArgs(additionalTagsArgs),
+
+		trustPolicySizeLimit: TrustPolicySizeLimit,
+	}
+}
+
+// SetTrustPolicySizeLimit changes the max size of trust policies, which can be raised up to MaxTrustPolicySizeLimit by a quota request,
+// the default limit is kept if it is not positive
+func (c *IamClient) SetTrustPolicySizeLimit(limit int) {
+	if limit > 0 {
+		c.trustPolicySizeLimit = limit
 	}
 }
 
+// TrustPolicySizeLimit returns the max size of trust policies
+func (c *IamClient) TrustPolicySizeLimit() int {
+	return c.trustPolicySizeLimit
+}
+
 func parseAdditionalTagsArgs(args []string) map[string]string {
 	at := make(map[string]string)
 	for _, arg := range args {
@@ -72,8 +111,19 @@ func parseAdditionalTagsArgs(args []string) map[string]string {
 // Create creates aws iam role in aws account and attaches managed policies arn to role
 // also create inline policy if defined in irsa
 // returns arn of aws iam role and arn of inline policy if inline policy is created
-func (c *IamClient) Create(ctx context.Context, oidcProvider string, irsa *v1alpha1.IamRoleServiceAccount) (string, error) {
-	iamRole := NewIamRole(oidcProvider, irsa, c.additionalTags)
+func (c *IamClient) Create(ctx context.Context, oidcProvider string, irsa *v1alpha1.IamRoleServiceAccount, vars PolicyVariables) (string, error) {
+	if policy := irsa.Spec.Policy; policy != nil && policy.InlinePolicyDocument != "" {
+		if _, err := ParseRoleDocument(policy.InlinePolicyDocument); err != nil {
+			return "", err
+		}
+	}
+	iamRole, err := NewIamRole(oidcProvider, irsa, c.IrsaRoleTags(irsa), vars)
+	if err != nil {
+		return "", err
+	}
+	if err := iamRole.CheckPoliciesSize(c.trustPolicySizeLimit); err != nil {
+		return "", err
+	}
 
 	assumeRoleDocument, err := iamRole.AssumeRolePolicy.AssumeRoleDocumentPolicyDocument()
 	if err != nil {
@@ -108,11 +158,21 @@ func (c *IamClient) Create(ctx context.Context, oidcProvider string, irsa *v1alp
 			return createdRoleArn, errors.Wrap(err, "Create inline policy")
 		}
 	}
+	for policyName, policy := range iamRole.InlinePolicies {
+		if err := c.PutInlinePolicy(ctx, roleName, policyName, policy); err != nil {
+			return createdRoleArn, errors.Wrap(err, "Create named inline policy")
+		}
+	}
 
 	// append managed policies and inline policy into role
 	if err := c.AttachRolePolicy(ctx, roleName, iamRole.ManagedPolicies); err != nil {
 		return createdRoleArn, errors.Wrap(err, "Attach managed policies failed")
 	}
+	for idx, policy := range iamRole.OwnedPolicies {
+		if err := c.CreateOwnedPolicy(ctx, createdRoleArn, idx, policy); err != nil {
+			return createdRoleArn, errors.Wrap(err, "Create owned managed policy failed")
+		}
+	}
 
 	return createdRoleArn, nil
 }
@@ -152,6 +212,10 @@ func (c *IamClient) DetachRolePolicy(ctx context.Context, roleName string, polic
 }
 
 func (c *IamClient) UpdateAssumePolicy(ctx context.Context, roleName string, assumePolicy *AssumeRoleDocument) error {
+	// detect the quota before updating, aws returns a LimitExceeded error without the size
+	if err := assumePolicy.CheckSize(c.trustPolicySizeLimit); err != nil {
+		return err
+	}
 	doc, err := assumePolicy.AssumeRoleDocumentPolicyDocument()
 	if err != nil {
 		return errors.Wrap(err, "Marshal assume policy failed")
@@ -178,12 +242,28 @@ func (c *IamClient) UpdateTags(ctx context.Context, roleName string, tags map[st
 	return nil
 }
 
+// RemoveTags removes the tags of keys from iam role
+func (c *IamClient) RemoveTags(ctx context.Context, roleName string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.iamClient.UntagRoleWithContext(ctx, &iam.UntagRoleInput{
+		RoleName: aws.String(roleName),
+		TagKeys:  aws.StringSlice(keys),
+	})
+	return errors.Wrap(err, "Untag iam role failed")
+}
+
 func (c *IamClient) UpdatePolicy(ctx context.Context, policyArn string, policy *RoleDocument) error {
 	policyDocument, err := policy.RoleDocumentPolicyDocument()
 	if err != nil {
 		return errors.Wrap(err, "Update policy failed")
 	}
 
+	if err := c.prunePolicyVersions(ctx, policyArn, maxPolicyVersions-1); err != nil {
+		return errors.Wrap(err, "Update policy failed")
+	}
+
 	_, err = c.iamClient.CreatePolicyVersionWithContext(ctx, &iam.CreatePolicyVersionInput{
 		PolicyArn:      aws.String(policyArn),
 		PolicyDocument: aws.String(policyDocument),
@@ -192,6 +272,212 @@ func (c *IamClient) UpdatePolicy(ctx context.Context, policyArn string, policy *
 	return errors.Wrap(err, "Update policy failed")
 }
 
+// prunePolicyVersions deletes the oldest non-default versions of policy until the count of versions is not over than keep
+func (c *IamClient) prunePolicyVersions(ctx context.Context, policyArn string, keep int) error {
+	output, err := c.iamClient.ListPolicyVersionsWithContext(ctx, &iam.ListPolicyVersionsInput{
+		PolicyArn: aws.String(policyArn),
+	})
+	if err != nil {
+		return errors.Wrap(err, "List policy versions failed")
+	}
+	var versions []*iam.PolicyVersion
+	for _, version := range output.Versions {
+		if version.IsDefaultVersion == nil || !*version.IsDefaultVersion {
+			versions = append(versions, version)
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return aws.TimeValue(versions[i].CreateDate).Before(aws.TimeValue(versions[j].CreateDate))
+	})
+	for idx := 0; idx < len(output.Versions)-keep && idx < len(versions); idx++ {
+		if _, err := c.iamClient.DeletePolicyVersionWithContext(ctx, &iam.DeletePolicyVersionInput{
+			PolicyArn: aws.String(policyArn),
+			VersionId: versions[idx].VersionId,
+		}); err != nil {
+			return errors.Wrap(err, "Delete policy version failed")
+		}
+	}
+	return nil
+}
+
+// CreatePolicy creates a customer managed policy which is managed by irsa-controller, returns the arn of policy
+func (c *IamClient) CreatePolicy(ctx context.Context, policyName, description string, policy *RoleDocument) (string, error) {
+	return c.createPolicy(ctx, policyName, description, policy, nil)
+}
+
+func (c *IamClient) createPolicy(ctx context.Context, policyName, description string, policy *RoleDocument, extraTags map[string]string) (string, error) {
+	policyDocument, err := policy.RoleDocumentPolicyDocument()
+	if err != nil {
+		return "", errors.Wrap(err, "Marshal policy failed")
+	}
+	tags := make(map[string]string, len(c.additionalTags)+len(extraTags)+1)
+	for k, v := range c.additionalTags {
+		tags[k] = v
+	}
+	for k, v := range extraTags {
+		tags[k] = v
+	}
+	tags[IrsaContollerManagedTagKey] = IrsaContollerManagedTagVal
+	input := &iam.CreatePolicyInput{
+		PolicyName:     aws.String(policyName),
+		PolicyDocument: aws.String(policyDocument),
+		Tags:           getIamRoleTags(tags),
+	}
+	if description != "" {
+		input.Description = aws.String(description)
+	}
+	output, err := c.iamClient.CreatePolicyWithContext(ctx, input)
+	if err != nil {
+		return "", errors.Wrap(err, "Create policy failed")
+	}
+	return aws.StringValue(output.Policy.Arn), nil
+}
+
+// CreateOwnedPolicy creates the idx-th customer managed policy owned by the role and attaches it to the role,
+// the policy is updated if it already exists
+func (c *IamClient) CreateOwnedPolicy(ctx context.Context, roleArn string, idx int, policy *RoleDocument) error {
+	roleName := RoleNameByArn(roleArn)
+	policyArn := c.OwnedPolicyArn(roleArn, idx)
+	if _, err := c.createPolicy(ctx, c.OwnedPolicyName(roleName, idx), "", policy, map[string]string{OwnerRoleTagKey: roleName}); err != nil {
+		if !ErrAlreadyExists(err) {
+			return errors.Wrap(err, "Create owned policy failed")
+		}
+		// the policy is left by the last failed reconciliation, never overwrite the policy created by others
+		existing, err := c.GetPolicy(ctx, policyArn)
+		if err != nil {
+			return err
+		}
+		if !c.isOwnedPolicy(roleName, existing) {
+			return fmt.Errorf("Policy %s already exists and is not owned by role %s", policyArn, roleName)
+		}
+		if err := c.UpdatePolicy(ctx, policyArn, policy); err != nil {
+			return err
+		}
+	}
+	return c.AttachRolePolicy(ctx, roleName, []string{policyArn})
+}
+
+// DeleteOwnedPolicy detaches the idx-th customer managed policy owned by the role and deletes it
+func (c *IamClient) DeleteOwnedPolicy(ctx context.Context, roleArn string, idx int) error {
+	policyArn := c.OwnedPolicyArn(roleArn, idx)
+	if err := c.DetachRolePolicy(ctx, RoleNameByArn(roleArn), []string{policyArn}); err != nil {
+		return err
+	}
+	return c.DeletePolicy(ctx, policyArn)
+}
+
+// DeletePolicy deletes all of the non-default versions of policy and the policy
+func (c *IamClient) DeletePolicy(ctx context.Context, policyArn string) error {
+	if err := c.prunePolicyVersions(ctx, policyArn, 1); err != nil {
+		return errors.Wrap(err, "Delete policy failed")
+	}
+	_, err := c.iamClient.DeletePolicyWithContext(ctx, &iam.DeletePolicyInput{
+		PolicyArn: aws.String(policyArn),
+	})
+	return errors.Wrap(err, "Delete policy failed")
+}
+
+// GetPolicy returns the customer managed policy with the document of its default version
+func (c *IamClient) GetPolicy(ctx context.Context, policyArn string) (*Policy, error) {
+	policyOut, err := c.iamClient.GetPolicyWithContext(ctx, &iam.GetPolicyInput{
+		PolicyArn: aws.String(policyArn),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Get policy failed")
+	}
+	versionOut, err := c.iamClient.GetPolicyVersionWithContext(ctx, &iam.GetPolicyVersionInput{
+		PolicyArn: aws.String(policyArn),
+		VersionId: policyOut.Policy.DefaultVersionId,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Get policy version failed")
+	}
+	decoded, err := url.QueryUnescape(aws.StringValue(versionOut.PolicyVersion.Document))
+	if err != nil {
+		return nil, errors.Wrap(err, "Decode policy failed")
+	}
+	var doc RoleDocument
+	if err := json.Unmarshal([]byte(decoded), &doc); err != nil {
+		return nil, errors.Wrap(err, "Unmarshal policy failed")
+	}
+	res := &Policy{
+		PolicyArn: policyArn,
+		Document:  &doc,
+		Tags:      make(map[string]string, len(policyOut.Policy.Tags)),
+	}
+	for _, tag := range policyOut.Policy.Tags {
+		res.Tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return res, nil
+}
+
+// CheckPolicyExists returns error if the managed policy does not exist or cannot be gotten
+func (c *IamClient) CheckPolicyExists(ctx context.Context, policyArn string) error {
+	_, err := c.iamClient.GetPolicyWithContext(ctx, &iam.GetPolicyInput{
+		PolicyArn: aws.String(policyArn),
+	})
+	return errors.Wrap(err, "Get policy failed")
+}
+
+// ListPolicyRoles returns the names of iam roles which the policy is attached to
+func (c *IamClient) ListPolicyRoles(ctx context.Context, policyArn string) ([]string, error) {
+	var res []string
+	input := &iam.ListEntitiesForPolicyInput{
+		PolicyArn:    aws.String(policyArn),
+		EntityFilter: aws.String(iam.EntityTypeRole),
+	}
+	for {
+		output, err := c.iamClient.ListEntitiesForPolicyWithContext(ctx, input)
+		if err != nil {
+			return nil, errors.Wrap(err, "List entities for policy failed")
+		}
+		for _, role := range output.PolicyRoles {
+			res = append(res, aws.StringValue(role.RoleName))
+		}
+		if !aws.BoolValue(output.IsTruncated) {
+			break
+		}
+		input.Marker = output.Marker
+	}
+	return res, nil
+}
+
+// PolicyName returns the name of customer managed policy of IamPolicy in aws account
+func (c *IamClient) PolicyName(policy *v1alpha1.IamPolicy) string {
+	return policy.AwsIamPolicyName(c.prefix, c.clusterName)
+}
+
+// PolicyArn returns the arn of customer managed policy of IamPolicy in the account of aws credentials
+func (c *IamClient) PolicyArn(ctx context.Context, policy *v1alpha1.IamPolicy) (string, error) {
+	callerArn, err := c.getCallerArn(ctx)
+	if err != nil {
+		return "", err
+	}
+	return PolicyArnByArn(callerArn, c.PolicyName(policy)), nil
+}
+
+// getCallerArn returns the arn of aws credentials, the account never changes so it is cached after the first call
+func (c *IamClient) getCallerArn(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.callerArn != "" {
+		return c.callerArn, nil
+	}
+	if c.stsClient == nil {
+		return "", errors.New("Aws account is unknown without sts client")
+	}
+	identity, err := c.stsClient.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", errors.Wrap(err, "Get caller identity failed")
+	}
+	arn := aws.StringValue(identity.Arn)
+	if len(strings.SplitN(arn, ":", 6)) != 6 {
+		return "", fmt.Errorf("Invalid caller arn %q", arn)
+	}
+	c.callerArn = arn
+	return arn, nil
+}
+
 func (c *IamClient) UpdateInlinePolicy(ctx context.Context, roleName string, policy *RoleDocument) error {
 	policyDocument, err := policy.RoleDocumentPolicyDocument()
 	if err != nil {
@@ -207,7 +493,23 @@ func (c *IamClient) UpdateInlinePolicy(ctx context.Context, roleName string, pol
 	return errors.Wrap(err, "Update inline policy failed")
 }
 
-func (c *IamClient) transfer(role *iam.Role, managedPolicyArns []string, inlinePolicy *iam.PolicyDetail) (*IamRole, error) {
+// PutInlinePolicy creates or updates the inline policy named policyName in iam role
+func (c *IamClient) PutInlinePolicy(ctx context.Context, roleName, policyName string, policy *RoleDocument) error {
+	policyDocument, err := policy.RoleDocumentPolicyDocument()
+	if err != nil {
+		return errors.Wrap(err, "Put inline policy failed")
+	}
+
+	_, err = c.iamClient.PutRolePolicyWithContext(ctx, &iam.PutRolePolicyInput{
+		PolicyDocument: aws.String(policyDocument),
+		PolicyName:     aws.String(policyName),
+		RoleName:       aws.String(roleName),
+	})
+
+	return errors.Wrap(err, "Put inline policy failed")
+}
+
+func (c *IamClient) transfer(role *iam.Role, managedPolicyArns []string, inlinePolicies []*iam.PolicyDetail) (*IamRole, error) {
 	res := new(IamRole)
 	res.RoleArn = *role.Arn
 	res.RoleName = *role.RoleName
@@ -233,7 +535,10 @@ func (c *IamClient) transfer(role *iam.Role, managedPolicyArns []string, inlineP
 		}
 	}
 	res.ManagedPolicies = managedPolicyArns
-	if inlinePolicy != nil && inlinePolicy.PolicyDocument != nil {
+	for _, inlinePolicy := range inlinePolicies {
+		if inlinePolicy == nil || inlinePolicy.PolicyDocument == nil || inlinePolicy.PolicyName == nil {
+			continue
+		}
 		var docJson RoleDocument
 		decoded, err := url.QueryUnescape(*inlinePolicy.PolicyDocument)
 		if err != nil {
@@ -243,7 +548,14 @@ func (c *IamClient) transfer(role *iam.Role, managedPolicyArns []string, inlineP
 		if err != nil {
 			return nil, errors.Wrap(err, "Unmarshal inline policy failed")
 		}
-		res.InlinePolicy = &docJson
+		if *inlinePolicy.PolicyName == c.getInlinePolicyName(res.RoleName) {
+			res.InlinePolicy = &docJson
+			continue
+		}
+		if res.InlinePolicies == nil {
+			res.InlinePolicies = make(map[string]*RoleDocument)
+		}
+		res.InlinePolicies[*inlinePolicy.PolicyName] = &docJson
 	}
 	return res, nil
 }
@@ -263,51 +575,99 @@ func (c *IamClient) Get(ctx context.Context, roleName string) (*IamRole, error)
 		return nil, errors.Wrap(err, "List attached role policies failed")
 	}
 	var managedPolicyArns []string
+	var ownedPolicies []*RoleDocument
 	for _, p := range policiesOut.AttachedPolicies {
-		managedPolicyArns = append(managedPolicyArns, *p.PolicyArn)
+		idx, ownedPolicy, err := c.getOwnedPolicy(ctx, roleName, p)
+		if err != nil {
+			return nil, errors.Wrap(err, "Get owned policy failed")
+		}
+		if ownedPolicy == nil {
+			managedPolicyArns = append(managedPolicyArns, *p.PolicyArn)
+			continue
+		}
+		for len(ownedPolicies) <= idx {
+			ownedPolicies = append(ownedPolicies, nil)
+		}
+		ownedPolicies[idx] = ownedPolicy.Document
 	}
 
-	inlinePolicyName := c.getInlinePolicyName(roleName)
-	ipo, err := c.iamClient.GetRolePolicyWithContext(ctx, &iam.GetRolePolicyInput{
-		RoleName:   aws.String(roleName),
-		PolicyName: aws.String(inlinePolicyName),
-	})
-	// err is not no such entities
-	if err != nil && !ErrIsNotFound(err) {
-		return nil, errors.Wrap(err, "Get inline policy failed")
+	var inlinePolicyDetails []*iam.PolicyDetail
+	listRolePoliciesInput := &iam.ListRolePoliciesInput{
+		RoleName: aws.String(roleName),
 	}
-	var inlinePolicyDetail *iam.PolicyDetail
-	// not found inline policy
-	if ipo != nil {
-		inlinePolicyDetail = new(iam.PolicyDetail)
-
-		inlinePolicyDetail.PolicyDocument = ipo.PolicyDocument
-		inlinePolicyDetail.PolicyName = ipo.PolicyName
+	for {
+		rolePolicies, err := c.iamClient.ListRolePoliciesWithContext(ctx, listRolePoliciesInput)
+		if err != nil {
+			return nil, errors.Wrap(err, "List role policies failed")
+		}
+		for _, policyName := range rolePolicies.PolicyNames {
+			ipo, err := c.iamClient.GetRolePolicyWithContext(ctx, &iam.GetRolePolicyInput{
+				RoleName:   aws.String(roleName),
+				PolicyName: policyName,
+			})
+			if err != nil {
+				// policy is deleted after listed
+				if ErrIsNotFound(err) {
+					continue
+				}
+				return nil, errors.Wrap(err, "Get inline policy failed")
+			}
+			inlinePolicyDetails = append(inlinePolicyDetails, &iam.PolicyDetail{
+				PolicyName:     ipo.PolicyName,
+				PolicyDocument: ipo.PolicyDocument,
+			})
+		}
+		if rolePolicies.IsTruncated == nil || !*rolePolicies.IsTruncated {
+			break
+		}
+		listRolePoliciesInput.Marker = rolePolicies.Marker
 	}
-	iam, err := c.transfer(output.Role, managedPolicyArns, inlinePolicyDetail)
+	iam, err := c.transfer(output.Role, managedPolicyArns, inlinePolicyDetails)
 	if err != nil {
 		return nil, errors.Wrap(err, "Transfer role failed")
 	}
+	iam.OwnedPolicies = ownedPolicies
 	return iam, nil
 }
 
-func (c *IamClient) AllowServiceAccountAccess(ctx context.Context, role *IamRole, oidcProviderArn, namespace, serviceAccountName string) error {
-	policy := NewAssumeRolePolicy(oidcProviderArn, namespace, serviceAccountName)
-	role.AssumeRolePolicy.Statement = append(role.AssumeRolePolicy.Statement, policy.Statement...)
-	doc, err := role.AssumeRolePolicy.AssumeRoleDocumentPolicyDocument()
-	if err != nil {
-		return errors.Wrap(err, "Allow serviceaccount access failed")
+func (c *IamClient) AllowServiceAccountAccess(ctx context.Context, role *IamRole, oidcProviderArn, audience, namespace, serviceAccountName string) error {
+	return c.AllowServiceAccountsAccess(ctx, role, oidcProviderArn, audience, []types.NamespacedName{{Namespace: namespace, Name: serviceAccountName}})
+}
+
+// AllowServiceAccountsAccess adds the subjects of service accounts into the consolidated trust statement of oidc provider and audience
+func (c *IamClient) AllowServiceAccountsAccess(ctx context.Context, role *IamRole, oidcProviderArn, audience string, serviceAccounts []types.NamespacedName) error {
+	if role.AssumeRolePolicy == nil {
+		role.AssumeRolePolicy = &AssumeRoleDocument{Version: "2012-10-17"}
 	}
-	_, err = c.iamClient.UpdateAssumeRolePolicyWithContext(ctx, &iam.UpdateAssumeRolePolicyInput{
-		RoleName:       aws.String(role.RoleName),
-		PolicyDocument: aws.String(doc),
-	})
-	if err != nil {
+	subjects := make([]string, 0, len(serviceAccounts))
+	for _, sa := range serviceAccounts {
+		subjects = append(subjects, ServiceAccountSubject(sa.Namespace, sa.Name))
+	}
+	role.AssumeRolePolicy.AllowSubjects(oidcProviderArn, audience, subjects)
+	if err := c.UpdateAssumePolicy(ctx, role.RoleName, role.AssumeRolePolicy); err != nil {
 		return errors.Wrap(err, "Allow access update assume role policy failed")
 	}
 	return nil
 }
 
+// RevokeServiceAccountsAccess removes the subjects of service accounts from the trust statements of oidc provider
+func (c *IamClient) RevokeServiceAccountsAccess(ctx context.Context, role *IamRole, oidcProviderArn string, serviceAccounts []types.NamespacedName) error {
+	if role.AssumeRolePolicy == nil {
+		return nil
+	}
+	subjects := make([]string, 0, len(serviceAccounts))
+	for _, sa := range serviceAccounts {
+		subjects = append(subjects, ServiceAccountSubject(sa.Namespace, sa.Name))
+	}
+	if !role.AssumeRolePolicy.RevokeSubjects(oidcProviderArn, subjects) {
+		return nil
+	}
+	if err := c.UpdateAssumePolicy(ctx, role.RoleName, role.AssumeRolePolicy); err != nil {
+		return errors.Wrap(err, "Revoke access update assume role policy failed")
+	}
+	return nil
+}
+
 func (c *IamClient) Delete(ctx context.Context, roleArn string) error {
 	roleName := RoleNameByArn(roleArn)
 
@@ -335,7 +695,7 @@ func (c *IamClient) Delete(ctx context.Context, roleArn string) error {
 		}
 	}
 
-	// detach managed role
+	// detach managed role, and delete the policies owned by role
 	for _, policy := range managedPolicies.AttachedPolicies {
 		if _, err := c.iamClient.DetachRolePolicyWithContext(ctx, &iam.DetachRolePolicyInput{
 			RoleName:  aws.String(roleName),
@@ -343,6 +703,15 @@ func (c *IamClient) Delete(ctx context.Context, roleArn string) error {
 		}); err != nil {
 			return errors.Wrap(err, "Detach role policy failed")
 		}
+		_, ownedPolicy, err := c.getOwnedPolicy(ctx, roleName, policy)
+		if err != nil {
+			return errors.Wrap(err, "Get owned policy failed")
+		}
+		if ownedPolicy != nil {
+			if err := c.DeletePolicy(ctx, *policy.PolicyArn); err != nil {
+				return errors.Wrap(err, "Delete owned policy failed")
+			}
+		}
 	}
 
 	_, err = c.iamClient.DeleteRoleWithContext(ctx, &iam.DeleteRoleInput{
@@ -355,9 +724,14 @@ func (c *IamClient) Delete(ctx context.Context, roleArn string) error {
 }
 
 func (c *IamClient) DeleteInlinePolicy(ctx context.Context, roleName string) error {
+	return c.DeleteNamedInlinePolicy(ctx, roleName, c.getInlinePolicyName(roleName))
+}
+
+// DeleteNamedInlinePolicy deletes the inline policy named policyName in iam role
+func (c *IamClient) DeleteNamedInlinePolicy(ctx context.Context, roleName, policyName string) error {
 	_, err := c.iamClient.DeleteRolePolicyWithContext(ctx, &iam.DeleteRolePolicyInput{
 		RoleName:   aws.String(roleName),
-		PolicyName: aws.String(c.getInlinePolicyName(roleName)),
+		PolicyName: aws.String(policyName),
 	})
 	if err != nil {
 		return errors.Wrap(err, "Delete inline policy failed")
@@ -365,14 +739,110 @@ func (c *IamClient) DeleteInlinePolicy(ctx context.Context, roleName string) err
 	return nil
 }
 
+// InlinePolicyName returns the name of the default inline policy in iam role
+func (c *IamClient) InlinePolicyName(roleName string) string {
+	return c.getInlinePolicyName(roleName)
+}
+
 func (c *IamClient) getInlinePolicyName(roleName string) string {
 	return fmt.Sprintf("%s-inline-policy", roleName)
 }
 
+// OwnedPolicyName returns the name of the idx-th customer managed policy owned by the role
+func (c *IamClient) OwnedPolicyName(roleName string, idx int) string {
+	return fmt.Sprintf("%s-policy-%d", roleName, idx)
+}
+
+// OwnedPolicyArn returns the arn of the idx-th customer managed policy owned by the role,
+// the policy is in the same partition and account as the role
+func (c *IamClient) OwnedPolicyArn(roleArn string, idx int) string {
+	return PolicyArnByArn(roleArn, c.OwnedPolicyName(RoleNameByArn(roleArn), idx))
+}
+
+// ownedPolicyIndex returns the index in the name of the customer managed policy if it is named as the policy owned by the role
+func (c *IamClient) ownedPolicyIndex(roleName, policyName string) (int, bool) {
+	prefix := c.OwnedPolicyName(roleName, 0)
+	prefix = prefix[:len(prefix)-1]
+	if !strings.HasPrefix(policyName, prefix) {
+		return 0, false
+	}
+	idx, err := strconv.Atoi(strings.TrimPrefix(policyName, prefix))
+	if err != nil || idx < 0 || idx >= MaxOwnedPolicies {
+		return 0, false
+	}
+	return idx, true
+}
+
+// getOwnedPolicy returns the index and the policy if the attached policy is owned by the role, or nil if it is not owned.
+// The name only is not trusted, the policy must be tagged by irsa-controller with the owner role
+func (c *IamClient) getOwnedPolicy(ctx context.Context, roleName string, attached *iam.AttachedPolicy) (int, *Policy, error) {
+	idx, ok := c.ownedPolicyIndex(roleName, aws.StringValue(attached.PolicyName))
+	if !ok {
+		return 0, nil, nil
+	}
+	policy, err := c.GetPolicy(ctx, aws.StringValue(attached.PolicyArn))
+	if err != nil {
+		return 0, nil, err
+	}
+	if !c.isOwnedPolicy(roleName, policy) {
+		return 0, nil, nil
+	}
+	return idx, policy, nil
+}
+
+// isOwnedPolicy returns true if the policy is created by irsa-controller for the role
+func (c *IamClient) isOwnedPolicy(roleName string, policy *Policy) bool {
+	return policy.IsManagedByIrsaController() && policy.Tags[OwnerRoleTagKey] == roleName
+}
+
+// ClusterName returns the name of cluster which the iam roles are created for
+func (c *IamClient) ClusterName() string {
+	return c.clusterName
+}
+
 func (c *IamClient) GetAdditionalTags() map[string]string {
 	return c.additionalTags
 }
 
+// RoleTags returns the tags of iam roles created by irsa-controller, which are the additional tags
+// and the tag recording the cluster depends on the role
+func (c *IamClient) RoleTags() map[string]string {
+	tags := make(map[string]string, len(c.additionalTags)+1)
+	for k, v := range c.additionalTags {
+		tags[k] = v
+	}
+	tags[ClusterTagKey(c.clusterName)] = c.clusterName
+	return tags
+}
+
+// IrsaRoleTags returns the tags of the iam role created by irsa-controller for irsa,
+// which are the role tags and the cluster tags of irsa
+func (c *IamClient) IrsaRoleTags(irsa *v1alpha1.IamRoleServiceAccount) map[string]string {
+	tags := c.RoleTags()
+	for k, v := range c.ClusterTags(irsa) {
+		tags[k] = v
+	}
+	return tags
+}
+
+// ClusterTags returns the tags recording the clusters which depend on the iam role of irsa, which are this cluster
+// and the clusters of the oidc providers trusted by irsa. The value is the name of this cluster,
+// so that the tags added by the controllers of other clusters sharing the role can be told apart
+func (c *IamClient) ClusterTags(irsa *v1alpha1.IamRoleServiceAccount) map[string]string {
+	tags := map[string]string{ClusterTagKey(c.clusterName): c.clusterName}
+	if irsa.Spec.Trust != nil {
+		for _, provider := range irsa.Spec.Trust.OIDCProviders {
+			tags[ClusterTagKey(provider.Cluster)] = c.clusterName
+		}
+	}
+	return tags
+}
+
+// IsOwnedClusterTag returns true if the tag is a cluster tag added by the controller of this cluster
+func (c *IamClient) IsOwnedClusterTag(key, value string) bool {
+	return strings.HasPrefix(key, ClusterTagKeyPrefix) && value == c.clusterName
+}
+
 func getIamRoleTags(tags map[string]string) []*iam.Tag {
 	var res []*iam.Tag
 	for k, v := range tags {
@@ -19,6 +19,7 @@ package aws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"reflect"
 	"strings"
@@ -223,7 +224,7 @@ func TestIamClient_Create(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := client
-			got, err := c.Create(tt.args.ctx, tt.args.oidcProvider, tt.args.irsa)
+			got, err := c.Create(tt.args.ctx, tt.args.oidcProvider, tt.args.irsa, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("IamClient.Create() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -268,7 +269,7 @@ func TestIamClient_Create(t *testing.T) {
 						t.Errorf("Unmarshal inline policy failed: %v", err)
 					}
 
-					wantRole := NewIamRole(testOidcProviderArn, tt.args.irsa, nil)
+					wantRole, _ := NewIamRole(testOidcProviderArn, tt.args.irsa, nil, nil)
 
 					if !reflect.DeepEqual(gotIpc, wantRole.InlinePolicy) {
 						t.Errorf("IamClient.Create() policy got = %v, want = %v", gotIpc, wantRole.InlinePolicy)
@@ -359,6 +360,44 @@ func TestIamClient_RoleName(t *testing.T) {
 	}
 }
 
+func TestIamClient_PolicyArn(t *testing.T) {
+	policy := &v1alpha1.IamPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "ns",
+		},
+	}
+	tests := []struct {
+		name    string
+		sts     *MockedStsClient
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Account of credentials",
+			sts:  NewMockedStsClient("111111111111"),
+			want: "arn:aws:iam::111111111111:policy/pre-cls-ns-name-policy",
+		},
+		{
+			name:    "Credentials are invalid",
+			sts:     &MockedStsClient{err: errors.New("InvalidClientTokenId")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewIamClientWithAPI("cls", "pre", nil, NewMockedIamClient(), tt.sts)
+			got, err := c.PolicyArn(context.Background(), policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IamClient.PolicyArn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IamClient.PolicyArn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestIamClient_AttachRolePolicy(t *testing.T) {
 
 	t.Parallel()
@@ -995,7 +1034,7 @@ func TestIamClient_AllowServiceAccountAccess(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := client
-			if err := c.AllowServiceAccountAccess(tt.args.ctx, tt.args.role, tt.args.oidcProviderArn, tt.args.namespace, tt.args.serviceAccountName); (err != nil) != tt.wantErr {
+			if err := c.AllowServiceAccountAccess(tt.args.ctx, tt.args.role, tt.args.oidcProviderArn, DefaultAudience, tt.args.namespace, tt.args.serviceAccountName); (err != nil) != tt.wantErr {
 				t.Errorf("IamClient.AllowServiceAccountAccess() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
@@ -1015,7 +1054,7 @@ func TestIamClient_AllowServiceAccountAccess(t *testing.T) {
 			if err != nil {
 				t.Errorf("Unmarshal assume role policy failed: %v", err)
 			}
-			if !assumeRolePolicy.IsAllowOIDC(tt.args.oidcProviderArn, tt.args.namespace, tt.args.serviceAccountName) {
+			if !assumeRolePolicy.IsAllowOIDC(tt.args.oidcProviderArn, DefaultAudience, tt.args.namespace, tt.args.serviceAccountName) {
 				t.Errorf("Assume role policy want to be allowed oidc, but not")
 			}
 		})
@@ -0,0 +1,62 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/pkg/errors"
+)
+
+// DiscoverOIDCProviderArn returns the arn of iam oidc provider of the service account issuer in the account of caller
+func DiscoverOIDCProviderArn(ctx context.Context, config *AWSConfig, issuer string) (string, error) {
+	session := newSession()
+	awsconf := newAWSConfig(config)
+	return discoverOIDCProviderArn(ctx, iam.New(session, awsconf), sts.New(session, awsconf), issuer)
+}
+
+func discoverOIDCProviderArn(ctx context.Context, iamAPI iamiface.IAMAPI, stsAPI stsiface.STSAPI, issuer string) (string, error) {
+	identity, err := stsAPI.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", errors.Wrap(err, "Get caller identity failed")
+	}
+	account := aws.StringValue(identity.Account)
+	want := fmt.Sprintf("arn:%s:iam::%s:oidc-provider/%s", PartitionByArn(aws.StringValue(identity.Arn)), account, IssuerHostpath(issuer))
+
+	output, err := iamAPI.ListOpenIDConnectProvidersWithContext(ctx, &iam.ListOpenIDConnectProvidersInput{})
+	if err != nil {
+		return "", errors.Wrap(err, "List iam oidc providers failed")
+	}
+	for _, provider := range output.OpenIDConnectProviderList {
+		if aws.StringValue(provider.Arn) == want {
+			return want, nil
+		}
+	}
+	return "", fmt.Errorf("Iam oidc provider of issuer %s is not found in account %s, create it or set oidcProviderArn", issuer, account)
+}
+
+// IssuerHostpath returns the issuer without scheme, which is the suffix of the arn of iam oidc provider
+func IssuerHostpath(issuer string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(issuer, "https://"), "/")
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+func TestDiscoverOIDCProviderArn(t *testing.T) {
+	providerArn := "arn:aws:iam::000000000000:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE"
+	mic := NewMockedIamClient()
+	for _, issuer := range []string{"https://oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE", "https://oidc.eks.us-east-1.amazonaws.com/id/OTHER"} {
+		if _, err := mic.CreateOpenIDConnectProviderWithContext(context.Background(), &iam.CreateOpenIDConnectProviderInput{Url: aws.String(issuer)}); err != nil {
+			t.Fatalf("Create oidc provider failed: %v", err)
+		}
+	}
+	tests := []struct {
+		name    string
+		account string
+		issuer  string
+		want    string
+		wantErr bool
+	}{
+		{name: "matched", account: "000000000000", issuer: "https://oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE", want: providerArn},
+		{name: "trailing slash", account: "000000000000", issuer: "https://oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE/", want: providerArn},
+		{name: "issuer not registered", account: "000000000000", issuer: "https://kubernetes.default.svc", wantErr: true},
+		{name: "other account", account: "111111111111", issuer: "https://oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := discoverOIDCProviderArn(context.Background(), mic, NewMockedStsClient(tt.account), tt.issuer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("discoverOIDCProviderArn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("discoverOIDCProviderArn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,24 +19,42 @@ package aws
 import (
 	"context"
 	"fmt"
+	"io"
+	"sort"
+	"time"
 
+	"domc.me/irsa-controller/pkg/utils/slices"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 )
 
 type MockedIamClient struct {
 	iamiface.IAMAPI
 	mockRoles            map[string]*iam.Role
-	mockRolePolicies     map[string][]*iam.Policy
+	mockRolePolicies     map[string]map[string]string
 	mockAttachedPolicies map[string][]*iam.AttachedPolicy
+	mockPolicies         map[string]*mockedPolicy
+	mockOIDCProviders    map[string]*iam.GetOpenIDConnectProviderOutput
+}
+
+type mockedPolicy struct {
+	policy   *iam.Policy
+	versions []*iam.PolicyVersion
 }
 
 func NewMockedIamClient() *MockedIamClient {
 	return &MockedIamClient{
 		mockRoles:            make(map[string]*iam.Role),
+		mockRolePolicies:     make(map[string]map[string]string),
 		mockAttachedPolicies: make(map[string][]*iam.AttachedPolicy),
+		mockPolicies:         make(map[string]*mockedPolicy),
+		mockOIDCProviders:    make(map[string]*iam.GetOpenIDConnectProviderOutput),
 	}
 }
 
@@ -74,30 +92,339 @@ func (m *MockedIamClient) ListAttachedRolePoliciesWithContext(ctx context.Contex
 
 func (m *MockedIamClient) ListRolePoliciesWithContext(ctx context.Context, input *iam.ListRolePoliciesInput, opts ...request.Option) (*iam.ListRolePoliciesOutput, error) {
 	res := &iam.ListRolePoliciesOutput{}
-	for _, policy := range m.mockRolePolicies[*input.RoleName] {
-		res.PolicyNames = append(res.PolicyNames, policy.PolicyName)
+	policyNames := make([]string, 0, len(m.mockRolePolicies[*input.RoleName]))
+	for policyName := range m.mockRolePolicies[*input.RoleName] {
+		policyNames = append(policyNames, policyName)
 	}
+	sort.Strings(policyNames)
+	res.PolicyNames = aws.StringSlice(policyNames)
 	return res, nil
 }
 
 func (m *MockedIamClient) GetRolePolicyWithContext(ctx context.Context, input *iam.GetRolePolicyInput, opts ...request.Option) (*iam.GetRolePolicyOutput, error) {
+	policyDocument, ok := m.mockRolePolicies[*input.RoleName][*input.PolicyName]
+	if !ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+	}
 	return &iam.GetRolePolicyOutput{
 		RoleName:       input.RoleName,
 		PolicyName:     input.PolicyName,
-		PolicyDocument: aws.String(`{"Version":"2012-10-17","Statement":[]}`),
+		PolicyDocument: aws.String(policyDocument),
 	}, nil
 }
 
+func (m *MockedIamClient) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	if _, ok := m.mockRolePolicies[*input.RoleName]; !ok {
+		m.mockRolePolicies[*input.RoleName] = make(map[string]string)
+	}
+	m.mockRolePolicies[*input.RoleName][*input.PolicyName] = *input.PolicyDocument
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (m *MockedIamClient) PutRolePolicyWithContext(ctx context.Context, input *iam.PutRolePolicyInput, opts ...request.Option) (*iam.PutRolePolicyOutput, error) {
+	return m.PutRolePolicy(input)
+}
+
 func (m *MockedIamClient) UpdateAssumeRolePolicyWithContext(ctx context.Context, input *iam.UpdateAssumeRolePolicyInput, opts ...request.Option) (*iam.UpdateAssumeRolePolicyOutput, error) {
 	m.mockRoles[*input.RoleName].AssumeRolePolicyDocument = input.PolicyDocument
 	return &iam.UpdateAssumeRolePolicyOutput{}, nil
 }
 
+func (m *MockedIamClient) TagRole(input *iam.TagRoleInput) (*iam.TagRoleOutput, error) {
+	role := m.mockRoles[*input.RoleName]
+	for _, tag := range input.Tags {
+		found := false
+		for _, got := range role.Tags {
+			if *got.Key == *tag.Key {
+				got.Value = tag.Value
+				found = true
+			}
+		}
+		if !found {
+			role.Tags = append(role.Tags, tag)
+		}
+	}
+	return &iam.TagRoleOutput{}, nil
+}
+
+func (m *MockedIamClient) UntagRoleWithContext(ctx context.Context, input *iam.UntagRoleInput, opts ...request.Option) (*iam.UntagRoleOutput, error) {
+	role := m.mockRoles[*input.RoleName]
+	var tags []*iam.Tag
+	for _, tag := range role.Tags {
+		if !slices.ContainsString(aws.StringValueSlice(input.TagKeys), *tag.Key) {
+			tags = append(tags, tag)
+		}
+	}
+	role.Tags = tags
+	return &iam.UntagRoleOutput{}, nil
+}
+
 func (m *MockedIamClient) DeleteRoleWithContext(ctx context.Context, input *iam.DeleteRoleInput, opts ...request.Option) (*iam.DeleteRoleOutput, error) {
 	delete(m.mockRoles, *input.RoleName)
+	delete(m.mockRolePolicies, *input.RoleName)
 	return &iam.DeleteRoleOutput{}, nil
 }
 
 func (m *MockedIamClient) DeleteRolePolicyWithContext(ctx context.Context, input *iam.DeleteRolePolicyInput, opts ...request.Option) (*iam.DeleteRolePolicyOutput, error) {
+	delete(m.mockRolePolicies[*input.RoleName], *input.PolicyName)
 	return &iam.DeleteRolePolicyOutput{}, nil
 }
+
+func (m *MockedIamClient) AttachRolePolicyWithContext(ctx context.Context, input *iam.AttachRolePolicyInput, opts ...request.Option) (*iam.AttachRolePolicyOutput, error) {
+	for _, attached := range m.mockAttachedPolicies[*input.RoleName] {
+		if *attached.PolicyArn == *input.PolicyArn {
+			return &iam.AttachRolePolicyOutput{}, nil
+		}
+	}
+	m.mockAttachedPolicies[*input.RoleName] = append(m.mockAttachedPolicies[*input.RoleName], &iam.AttachedPolicy{
+		PolicyArn:  input.PolicyArn,
+		PolicyName: aws.String(RoleNameByArn(*input.PolicyArn)),
+	})
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+func (m *MockedIamClient) DetachRolePolicyWithContext(ctx context.Context, input *iam.DetachRolePolicyInput, opts ...request.Option) (*iam.DetachRolePolicyOutput, error) {
+	attachedPolicies := m.mockAttachedPolicies[*input.RoleName]
+	for idx, attached := range attachedPolicies {
+		if *attached.PolicyArn == *input.PolicyArn {
+			m.mockAttachedPolicies[*input.RoleName] = append(attachedPolicies[:idx:idx], attachedPolicies[idx+1:]...)
+			return &iam.DetachRolePolicyOutput{}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+}
+
+func (m *MockedIamClient) CreatePolicyWithContext(ctx context.Context, input *iam.CreatePolicyInput, opts ...request.Option) (*iam.CreatePolicyOutput, error) {
+	policyArn := fmt.Sprintf("arn:aws:iam::000000000000:policy/%s", *input.PolicyName)
+	if _, ok := m.mockPolicies[policyArn]; ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeEntityAlreadyExistsException)
+	}
+	m.mockPolicies[policyArn] = &mockedPolicy{
+		policy: &iam.Policy{
+			Arn:              aws.String(policyArn),
+			PolicyName:       input.PolicyName,
+			DefaultVersionId: aws.String("v1"),
+			Tags:             input.Tags,
+		},
+		versions: []*iam.PolicyVersion{
+			{
+				VersionId:        aws.String("v1"),
+				Document:         input.PolicyDocument,
+				IsDefaultVersion: aws.Bool(true),
+				CreateDate:       aws.Time(time.Now()),
+			},
+		},
+	}
+	return &iam.CreatePolicyOutput{
+		Policy: m.mockPolicies[policyArn].policy,
+	}, nil
+}
+
+func (m *MockedIamClient) GetPolicyWithContext(ctx context.Context, input *iam.GetPolicyInput, opts ...request.Option) (*iam.GetPolicyOutput, error) {
+	policy, ok := m.mockPolicies[*input.PolicyArn]
+	if !ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+	}
+	return &iam.GetPolicyOutput{
+		Policy: policy.policy,
+	}, nil
+}
+
+func (m *MockedIamClient) GetPolicyVersionWithContext(ctx context.Context, input *iam.GetPolicyVersionInput, opts ...request.Option) (*iam.GetPolicyVersionOutput, error) {
+	policy, ok := m.mockPolicies[*input.PolicyArn]
+	if !ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+	}
+	for _, version := range policy.versions {
+		if *version.VersionId == *input.VersionId {
+			return &iam.GetPolicyVersionOutput{
+				PolicyVersion: version,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+}
+
+func (m *MockedIamClient) ListPolicyVersionsWithContext(ctx context.Context, input *iam.ListPolicyVersionsInput, opts ...request.Option) (*iam.ListPolicyVersionsOutput, error) {
+	policy, ok := m.mockPolicies[*input.PolicyArn]
+	if !ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+	}
+	return &iam.ListPolicyVersionsOutput{
+		Versions: policy.versions,
+	}, nil
+}
+
+func (m *MockedIamClient) CreatePolicyVersionWithContext(ctx context.Context, input *iam.CreatePolicyVersionInput, opts ...request.Option) (*iam.CreatePolicyVersionOutput, error) {
+	policy, ok := m.mockPolicies[*input.PolicyArn]
+	if !ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+	}
+	if len(policy.versions) >= 5 {
+		return nil, fmt.Errorf("%s", iam.ErrCodeLimitExceededException)
+	}
+	lastVersion := policy.versions[len(policy.versions)-1]
+	var lastVersionNum int
+	fmt.Sscanf(*lastVersion.VersionId, "v%d", &lastVersionNum)
+	version := &iam.PolicyVersion{
+		VersionId:        aws.String(fmt.Sprintf("v%d", lastVersionNum+1)),
+		Document:         input.PolicyDocument,
+		IsDefaultVersion: aws.Bool(false),
+		CreateDate:       aws.Time(time.Now()),
+	}
+	if aws.BoolValue(input.SetAsDefault) {
+		for _, v := range policy.versions {
+			v.IsDefaultVersion = aws.Bool(false)
+		}
+		version.IsDefaultVersion = aws.Bool(true)
+		policy.policy.DefaultVersionId = version.VersionId
+	}
+	policy.versions = append(policy.versions, version)
+	return &iam.CreatePolicyVersionOutput{
+		PolicyVersion: version,
+	}, nil
+}
+
+func (m *MockedIamClient) DeletePolicyVersionWithContext(ctx context.Context, input *iam.DeletePolicyVersionInput, opts ...request.Option) (*iam.DeletePolicyVersionOutput, error) {
+	policy, ok := m.mockPolicies[*input.PolicyArn]
+	if !ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+	}
+	for idx, version := range policy.versions {
+		if *version.VersionId == *input.VersionId {
+			if aws.BoolValue(version.IsDefaultVersion) {
+				return nil, fmt.Errorf("%s", iam.ErrCodeDeleteConflictException)
+			}
+			policy.versions = append(policy.versions[:idx:idx], policy.versions[idx+1:]...)
+			return &iam.DeletePolicyVersionOutput{}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+}
+
+func (m *MockedIamClient) DeletePolicyWithContext(ctx context.Context, input *iam.DeletePolicyInput, opts ...request.Option) (*iam.DeletePolicyOutput, error) {
+	policy, ok := m.mockPolicies[*input.PolicyArn]
+	if !ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+	}
+	if len(policy.versions) > 1 {
+		return nil, fmt.Errorf("%s", iam.ErrCodeDeleteConflictException)
+	}
+	delete(m.mockPolicies, *input.PolicyArn)
+	return &iam.DeletePolicyOutput{}, nil
+}
+
+func (m *MockedIamClient) ListEntitiesForPolicyWithContext(ctx context.Context, input *iam.ListEntitiesForPolicyInput, opts ...request.Option) (*iam.ListEntitiesForPolicyOutput, error) {
+	if _, ok := m.mockPolicies[*input.PolicyArn]; !ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+	}
+	res := &iam.ListEntitiesForPolicyOutput{}
+	roleNames := make([]string, 0, len(m.mockAttachedPolicies))
+	for roleName := range m.mockAttachedPolicies {
+		roleNames = append(roleNames, roleName)
+	}
+	sort.Strings(roleNames)
+	for _, roleName := range roleNames {
+		for _, attached := range m.mockAttachedPolicies[roleName] {
+			if *attached.PolicyArn == *input.PolicyArn {
+				res.PolicyRoles = append(res.PolicyRoles, &iam.PolicyRole{RoleName: aws.String(roleName)})
+			}
+		}
+	}
+	return res, nil
+}
+
+func (m *MockedIamClient) ListOpenIDConnectProvidersWithContext(ctx context.Context, input *iam.ListOpenIDConnectProvidersInput, opts ...request.Option) (*iam.ListOpenIDConnectProvidersOutput, error) {
+	arns := make([]string, 0, len(m.mockOIDCProviders))
+	for arn := range m.mockOIDCProviders {
+		arns = append(arns, arn)
+	}
+	sort.Strings(arns)
+	output := &iam.ListOpenIDConnectProvidersOutput{}
+	for _, arn := range arns {
+		output.OpenIDConnectProviderList = append(output.OpenIDConnectProviderList, &iam.OpenIDConnectProviderListEntry{Arn: aws.String(arn)})
+	}
+	return output, nil
+}
+
+func (m *MockedIamClient) CreateOpenIDConnectProviderWithContext(ctx context.Context, input *iam.CreateOpenIDConnectProviderInput, opts ...request.Option) (*iam.CreateOpenIDConnectProviderOutput, error) {
+	arn := fmt.Sprintf("arn:aws:iam::000000000000:oidc-provider/%s", IssuerHostpath(*input.Url))
+	if _, ok := m.mockOIDCProviders[arn]; ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeEntityAlreadyExistsException)
+	}
+	m.mockOIDCProviders[arn] = &iam.GetOpenIDConnectProviderOutput{
+		Url:            aws.String(IssuerHostpath(*input.Url)),
+		ClientIDList:   input.ClientIDList,
+		ThumbprintList: input.ThumbprintList,
+		Tags:           input.Tags,
+	}
+	return &iam.CreateOpenIDConnectProviderOutput{OpenIDConnectProviderArn: aws.String(arn)}, nil
+}
+
+func (m *MockedIamClient) GetOpenIDConnectProviderWithContext(ctx context.Context, input *iam.GetOpenIDConnectProviderInput, opts ...request.Option) (*iam.GetOpenIDConnectProviderOutput, error) {
+	provider, ok := m.mockOIDCProviders[*input.OpenIDConnectProviderArn]
+	if !ok {
+		return nil, fmt.Errorf("%s", iam.ErrCodeNoSuchEntityException)
+	}
+	return provider, nil
+}
+
+func (m *MockedIamClient) UpdateOpenIDConnectProviderThumbprintWithContext(ctx context.Context, input *iam.UpdateOpenIDConnectProviderThumbprintInput, opts ...request.Option) (*iam.UpdateOpenIDConnectProviderThumbprintOutput, error) {
+	m.mockOIDCProviders[*input.OpenIDConnectProviderArn].ThumbprintList = input.ThumbprintList
+	return &iam.UpdateOpenIDConnectProviderThumbprintOutput{}, nil
+}
+
+func (m *MockedIamClient) AddClientIDToOpenIDConnectProviderWithContext(ctx context.Context, input *iam.AddClientIDToOpenIDConnectProviderInput, opts ...request.Option) (*iam.AddClientIDToOpenIDConnectProviderOutput, error) {
+	provider := m.mockOIDCProviders[*input.OpenIDConnectProviderArn]
+	provider.ClientIDList = append(provider.ClientIDList, input.ClientID)
+	return &iam.AddClientIDToOpenIDConnectProviderOutput{}, nil
+}
+
+func (m *MockedIamClient) RemoveClientIDFromOpenIDConnectProviderWithContext(ctx context.Context, input *iam.RemoveClientIDFromOpenIDConnectProviderInput, opts ...request.Option) (*iam.RemoveClientIDFromOpenIDConnectProviderOutput, error) {
+	provider := m.mockOIDCProviders[*input.OpenIDConnectProviderArn]
+	provider.ClientIDList = aws.StringSlice(slices.RemoveString(aws.StringValueSlice(provider.ClientIDList), *input.ClientID))
+	return &iam.RemoveClientIDFromOpenIDConnectProviderOutput{}, nil
+}
+
+type MockedStsClient struct {
+	stsiface.STSAPI
+	account string
+	// err is returned by all of the apis if it is set
+	err error
+}
+
+func NewMockedStsClient(account string) *MockedStsClient {
+	return &MockedStsClient{account: account}
+}
+
+func (m *MockedStsClient) GetCallerIdentityWithContext(ctx context.Context, input *sts.GetCallerIdentityInput, opts ...request.Option) (*sts.GetCallerIdentityOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String(m.account),
+		Arn:     aws.String(fmt.Sprintf("arn:aws:iam::%s:user/irsa-controller", m.account)),
+		UserId:  aws.String("AIDAEXAMPLE"),
+	}, nil
+}
+
+type MockedS3Client struct {
+	s3iface.S3API
+	// objects is the content of objects by bucket/key
+	objects map[string][]byte
+	puts    int
+}
+
+func NewMockedS3Client() *MockedS3Client {
+	return &MockedS3Client{objects: make(map[string][]byte)}
+}
+
+func (m *MockedS3Client) PutObjectWithContext(ctx context.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[*input.Bucket+"/"+*input.Key] = body
+	m.puts++
+	return &s3.PutObjectOutput{}, nil
+}
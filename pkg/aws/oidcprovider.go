@@ -0,0 +1,167 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"domc.me/irsa-controller/pkg/utils/slices"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/pkg/errors"
+)
+
+// oidcRequestTimeout bounds each request to the issuer and the host of jwks_uri
+const oidcRequestTimeout = time.Second * 10
+
+// OIDCThumbprint returns the thumbprint of the top certificate served by the host of jwks_uri of the issuer,
+// which is required by iam oidc provider
+func OIDCThumbprint(ctx context.Context, issuerURL string) (string, error) {
+	return oidcThumbprint(ctx, issuerURL, &tls.Config{})
+}
+
+func oidcThumbprint(ctx context.Context, issuerURL string, tlsConfig *tls.Config) (string, error) {
+	// honour HTTPS_PROXY and NO_PROXY as the aws sdk does, the issuer may only be reachable through a proxy
+	client := &http.Client{Timeout: oidcRequestTimeout, Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(issuerURL, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return "", errors.Wrap(err, "Build openid configuration request failed")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "Get openid configuration failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Get openid configuration failed with status %s", resp.Status)
+	}
+	var openidConfig struct {
+		JwksURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&openidConfig); err != nil {
+		return "", errors.Wrap(err, "Decode openid configuration failed")
+	}
+	jwksURL, err := url.Parse(openidConfig.JwksURI)
+	if err != nil || jwksURL.Host == "" {
+		return "", fmt.Errorf("Invalid jwks_uri %q in openid configuration", openidConfig.JwksURI)
+	}
+	// the certificates are read from the response instead of dialing the host, so that the proxy is used as well
+	jwksReq, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL.String(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "Build jwks request failed")
+	}
+	jwksResp, err := client.Do(jwksReq)
+	if err != nil {
+		return "", errors.Wrap(err, "Connect to the host of jwks_uri failed")
+	}
+	jwksResp.Body.Close()
+	if jwksResp.TLS == nil || len(jwksResp.TLS.PeerCertificates) == 0 {
+		return "", fmt.Errorf("No certificate is served by %s", jwksURL.Host)
+	}
+	certs := jwksResp.TLS.PeerCertificates
+	sum := sha1.Sum(certs[len(certs)-1].Raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// EnsureOIDCProvider creates the iam oidc provider of issuer if it does not exist, or updates the client ids and thumbprint
+// of the iam oidc provider created by irsa-controller, returns the arn of iam oidc provider
+func (c *IamClient) EnsureOIDCProvider(ctx context.Context, issuerURL string, clientIDs []string, thumbprint string) (string, error) {
+	providerArn, err := c.findOIDCProvider(ctx, issuerURL)
+	if err != nil {
+		return "", err
+	}
+	if providerArn == "" {
+		tags := c.RoleTags()
+		tags[IrsaContollerManagedTagKey] = IrsaContollerManagedTagVal
+		output, err := c.iamClient.CreateOpenIDConnectProviderWithContext(ctx, &iam.CreateOpenIDConnectProviderInput{
+			Url:            aws.String(issuerURL),
+			ClientIDList:   aws.StringSlice(clientIDs),
+			ThumbprintList: aws.StringSlice([]string{thumbprint}),
+			Tags:           getIamRoleTags(tags),
+		})
+		if err != nil {
+			return "", errors.Wrap(err, "Create iam oidc provider failed")
+		}
+		return aws.StringValue(output.OpenIDConnectProviderArn), nil
+	}
+
+	got, err := c.iamClient.GetOpenIDConnectProviderWithContext(ctx, &iam.GetOpenIDConnectProviderInput{
+		OpenIDConnectProviderArn: aws.String(providerArn),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "Get iam oidc provider failed")
+	}
+	managed := false
+	for _, tag := range got.Tags {
+		if aws.StringValue(tag.Key) == IrsaContollerManagedTagKey && aws.StringValue(tag.Value) == IrsaContollerManagedTagVal {
+			managed = true
+		}
+	}
+	if !managed {
+		return "", fmt.Errorf("Iam oidc provider %s is not managed by irsa-controller", providerArn)
+	}
+	if !slices.Equal(aws.StringValueSlice(got.ThumbprintList), []string{thumbprint}) {
+		if _, err := c.iamClient.UpdateOpenIDConnectProviderThumbprintWithContext(ctx, &iam.UpdateOpenIDConnectProviderThumbprintInput{
+			OpenIDConnectProviderArn: aws.String(providerArn),
+			ThumbprintList:           aws.StringSlice([]string{thumbprint}),
+		}); err != nil {
+			return "", errors.Wrap(err, "Update thumbprint of iam oidc provider failed")
+		}
+	}
+	gotClientIDs := aws.StringValueSlice(got.ClientIDList)
+	for _, clientID := range slices.Difference(clientIDs, gotClientIDs) {
+		if _, err := c.iamClient.AddClientIDToOpenIDConnectProviderWithContext(ctx, &iam.AddClientIDToOpenIDConnectProviderInput{
+			OpenIDConnectProviderArn: aws.String(providerArn),
+			ClientID:                 aws.String(clientID),
+		}); err != nil {
+			return "", errors.Wrap(err, "Add client id to iam oidc provider failed")
+		}
+	}
+	for _, clientID := range slices.Difference(gotClientIDs, clientIDs) {
+		if _, err := c.iamClient.RemoveClientIDFromOpenIDConnectProviderWithContext(ctx, &iam.RemoveClientIDFromOpenIDConnectProviderInput{
+			OpenIDConnectProviderArn: aws.String(providerArn),
+			ClientID:                 aws.String(clientID),
+		}); err != nil {
+			return "", errors.Wrap(err, "Remove client id from iam oidc provider failed")
+		}
+	}
+	return providerArn, nil
+}
+
+// findOIDCProvider returns the arn of iam oidc provider of issuer, returns "" if it does not exist
+func (c *IamClient) findOIDCProvider(ctx context.Context, issuerURL string) (string, error) {
+	output, err := c.iamClient.ListOpenIDConnectProvidersWithContext(ctx, &iam.ListOpenIDConnectProvidersInput{})
+	if err != nil {
+		return "", errors.Wrap(err, "List iam oidc providers failed")
+	}
+	suffix := ":oidc-provider/" + IssuerHostpath(issuerURL)
+	for _, provider := range output.OpenIDConnectProviderList {
+		if strings.HasSuffix(aws.StringValue(provider.Arn), suffix) {
+			return aws.StringValue(provider.Arn), nil
+		}
+	}
+	return "", nil
+}
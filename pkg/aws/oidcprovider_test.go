@@ -0,0 +1,151 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+func TestOIDCThumbprint(t *testing.T) {
+	var serverURL string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":"%s/openid/v1/jwks"}`, serverURL, serverURL)
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	sum := sha1.Sum(server.Certificate().Raw)
+	want := hex.EncodeToString(sum[:])
+
+	got, err := oidcThumbprint(context.Background(), server.URL, &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatalf("oidcThumbprint() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("oidcThumbprint() = %v, want %v", got, want)
+	}
+
+	if _, err := oidcThumbprint(context.Background(), server.URL+"/notfound", &tls.Config{RootCAs: pool}); err == nil {
+		t.Errorf("oidcThumbprint() of unknown issuer should be failed")
+	}
+}
+
+func TestIamClient_EnsureOIDCProvider(t *testing.T) {
+	issuer := "https://oidc.example.com/cluster"
+	providerArn := "arn:aws:iam::000000000000:oidc-provider/oidc.example.com/cluster"
+	tests := []struct {
+		name           string
+		prepare        func(mic *MockedIamClient)
+		clientIDs      []string
+		thumbprint     string
+		wantClientIDs  []string
+		wantThumbprint string
+		wantErr        bool
+	}{
+		{
+			name:           "create",
+			clientIDs:      []string{DefaultAudience},
+			thumbprint:     "aaaa",
+			wantClientIDs:  []string{DefaultAudience},
+			wantThumbprint: "aaaa",
+		},
+		{
+			name: "update thumbprint and client ids",
+			prepare: func(mic *MockedIamClient) {
+				mic.CreateOpenIDConnectProviderWithContext(context.Background(), &iam.CreateOpenIDConnectProviderInput{
+					Url:            aws.String(issuer),
+					ClientIDList:   aws.StringSlice([]string{"legacy"}),
+					ThumbprintList: aws.StringSlice([]string{"aaaa"}),
+					Tags:           getIamRoleTags(map[string]string{IrsaContollerManagedTagKey: IrsaContollerManagedTagVal}),
+				})
+			},
+			clientIDs:      []string{DefaultAudience, "other"},
+			thumbprint:     "bbbb",
+			wantClientIDs:  []string{"other", DefaultAudience},
+			wantThumbprint: "bbbb",
+		},
+		{
+			name: "not managed",
+			prepare: func(mic *MockedIamClient) {
+				mic.CreateOpenIDConnectProviderWithContext(context.Background(), &iam.CreateOpenIDConnectProviderInput{
+					Url:            aws.String(issuer),
+					ClientIDList:   aws.StringSlice([]string{DefaultAudience}),
+					ThumbprintList: aws.StringSlice([]string{"aaaa"}),
+				})
+			},
+			clientIDs:  []string{DefaultAudience},
+			thumbprint: "bbbb",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mic := NewMockedIamClient()
+			if tt.prepare != nil {
+				tt.prepare(mic)
+			}
+			c := NewIamClientWithIamAPI("test", "test", nil, mic)
+			got, err := c.EnsureOIDCProvider(context.Background(), issuer, tt.clientIDs, tt.thumbprint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IamClient.EnsureOIDCProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != providerArn {
+				t.Errorf("IamClient.EnsureOIDCProvider() = %v, want %v", got, providerArn)
+			}
+			provider := mic.mockOIDCProviders[providerArn]
+			clientIDs := aws.StringValueSlice(provider.ClientIDList)
+			sort.Strings(clientIDs)
+			if !reflect.DeepEqual(clientIDs, tt.wantClientIDs) {
+				t.Errorf("client ids = %v, want %v", clientIDs, tt.wantClientIDs)
+			}
+			if !reflect.DeepEqual(aws.StringValueSlice(provider.ThumbprintList), []string{tt.wantThumbprint}) {
+				t.Errorf("thumbprints = %v, want %v", aws.StringValueSlice(provider.ThumbprintList), tt.wantThumbprint)
+			}
+			tagged := false
+			for _, tag := range provider.Tags {
+				if aws.StringValue(tag.Key) == IrsaContollerManagedTagKey {
+					tagged = true
+				}
+			}
+			if !tagged {
+				t.Errorf("iam oidc provider should be tagged as managed")
+			}
+		})
+	}
+}
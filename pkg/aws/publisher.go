@@ -0,0 +1,155 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"domc.me/irsa-controller/api/v1alpha1"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DiscoveryDocumentKey is the object key of openid discovery document relative to the issuer
+	DiscoveryDocumentKey = ".well-known/openid-configuration"
+	// JWKSKey is the object key of json web key set relative to the issuer
+	JWKSKey = "openid/v1/jwks"
+)
+
+// DiscoveryPublisher publishes the openid discovery document and json web key set of service account issuer to a s3 bucket,
+// so the iam oidc provider of self-managed cluster can verify the service account tokens
+type DiscoveryPublisher struct {
+	s3Client  s3iface.S3API
+	bucket    string
+	keyPrefix string
+	// published is the content of the objects uploaded by the publisher, used to skip unchanged objects
+	published map[string][]byte
+}
+
+func NewDiscoveryPublisher(config *AWSConfig, spec *v1alpha1.OIDCDiscoveryConfigSpec) *DiscoveryPublisher {
+	awsconf := newAWSConfig(config)
+	if spec.Region != "" {
+		awsconf = awsconf.WithRegion(spec.Region)
+	}
+	if spec.Endpoint != "" {
+		// s3 compatible storages generally do not support virtual hosted style
+		awsconf = awsconf.WithEndpoint(spec.Endpoint).WithS3ForcePathStyle(true)
+	}
+	return NewDiscoveryPublisherWithS3API(s3.New(newSession(), awsconf), spec.Bucket, spec.KeyPrefix)
+}
+
+func NewDiscoveryPublisherWithS3API(s3Client s3iface.S3API, bucket, keyPrefix string) *DiscoveryPublisher {
+	keyPrefix = strings.Trim(keyPrefix, "/")
+	if keyPrefix != "" {
+		keyPrefix += "/"
+	}
+	return &DiscoveryPublisher{
+		s3Client:  s3Client,
+		bucket:    bucket,
+		keyPrefix: keyPrefix,
+		published: make(map[string][]byte),
+	}
+}
+
+// Publish uploads the discovery document built from the openid configuration of api server and the json web key set
+// if they are changed since last publishing, returns true if any of them is uploaded
+func (p *DiscoveryPublisher) Publish(ctx context.Context, openidConfig, jwks []byte) (bool, error) {
+	var keySet struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &keySet); err != nil || len(keySet.Keys) == 0 {
+		return false, errors.Errorf("Invalid json web key set: %s", jwks)
+	}
+	issuer, document, err := NewDiscoveryDocument(openidConfig)
+	if err != nil {
+		return false, err
+	}
+	if err := p.checkIssuer(issuer); err != nil {
+		return false, err
+	}
+
+	uploaded := false
+	// upload keys first, so the new document never refers to missing keys
+	for _, obj := range []struct {
+		key  string
+		body []byte
+	}{{key: JWKSKey, body: jwks}, {key: DiscoveryDocumentKey, body: document}} {
+		key := p.keyPrefix + obj.key
+		if bytes.Equal(p.published[key], obj.body) {
+			continue
+		}
+		if _, err := p.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket:       aws.String(p.bucket),
+			Key:          aws.String(key),
+			Body:         bytes.NewReader(obj.body),
+			ContentType:  aws.String("application/json"),
+			CacheControl: aws.String("max-age=60"),
+		}); err != nil {
+			return uploaded, errors.Wrapf(err, "Upload %s to bucket %s failed", key, p.bucket)
+		}
+		p.published[key] = obj.body
+		uploaded = true
+	}
+	return uploaded, nil
+}
+
+// checkIssuer returns error if the objects published under keyPrefix are not served at the path of issuer,
+// which is either the key prefix, or the bucket and the key prefix in path style
+func (p *DiscoveryPublisher) checkIssuer(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid issuer %s", issuer)
+	}
+	path := strings.Trim(u.Path, "/")
+	if path != "" {
+		path += "/"
+	}
+	if path != p.keyPrefix && path != p.bucket+"/"+p.keyPrefix {
+		return errors.Errorf("Key prefix %q does not match the path of issuer %s", p.keyPrefix, issuer)
+	}
+	return nil
+}
+
+// NewDiscoveryDocument returns the issuer and the openid discovery document of the openid configuration of api server,
+// only jwks_uri is rewritten to point to the json web key set published next to it, other fields are kept as they are
+func NewDiscoveryDocument(openidConfig []byte) (string, []byte, error) {
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(openidConfig, &document); err != nil {
+		return "", nil, errors.Wrap(err, "Invalid openid configuration")
+	}
+	var issuer string
+	if err := json.Unmarshal(document["issuer"], &issuer); err != nil || issuer == "" {
+		return "", nil, errors.Errorf("Issuer is not found in openid configuration: %s", openidConfig)
+	}
+	if !strings.HasPrefix(issuer, "https://") {
+		return "", nil, errors.Errorf("Issuer %s must be a https url", issuer)
+	}
+	jwksURI, err := json.Marshal(strings.TrimSuffix(issuer, "/") + "/" + JWKSKey)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Marshal jwks_uri failed")
+	}
+	document["jwks_uri"] = jwksURI
+	res, err := json.Marshal(document)
+	return issuer, res, errors.Wrap(err, "Marshal discovery document failed")
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestDiscoveryPublisher_Publish(t *testing.T) {
+	issuer := "https://irsa-oidc.s3.amazonaws.com/cluster"
+	openidConfig := []byte(`{"issuer":"https://irsa-oidc.s3.amazonaws.com/cluster","jwks_uri":"https://10.0.0.1:6443/openid/v1/jwks",` +
+		`"response_types_supported":["id_token"],"subject_types_supported":["public"],"id_token_signing_alg_values_supported":["RS256","ES256"]}`)
+	jwks := []byte(`{"keys":[{"use":"sig","kty":"RSA","kid":"a","alg":"RS256","n":"n","e":"AQAB"}]}`)
+	rotated := []byte(`{"keys":[{"use":"sig","kty":"RSA","kid":"b","alg":"RS256","n":"n","e":"AQAB"}]}`)
+	ms3 := NewMockedS3Client()
+	p := NewDiscoveryPublisherWithS3API(ms3, "irsa-oidc", "/cluster/")
+
+	tests := []struct {
+		name         string
+		openidConfig []byte
+		jwks         []byte
+		wantUploaded bool
+		wantPuts     int
+		wantErr      bool
+	}{
+		{name: "first publishing", openidConfig: openidConfig, jwks: jwks, wantUploaded: true, wantPuts: 2},
+		{name: "unchanged", openidConfig: openidConfig, jwks: jwks, wantPuts: 2},
+		{name: "keys rotated", openidConfig: openidConfig, jwks: rotated, wantUploaded: true, wantPuts: 3},
+		{name: "empty key set", openidConfig: openidConfig, jwks: []byte(`{"keys":[]}`), wantPuts: 3, wantErr: true},
+		{name: "http issuer", openidConfig: []byte(`{"issuer":"http://irsa-oidc.s3.amazonaws.com/cluster"}`), jwks: jwks, wantPuts: 3, wantErr: true},
+		{name: "issuer not found", openidConfig: []byte(`{"jwks_uri":"https://10.0.0.1:6443/openid/v1/jwks"}`), jwks: jwks, wantPuts: 3, wantErr: true},
+		{name: "issuer out of key prefix", openidConfig: []byte(`{"issuer":"https://irsa-oidc.s3.amazonaws.com/other"}`), jwks: jwks, wantPuts: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploaded, err := p.Publish(context.Background(), tt.openidConfig, tt.jwks)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DiscoveryPublisher.Publish() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if uploaded != tt.wantUploaded {
+				t.Errorf("DiscoveryPublisher.Publish() = %v, want %v", uploaded, tt.wantUploaded)
+			}
+			if ms3.puts != tt.wantPuts {
+				t.Errorf("uploaded objects = %d, want %d", ms3.puts, tt.wantPuts)
+			}
+		})
+	}
+
+	if string(ms3.objects["irsa-oidc/cluster/openid/v1/jwks"]) != string(rotated) {
+		t.Errorf("published jwks = %s, want %s", ms3.objects["irsa-oidc/cluster/openid/v1/jwks"], rotated)
+	}
+	var document struct {
+		Issuer  string   `json:"issuer"`
+		JwksURI string   `json:"jwks_uri"`
+		Algs    []string `json:"id_token_signing_alg_values_supported"`
+	}
+	if err := json.Unmarshal(ms3.objects["irsa-oidc/cluster/.well-known/openid-configuration"], &document); err != nil {
+		t.Fatalf("Unmarshal published discovery document failed: %v", err)
+	}
+	if document.Issuer != issuer || document.JwksURI != issuer+"/openid/v1/jwks" || len(document.Algs) != 2 {
+		t.Errorf("published discovery document = %+v", document)
+	}
+
+	pathStyle := NewDiscoveryPublisherWithS3API(NewMockedS3Client(), "irsa-oidc", "cluster")
+	if _, err := pathStyle.Publish(context.Background(), []byte(`{"issuer":"https://s3.amazonaws.com/irsa-oidc/cluster"}`), jwks); err != nil {
+		t.Errorf("DiscoveryPublisher.Publish() of path style issuer error = %v", err)
+	}
+}
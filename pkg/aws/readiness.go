@@ -0,0 +1,179 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/pkg/errors"
+)
+
+// readinessCheckTimeout is the max duration of calling aws apis in one check
+const readinessCheckTimeout = 10 * time.Second
+
+// ReadinessChecker checks whether irsa-controller is able to manage iam roles, which requires valid aws credentials,
+// reachable iam endpoint and the existence of the oidc provider. The result is cached for ttl to avoid throttling.
+// The check is run once at a time and is not bound to the context of callers, so a probe giving up early
+// neither cancels the check nor caches its own cancellation as the result
+type ReadinessChecker struct {
+	iamAPI          iamiface.IAMAPI
+	stsAPI          stsiface.STSAPI
+	oidcProviderArn string
+	ttl             time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	lastErr   error
+	account   string
+	now       func() time.Time
+	onChange  func(err error)
+	// checking is closed when the running check is finished, it is nil if no check is running
+	checking chan struct{}
+}
+
+func NewReadinessChecker(config *AWSConfig, oidcProviderArn string, ttl time.Duration) *ReadinessChecker {
+	session := newSession()
+	awsconf := newAWSConfig(config)
+	return NewReadinessCheckerWithAPI(iam.New(session, awsconf), sts.New(session, awsconf), oidcProviderArn, ttl)
+}
+
+func NewReadinessCheckerWithAPI(iamAPI iamiface.IAMAPI, stsAPI stsiface.STSAPI, oidcProviderArn string, ttl time.Duration) *ReadinessChecker {
+	return &ReadinessChecker{
+		iamAPI:          iamAPI,
+		stsAPI:          stsAPI,
+		oidcProviderArn: oidcProviderArn,
+		ttl:             ttl,
+		now:             time.Now,
+	}
+}
+
+// Check implements healthz.Checker, returns the cached result if it is not expired
+func (c *ReadinessChecker) Check(req *http.Request) error {
+	return c.CheckContext(req.Context())
+}
+
+// CheckContext is the same as Check but waits for the result until ctx is done,
+// the error of ctx is returned without being cached if the check is not finished in time
+func (c *ReadinessChecker) CheckContext(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.checkedAt.IsZero() && c.now().Sub(c.checkedAt) < c.ttl {
+		defer c.mu.Unlock()
+		return c.lastErr
+	}
+	done := c.checking
+	if done == nil {
+		done = make(chan struct{})
+		c.checking = done
+		go c.refresh(done)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+		return c.LastError()
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "Wait for aws readiness check failed")
+	}
+}
+
+// refresh runs the check and caches its result, done is closed after the result is cached
+func (c *ReadinessChecker) refresh(done chan struct{}) {
+	defer close(done)
+	ctx, cancel := context.WithTimeout(context.Background(), readinessCheckTimeout)
+	defer cancel()
+	account, err := c.check(ctx)
+
+	c.mu.Lock()
+	changed := c.checkedAt.IsZero() || errorMessage(err) != errorMessage(c.lastErr)
+	if account != "" {
+		c.account = account
+	}
+	c.lastErr = err
+	c.checkedAt = c.now()
+	c.checking = nil
+	onChange := c.onChange
+	c.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange(err)
+	}
+}
+
+// OnChange registers fn to be called with the result of the first check and whenever the result changes,
+// so that the reason of failed checks can be logged once instead of on every probe
+func (c *ReadinessChecker) OnChange(fn func(err error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = fn
+}
+
+// ServeHTTP reports the result of the last check with its reason, which is withheld from the response of readyz
+func (c *ReadinessChecker) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if err := c.LastError(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// LastError returns the reason of the last failed check, or nil if the last check is passed or not started
+func (c *ReadinessChecker) LastError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+// Account returns the aws account of the credentials verified by the last check, or "" if it has never been verified
+func (c *ReadinessChecker) Account() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.account
+}
+
+// check returns the account of the credentials once they are verified, and the reason if irsa-controller is not ready
+func (c *ReadinessChecker) check(ctx context.Context) (string, error) {
+	identity, err := c.stsAPI.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", errors.Wrap(err, "Verify aws credentials failed")
+	}
+	account := aws.StringValue(identity.Account)
+	if _, err := c.iamAPI.GetOpenIDConnectProviderWithContext(ctx, &iam.GetOpenIDConnectProviderInput{
+		OpenIDConnectProviderArn: aws.String(c.oidcProviderArn),
+	}); err != nil {
+		if ErrIsNotFound(err) {
+			return account, fmt.Errorf("Oidc provider %s does not exist", c.oidcProviderArn)
+		}
+		return account, errors.Wrap(err, "Get oidc provider from iam failed")
+	}
+	return account, nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+)
+
+type unreachableIamClient struct {
+	iamiface.IAMAPI
+}
+
+func (unreachableIamClient) GetOpenIDConnectProviderWithContext(ctx context.Context, input *iam.GetOpenIDConnectProviderInput, opts ...request.Option) (*iam.GetOpenIDConnectProviderOutput, error) {
+	return nil, fmt.Errorf("RequestError: send request failed")
+}
+
+func TestReadinessChecker_Check(t *testing.T) {
+	mic := NewMockedIamClient()
+	output, err := mic.CreateOpenIDConnectProviderWithContext(context.Background(), &iam.CreateOpenIDConnectProviderInput{Url: aws.String("https://oidc.example.com/cluster")})
+	if err != nil {
+		t.Fatalf("Create oidc provider failed: %v", err)
+	}
+	providerArn := aws.StringValue(output.OpenIDConnectProviderArn)
+
+	tests := []struct {
+		name            string
+		iamAPI          iamiface.IAMAPI
+		stsAPI          *MockedStsClient
+		oidcProviderArn string
+		wantErr         string
+	}{
+		{name: "ready", iamAPI: mic, stsAPI: NewMockedStsClient("000000000000"), oidcProviderArn: providerArn},
+		{name: "invalid credentials", iamAPI: mic, stsAPI: &MockedStsClient{err: fmt.Errorf("InvalidClientTokenId")}, oidcProviderArn: providerArn, wantErr: "Verify aws credentials failed"},
+		{name: "iam unreachable", iamAPI: unreachableIamClient{}, stsAPI: NewMockedStsClient("000000000000"), oidcProviderArn: providerArn, wantErr: "Get oidc provider from iam failed"},
+		{name: "oidc provider not found", iamAPI: mic, stsAPI: NewMockedStsClient("000000000000"), oidcProviderArn: providerArn + "-missing", wantErr: "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewReadinessCheckerWithAPI(tt.iamAPI, tt.stsAPI, tt.oidcProviderArn, time.Minute)
+			err := c.Check(&http.Request{})
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ReadinessChecker.Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if c.LastError() != err {
+				t.Errorf("ReadinessChecker.LastError() = %v, want %v", c.LastError(), err)
+			}
+			if err == nil && c.Account() != "000000000000" {
+				t.Errorf("ReadinessChecker.Account() = %v, want 000000000000", c.Account())
+			}
+		})
+	}
+}
+
+// blockingStsClient blocks GetCallerIdentity until release is closed, and counts the calls
+type blockingStsClient struct {
+	*MockedStsClient
+	release chan struct{}
+	calls   int32
+}
+
+func (m *blockingStsClient) GetCallerIdentityWithContext(ctx context.Context, input *sts.GetCallerIdentityInput, opts ...request.Option) (*sts.GetCallerIdentityOutput, error) {
+	atomic.AddInt32(&m.calls, 1)
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return m.MockedStsClient.GetCallerIdentityWithContext(ctx, input, opts...)
+}
+
+func TestReadinessChecker_CheckContext(t *testing.T) {
+	mic := NewMockedIamClient()
+	output, err := mic.CreateOpenIDConnectProviderWithContext(context.Background(), &iam.CreateOpenIDConnectProviderInput{Url: aws.String("https://oidc.example.com/cluster")})
+	if err != nil {
+		t.Fatalf("Create oidc provider failed: %v", err)
+	}
+	sts := &blockingStsClient{MockedStsClient: NewMockedStsClient("000000000000"), release: make(chan struct{})}
+	c := NewReadinessCheckerWithAPI(mic, sts, aws.StringValue(output.OpenIDConnectProviderArn), time.Minute)
+
+	// 1. the caller giving up should not cancel the check nor cache its cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.CheckContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("1 check should return the error of caller context, got %v", err)
+	}
+	if err := c.LastError(); err != nil {
+		t.Fatalf("1 cancellation of caller should not be cached, got %v", err)
+	}
+
+	// 2. the running check should be shared by the concurrent callers
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { results <- c.Check(&http.Request{}) }()
+	}
+	close(sts.release)
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("2 check should be passed, got %v", err)
+		}
+	}
+	if calls := atomic.LoadInt32(&sts.calls); calls != 1 {
+		t.Fatalf("2 aws should be checked once, got %d", calls)
+	}
+	if c.Account() != "000000000000" {
+		t.Fatalf("2 account should be cached, got %q", c.Account())
+	}
+}
+
+func TestReadinessChecker_CheckCached(t *testing.T) {
+	now := time.Now()
+	sts := &MockedStsClient{account: "000000000000", err: fmt.Errorf("InvalidClientTokenId")}
+	c := NewReadinessCheckerWithAPI(NewMockedIamClient(), sts, "", time.Minute)
+	c.now = func() time.Time { return now }
+	var changes []error
+	c.OnChange(func(err error) { changes = append(changes, err) })
+
+	if err := c.Check(&http.Request{}); err == nil {
+		t.Fatalf("1 check with invalid credentials should be failed")
+	}
+	if len(changes) != 1 {
+		t.Fatalf("1 the result of first check should be notified, got %v", changes)
+	}
+	sts.err = nil
+	if err := c.Check(&http.Request{}); err == nil {
+		t.Fatalf("2 cached result should be returned before ttl")
+	}
+	if len(changes) != 1 {
+		t.Fatalf("2 cached result should not be notified, got %v", changes)
+	}
+	now = now.Add(time.Minute)
+	if err := c.Check(&http.Request{}); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("3 check should be run again after ttl, got %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("3 changed result should be notified, got %v", changes)
+	}
+	now = now.Add(time.Minute)
+	if err := c.Check(&http.Request{}); err == nil {
+		t.Fatalf("4 check should be failed again")
+	}
+	if len(changes) != 2 {
+		t.Fatalf("4 unchanged result should not be notified, got %v", changes)
+	}
+}
+
+func TestReadinessChecker_ServeHTTP(t *testing.T) {
+	sts := &MockedStsClient{account: "000000000000", err: fmt.Errorf("InvalidClientTokenId")}
+	c := NewReadinessCheckerWithAPI(NewMockedIamClient(), sts, "", 0)
+
+	// 1. the reason of failed check should be served
+	_ = c.Check(&http.Request{})
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz/aws", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "InvalidClientTokenId") {
+		t.Fatalf("1 reason should be served with 503, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// 2. ok should be served after the check is passed
+	mic := NewMockedIamClient()
+	output, err := mic.CreateOpenIDConnectProviderWithContext(context.Background(), &iam.CreateOpenIDConnectProviderInput{Url: aws.String("https://oidc.example.com/cluster")})
+	if err != nil {
+		t.Fatalf("2 create oidc provider failed: %v", err)
+	}
+	c.iamAPI, c.oidcProviderArn, sts.err = mic, aws.StringValue(output.OpenIDConnectProviderArn), nil
+	if err := c.Check(&http.Request{}); err != nil {
+		t.Fatalf("2 check should be passed, got %v", err)
+	}
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz/aws", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("2 ok should be served, got %d %q", rec.Code, rec.Body.String())
+	}
+}
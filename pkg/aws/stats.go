@@ -0,0 +1,86 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// DefaultAPIStats records the aws api calls made by all of the clients created by this package
+var DefaultAPIStats = &APIStats{}
+
+// APIStats records the result of aws api calls, used to summarize the health of controller
+type APIStats struct {
+	mu                sync.Mutex
+	lastSuccessTime   time.Time
+	lastThrottledTime time.Time
+	throttledRequests int64
+	retriedRequests   int64
+}
+
+// APIStatsSnapshot is a copy of APIStats at some point
+type APIStatsSnapshot struct {
+	LastSuccessTime   time.Time
+	LastThrottledTime time.Time
+	ThrottledRequests int64
+	RetriedRequests   int64
+}
+
+// Snapshot returns a copy of the stats
+func (s *APIStats) Snapshot() APIStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return APIStatsSnapshot{
+		LastSuccessTime:   s.lastSuccessTime,
+		LastThrottledTime: s.lastThrottledTime,
+		ThrottledRequests: s.throttledRequests,
+		RetriedRequests:   s.retriedRequests,
+	}
+}
+
+// Handler returns the request handler recording the completed requests, should be added to the Complete handlers
+func (s *APIStats) Handler() request.NamedHandler {
+	return request.NamedHandler{Name: "irsa-controller.APIStats", Fn: s.record}
+}
+
+func (s *APIStats) record(r *request.Request) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.RetryCount > 0 {
+		s.retriedRequests++
+	}
+	if r.Error == nil {
+		s.lastSuccessTime = now
+		return
+	}
+	if request.IsErrorThrottle(r.Error) {
+		s.throttledRequests++
+		s.lastThrottledTime = now
+	}
+}
+
+// newSession returns the aws session recording api calls to DefaultAPIStats
+func newSession() *session.Session {
+	sess := session.New()
+	sess.Handlers.Complete.PushBackNamed(DefaultAPIStats.Handler())
+	return sess
+}
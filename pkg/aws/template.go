@@ -0,0 +1,130 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"regexp"
+	"strconv"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+// paramPattern matches the parameters of ClusterPolicyTemplate, e.g. ${params.queue}
+var paramPattern = regexp.MustCompile(`\$\{params\.([A-Za-z][A-Za-z0-9_]*)\}`)
+
+// RenderPolicyTemplate validates the arguments by the typed parameters of template, and returns the rendered statements
+func RenderPolicyTemplate(tpl *irsav1alpha1.ClusterPolicyTemplate, args []irsav1alpha1.TemplateArgument) ([]irsav1alpha1.StatementSpec, error) {
+	params, err := templateParams(tpl, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var renderErr error
+	render := func(s string) string {
+		return paramPattern.ReplaceAllStringFunc(s, func(match string) string {
+			name := paramPattern.FindStringSubmatch(match)[1]
+			val, ok := params[name]
+			switch {
+			case !ok && renderErr == nil:
+				renderErr = errors.Errorf("Parameter %s is not defined", name)
+			case ok && val.Values != nil && renderErr == nil:
+				renderErr = errors.Errorf("Parameter %s of StringList can only be used as a whole item of resource or action", name)
+			}
+			return val.Value
+		})
+	}
+	renderList := func(list []string) []string {
+		res := make([]string, 0, len(list))
+		for _, item := range list {
+			if matches := paramPattern.FindStringSubmatch(item); len(matches) == 2 && matches[0] == item {
+				if val, ok := params[matches[1]]; ok && val.Values != nil {
+					res = append(res, val.Values...)
+					continue
+				}
+			}
+			res = append(res, render(item))
+		}
+		return res
+	}
+
+	statements := make([]irsav1alpha1.StatementSpec, 0, len(tpl.Spec.Statement))
+	for idx, s := range tpl.Spec.Statement {
+		if len(s.ResourceFrom) > 0 {
+			return nil, errors.Errorf("Statement %d of ClusterPolicyTemplate %s: resourceFrom is not supported in template", idx, tpl.GetName())
+		}
+		statement := *s.DeepCopy()
+		statement.Resource = renderList(statement.Resource)
+		statement.Action = renderList(statement.Action)
+		for _, condition := range statement.Condition {
+			for key, val := range condition {
+				condition[key] = render(val)
+			}
+		}
+		statements = append(statements, statement)
+	}
+	if renderErr != nil {
+		return nil, errors.Wrapf(renderErr, "Render ClusterPolicyTemplate %s failed", tpl.GetName())
+	}
+	return statements, nil
+}
+
+// templateParams returns the values of all parameters, StringList parameters have non nil Values
+func templateParams(tpl *irsav1alpha1.ClusterPolicyTemplate, args []irsav1alpha1.TemplateArgument) (map[string]irsav1alpha1.TemplateValue, error) {
+	argValues := make(map[string]irsav1alpha1.TemplateValue, len(args))
+	for _, arg := range args {
+		argValues[arg.Name] = arg.TemplateValue
+	}
+
+	params := make(map[string]irsav1alpha1.TemplateValue, len(tpl.Spec.Parameters))
+	for _, param := range tpl.Spec.Parameters {
+		val, ok := argValues[param.Name]
+		delete(argValues, param.Name)
+		// the optional parameter without default and argument renders as an empty value
+		unset := !ok && param.Default == nil
+		if !ok {
+			if param.Required {
+				return nil, errors.Errorf("Argument %s of ClusterPolicyTemplate %s is required", param.Name, tpl.GetName())
+			}
+			if param.Default != nil {
+				val = *param.Default
+			}
+		}
+		switch param.Type {
+		case irsav1alpha1.TemplateParameterStringList:
+			if val.Values == nil {
+				val.Values = []string{}
+			}
+		case irsav1alpha1.TemplateParameterInteger:
+			if _, err := strconv.Atoi(val.Value); err != nil && !unset {
+				return nil, errors.Errorf("Argument %s of ClusterPolicyTemplate %s must be an integer", param.Name, tpl.GetName())
+			}
+		case irsav1alpha1.TemplateParameterBoolean:
+			if _, err := strconv.ParseBool(val.Value); err != nil && !unset {
+				return nil, errors.Errorf("Argument %s of ClusterPolicyTemplate %s must be a boolean", param.Name, tpl.GetName())
+			}
+		}
+		if param.Type != irsav1alpha1.TemplateParameterStringList {
+			val.Values = nil
+		}
+		params[param.Name] = val
+	}
+	for name := range argValues {
+		return nil, errors.Errorf("Argument %s is not a parameter of ClusterPolicyTemplate %s", name, tpl.GetName())
+	}
+	return params, nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderPolicyTemplate(t *testing.T) {
+	tpl := &irsav1alpha1.ClusterPolicyTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-prefix"},
+		Spec: irsav1alpha1.ClusterPolicyTemplateSpec{
+			Parameters: []irsav1alpha1.TemplateParameter{
+				{Name: "bucket", Required: true},
+				{Name: "prefix", Default: &irsav1alpha1.TemplateValue{Value: "data"}},
+				{Name: "actions", Type: irsav1alpha1.TemplateParameterStringList, Default: &irsav1alpha1.TemplateValue{Values: []string{"s3:GetObject"}}},
+				{Name: "secure", Type: irsav1alpha1.TemplateParameterBoolean, Default: &irsav1alpha1.TemplateValue{Value: "true"}},
+			},
+			Statement: []irsav1alpha1.StatementSpec{
+				{
+					Resource:  []string{"arn:aws:s3:::${params.bucket}/${params.prefix}/*"},
+					Action:    []string{"${params.actions}"},
+					Effect:    string(StatementAllow),
+					Condition: irsav1alpha1.StatementConditionSpec{"Bool": {"aws:SecureTransport": "${params.secure}"}},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		tpl     *irsav1alpha1.ClusterPolicyTemplate
+		args    []irsav1alpha1.TemplateArgument
+		want    []irsav1alpha1.StatementSpec
+		wantErr bool
+	}{
+		{
+			name: "render with defaults",
+			tpl:  tpl,
+			args: []irsav1alpha1.TemplateArgument{{Name: "bucket", TemplateValue: irsav1alpha1.TemplateValue{Value: "my-bucket"}}},
+			want: []irsav1alpha1.StatementSpec{
+				{
+					Resource:  []string{"arn:aws:s3:::my-bucket/data/*"},
+					Action:    []string{"s3:GetObject"},
+					Effect:    string(StatementAllow),
+					Condition: irsav1alpha1.StatementConditionSpec{"Bool": {"aws:SecureTransport": "true"}},
+				},
+			},
+		},
+		{
+			name: "string list is expanded",
+			tpl:  tpl,
+			args: []irsav1alpha1.TemplateArgument{
+				{Name: "bucket", TemplateValue: irsav1alpha1.TemplateValue{Value: "my-bucket"}},
+				{Name: "prefix", TemplateValue: irsav1alpha1.TemplateValue{Value: "${namespace}"}},
+				{Name: "actions", TemplateValue: irsav1alpha1.TemplateValue{Values: []string{"s3:GetObject", "s3:PutObject"}}},
+				{Name: "secure", TemplateValue: irsav1alpha1.TemplateValue{Value: "false"}},
+			},
+			want: []irsav1alpha1.StatementSpec{
+				{
+					Resource:  []string{"arn:aws:s3:::my-bucket/${namespace}/*"},
+					Action:    []string{"s3:GetObject", "s3:PutObject"},
+					Effect:    string(StatementAllow),
+					Condition: irsav1alpha1.StatementConditionSpec{"Bool": {"aws:SecureTransport": "false"}},
+				},
+			},
+		},
+		{
+			name:    "missing required argument",
+			tpl:     tpl,
+			wantErr: true,
+		},
+		{
+			name: "invalid boolean argument",
+			tpl:  tpl,
+			args: []irsav1alpha1.TemplateArgument{
+				{Name: "bucket", TemplateValue: irsav1alpha1.TemplateValue{Value: "my-bucket"}},
+				{Name: "secure", TemplateValue: irsav1alpha1.TemplateValue{Value: "yes"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "optional typed parameters without default",
+			tpl: &irsav1alpha1.ClusterPolicyTemplate{
+				Spec: irsav1alpha1.ClusterPolicyTemplateSpec{
+					Parameters: []irsav1alpha1.TemplateParameter{
+						{Name: "ttl", Type: irsav1alpha1.TemplateParameterInteger},
+						{Name: "secure", Type: irsav1alpha1.TemplateParameterBoolean},
+					},
+					Statement: []irsav1alpha1.StatementSpec{{Resource: []string{"*"}, Action: []string{"s3:ListAllMyBuckets"}, Effect: string(StatementAllow)}},
+				},
+			},
+			want: []irsav1alpha1.StatementSpec{{Resource: []string{"*"}, Action: []string{"s3:ListAllMyBuckets"}, Effect: string(StatementAllow)}},
+		},
+		{
+			name: "invalid integer argument",
+			tpl: &irsav1alpha1.ClusterPolicyTemplate{
+				Spec: irsav1alpha1.ClusterPolicyTemplateSpec{
+					Parameters: []irsav1alpha1.TemplateParameter{{Name: "ttl", Type: irsav1alpha1.TemplateParameterInteger}},
+					Statement:  []irsav1alpha1.StatementSpec{{Resource: []string{"*"}, Action: []string{"s3:ListAllMyBuckets"}, Effect: string(StatementAllow)}},
+				},
+			},
+			args:    []irsav1alpha1.TemplateArgument{{Name: "ttl", TemplateValue: irsav1alpha1.TemplateValue{Value: "soon"}}},
+			wantErr: true,
+		},
+		{
+			name: "unknown argument",
+			tpl:  tpl,
+			args: []irsav1alpha1.TemplateArgument{
+				{Name: "bucket", TemplateValue: irsav1alpha1.TemplateValue{Value: "my-bucket"}},
+				{Name: "table", TemplateValue: irsav1alpha1.TemplateValue{Value: "my-table"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "undefined parameter in statement",
+			tpl: &irsav1alpha1.ClusterPolicyTemplate{
+				Spec: irsav1alpha1.ClusterPolicyTemplateSpec{
+					Statement: []irsav1alpha1.StatementSpec{{Resource: []string{"${params.table}"}, Action: []string{"dynamodb:*"}, Effect: string(StatementAllow)}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "resourceFrom is not supported",
+			tpl: &irsav1alpha1.ClusterPolicyTemplate{
+				Spec: irsav1alpha1.ClusterPolicyTemplateSpec{
+					Statement: []irsav1alpha1.StatementSpec{{ResourceFrom: []irsav1alpha1.ResourceSource{{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{Key: "arn"}}}, Action: []string{"sqs:*"}, Effect: string(StatementAllow)}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "string list is used in a string",
+			tpl: &irsav1alpha1.ClusterPolicyTemplate{
+				Spec: irsav1alpha1.ClusterPolicyTemplateSpec{
+					Parameters: []irsav1alpha1.TemplateParameter{{Name: "tables", Type: irsav1alpha1.TemplateParameterStringList}},
+					Statement:  []irsav1alpha1.StatementSpec{{Resource: []string{"arn:aws:dynamodb:*:*:table/${params.tables}"}, Action: []string{"dynamodb:*"}, Effect: string(StatementAllow)}},
+				},
+			},
+			args:    []irsav1alpha1.TemplateArgument{{Name: "tables", TemplateValue: irsav1alpha1.TemplateValue{Values: []string{"a"}}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderPolicyTemplate(tt.tpl, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderPolicyTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("RenderPolicyTemplate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if tpl.Spec.Statement[0].Action[0] != "${params.actions}" {
+		t.Fatalf("RenderPolicyTemplate() should not modify template")
+	}
+}
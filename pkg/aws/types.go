@@ -17,12 +17,16 @@ limitations under the License.
 package aws
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
 
 	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"domc.me/irsa-controller/pkg/utils/slices"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type AWSConfig struct {
@@ -62,18 +66,58 @@ const (
 	StatementDeny  StatementEffect = "Deny"
 
 	AssumeRoleWithWebIdentityAction = "sts:AssumeRoleWithWebIdentity"
+	AssumeRoleAction                = "sts:AssumeRole"
+	// DefaultAudience is the audience of the web identity token projected by eks pod identity webhook
+	DefaultAudience = "sts.amazonaws.com"
+	// ServiceAccountSubjectPrefix is the prefix of the subject of service account token
+	ServiceAccountSubjectPrefix = "system:serviceaccount:"
 	// IrsaContollerManagedTagKey is a fixed tag key, if tag value is "y"
 	// means this iam role is manged by irsa-controller
 	IrsaContollerManagedTagKey = "irsa-controller"
 	IrsaContollerManagedTagVal = "y"
+	// ClusterTagKeyPrefix is the prefix of the tag keys recording the clusters which depend on the iam role
+	ClusterTagKeyPrefix = "irsa-controller/cluster/"
+	// OwnerRoleTagKey is the tag key recording the iam role which owns the customer managed policy
+	OwnerRoleTagKey = "irsa-controller/owner-role"
 )
 
+const (
+	// InlinePoliciesSizeLimit is the max aggregate size of the inline policies in an iam role, whitespace is not counted
+	InlinePoliciesSizeLimit = 10240
+	// ManagedPolicySizeLimit is the max size of a customer managed policy, whitespace is not counted
+	ManagedPolicySizeLimit = 6144
+	// TrustPolicySizeLimit is the default max size of the trust policy of iam role, whitespace is not counted
+	TrustPolicySizeLimit = 2048
+	// MaxTrustPolicySizeLimit is the max size of the trust policy of iam role after the quota is raised
+	MaxTrustPolicySizeLimit = 4096
+	// MaxOwnedPolicies is the max count of customer managed policies owned by an iam role,
+	// which is the max quota of managed policies attached to an iam role
+	MaxOwnedPolicies = 20
+)
+
+// PolicySizeExceededError means the size of policies is over the limit of aws
+type PolicySizeExceededError struct {
+	// Kind is the kind of the policies, e.g. inline policies
+	Kind  string
+	Size  int
+	Limit int
+}
+
+func (e *PolicySizeExceededError) Error() string {
+	return fmt.Sprintf("Size of %s is %d, exceeds the limit %d", e.Kind, e.Size, e.Limit)
+}
+
 type IamRole struct {
 	// RoleArn is "" when role is not created
 	RoleArn string
 	// RoleName is "" if RoleArn is ""
 	RoleName     string
 	InlinePolicy *RoleDocument
+	// InlinePolicies defines the named inline policies except InlinePolicy, the key is the name of policy
+	InlinePolicies map[string]*RoleDocument
+	// OwnedPolicies defines the customer managed policies created by irsa-controller for the role,
+	// the statements of InlinePolicy are split into them if it exceeds the size limit
+	OwnedPolicies []*RoleDocument
 	// ManagedPolicies defines the arns of ManagedPolicies
 	ManagedPolicies []string
 	// AssumeRolePolicy defines the trust relationship of iam role
@@ -89,18 +133,26 @@ func (i *IamRole) IsManagedByIrsaController() bool {
 	return false
 }
 
-// NewIamRole is used only if the iam role is created by irsa but not be specificed by irsa.roleName
-func NewIamRole(oidcProviderArn string, irsa *irsav1alpha1.IamRoleServiceAccount, additionalTags map[string]string) *IamRole {
+// NewIamRole is used only if the iam role is created by irsa but not be specificed by irsa.roleName,
+// the statements and tags of irsa are rendered with vars if vars is not nil, returns error if any variable cannot be rendered
+func NewIamRole(oidcProviderArn string, irsa *irsav1alpha1.IamRoleServiceAccount, additionalTags map[string]string, vars PolicyVariables) (*IamRole, error) {
+	if vars != nil {
+		rendered, err := vars.RenderIrsa(irsa)
+		if err != nil {
+			return nil, errors.Wrap(err, "Render policy variables failed")
+		}
+		irsa = rendered
+	}
 	iamRole := new(IamRole)
-	// set additional tags
-	iamRole.Tags = additionalTags
-	if iamRole.Tags == nil {
-		iamRole.Tags = make(map[string]string)
+	// set additional tags, they are copied because tags of irsa are merged into them
+	iamRole.Tags = make(map[string]string, len(additionalTags))
+	for k, v := range additionalTags {
+		iamRole.Tags[k] = v
 	}
 	iamRole.fromIRSA(oidcProviderArn, irsa)
 	// fixed key value: managed by irsa-controller
 	iamRole.Tags[IrsaContollerManagedTagKey] = IrsaContollerManagedTagVal
-	return iamRole
+	return iamRole, nil
 }
 
 func (i *IamRole) fromIRSA(oidcProviderArn string, irsa *irsav1alpha1.IamRoleServiceAccount) {
@@ -112,19 +164,26 @@ func (i *IamRole) fromIRSA(oidcProviderArn string, irsa *irsav1alpha1.IamRoleSer
 	if policy != nil {
 		i.ManagedPolicies = policy.ManagedPolicies
 		if policy.InlinePolicy != nil {
-			ip := policy.InlinePolicy
-			stses := policy.InlinePolicy.Statement
-			i.InlinePolicy = &RoleDocument{
-				Version:   ip.Version,
-				Statement: make([]RoleStatement, len(stses)),
-			}
-			for idx, sts := range stses {
-				i.InlinePolicy.Statement[idx] = roleStatementFromIRSAStatementSpec(&sts)
+			i.InlinePolicy = NewRoleDocument(policy.InlinePolicy)
+		}
+		// the document should be validated by ParseRoleDocument before
+		if policy.InlinePolicyDocument != "" {
+			i.InlinePolicy, _ = ParseRoleDocument(policy.InlinePolicyDocument)
+		}
+		for _, named := range policy.InlinePolicies {
+			if i.InlinePolicies == nil {
+				i.InlinePolicies = make(map[string]*RoleDocument, len(policy.InlinePolicies))
 			}
+			i.InlinePolicies[named.Name] = NewRoleDocument(&named.InlinePolicySpec)
+		}
+		if policy.Mode == irsav1alpha1.PolicyModeManaged {
+			i.moveInlinePolicyToOwnedPolicies()
+		} else if policy.OversizeStrategy == irsav1alpha1.OversizeSplitManagedPolicies {
+			i.splitInlinePolicyIfOversize()
 		}
 	}
 
-	arp := NewAssumeRolePolicy(oidcProviderArn, irsa.GetNamespace(), irsa.GetName())
+	arp := NewServiceAccountsAssumeRolePolicy(oidcProviderArn, irsa.ServiceAccounts(), irsa.Spec.Trust)
 	i.AssumeRolePolicy = &arp
 
 	for k, v := range irsa.Spec.Tags {
@@ -132,11 +191,124 @@ func (i *IamRole) fromIRSA(oidcProviderArn string, irsa *irsav1alpha1.IamRoleSer
 	}
 }
 
+// InlinePoliciesSize returns the aggregate size of the inline policies
+func (i *IamRole) InlinePoliciesSize() (int, error) {
+	size, err := i.InlinePolicy.Size()
+	if err != nil {
+		return 0, err
+	}
+	for _, policy := range i.InlinePolicies {
+		policySize, err := policy.Size()
+		if err != nil {
+			return 0, err
+		}
+		size += policySize
+	}
+	return size, nil
+}
+
+// CheckPoliciesSize returns PolicySizeExceededError if any policy of role exceeds the size limit
+func (i *IamRole) CheckPoliciesSize(trustPolicySizeLimit int) error {
+	size, err := i.InlinePoliciesSize()
+	if err != nil {
+		return errors.Wrap(err, "Compute size of inline policies failed")
+	}
+	if size > InlinePoliciesSizeLimit {
+		return &PolicySizeExceededError{Kind: "inline policies", Size: size, Limit: InlinePoliciesSizeLimit}
+	}
+	if err := i.AssumeRolePolicy.CheckSize(trustPolicySizeLimit); err != nil {
+		return err
+	}
+	if len(i.OwnedPolicies) > MaxOwnedPolicies {
+		return fmt.Errorf("Count of owned managed policies is %d, exceeds the limit %d", len(i.OwnedPolicies), MaxOwnedPolicies)
+	}
+	for idx, policy := range i.OwnedPolicies {
+		size, err := policy.Size()
+		if err != nil {
+			return errors.Wrap(err, "Compute size of managed policy failed")
+		}
+		if size > ManagedPolicySizeLimit {
+			return &PolicySizeExceededError{Kind: fmt.Sprintf("managed policy %d", idx), Size: size, Limit: ManagedPolicySizeLimit}
+		}
+	}
+	return nil
+}
+
+// moveInlinePolicyToOwnedPolicies moves the statements of InlinePolicy into OwnedPolicies,
+// the whole document is kept in one policy if it cannot be split, so that the size can be reported
+func (i *IamRole) moveInlinePolicyToOwnedPolicies() {
+	if i.InlinePolicy == nil {
+		return
+	}
+	policies, err := SplitRoleDocument(i.InlinePolicy, ManagedPolicySizeLimit)
+	if err != nil {
+		policies = []*RoleDocument{i.InlinePolicy}
+	}
+	i.InlinePolicy = nil
+	i.OwnedPolicies = policies
+}
+
+// splitInlinePolicyIfOversize moves the statements of InlinePolicy into OwnedPolicies if inline policies exceed the size limit,
+// InlinePolicy is kept if any of its statements cannot fit into a managed policy, so that the size can be reported
+func (i *IamRole) splitInlinePolicyIfOversize() {
+	size, err := i.InlinePoliciesSize()
+	if err != nil || size <= InlinePoliciesSizeLimit || i.InlinePolicy == nil {
+		return
+	}
+	policies, err := SplitRoleDocument(i.InlinePolicy, ManagedPolicySizeLimit)
+	if err != nil {
+		return
+	}
+	i.InlinePolicy = nil
+	i.OwnedPolicies = policies
+}
+
+// Policy is a customer managed policy in aws account
+type Policy struct {
+	PolicyArn string
+	// Document is the document of the default version of policy
+	Document *RoleDocument
+	Tags     map[string]string
+}
+
+func (p *Policy) IsManagedByIrsaController() bool {
+	return p.Tags[IrsaContollerManagedTagKey] == IrsaContollerManagedTagVal
+}
+
+// NewRoleDocument returns the policy document defined by spec
+func NewRoleDocument(spec *irsav1alpha1.InlinePolicySpec) *RoleDocument {
+	doc := &RoleDocument{
+		Version:   spec.Version,
+		Statement: make([]RoleStatement, len(spec.Statement)),
+	}
+	for idx, sts := range spec.Statement {
+		doc.Statement[idx] = roleStatementFromIRSAStatementSpec(&sts)
+	}
+	return doc
+}
+
 type RoleDocument struct {
 	Version   string
+	Id        string `json:"Id,omitempty"`
 	Statement []RoleStatement
 }
 
+// UnmarshalJSON supports the document whose Statement is a single statement
+func (r *RoleDocument) UnmarshalJSON(b []byte) error {
+	var raw rawRoleDocument
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	statement, err := unmarshalRoleStatements(raw.Statement, false)
+	if err != nil {
+		return err
+	}
+	r.Version = raw.Version
+	r.Id = raw.Id
+	r.Statement = statement
+	return nil
+}
+
 func (r *RoleDocument) RoleDocumentPolicyDocument() (string, error) {
 	bytes, err := json.Marshal(r)
 	if err != nil {
@@ -145,21 +317,224 @@ func (r *RoleDocument) RoleDocumentPolicyDocument() (string, error) {
 	return string(bytes), nil
 }
 
+// Size returns the size of minified policy document, it returns 0 if document is nil
+func (r *RoleDocument) Size() (int, error) {
+	if r == nil {
+		return 0, nil
+	}
+	doc, err := r.RoleDocumentPolicyDocument()
+	if err != nil {
+		return 0, err
+	}
+	return len(doc), nil
+}
+
+// SplitRoleDocument splits the statements of document into several documents whose size is not over than limit,
+// the order of statements is kept
+func SplitRoleDocument(doc *RoleDocument, limit int) ([]*RoleDocument, error) {
+	var res []*RoleDocument
+	current := &RoleDocument{Version: doc.Version, Id: doc.Id}
+	for idx, statement := range doc.Statement {
+		current.Statement = append(current.Statement, statement)
+		size, err := current.Size()
+		if err != nil {
+			return nil, err
+		}
+		if size <= limit {
+			continue
+		}
+		// current document is full, move the statement into a new document
+		if len(current.Statement) > 1 {
+			current.Statement = current.Statement[:len(current.Statement)-1]
+			res = append(res, current)
+			current = &RoleDocument{Version: doc.Version, Id: doc.Id, Statement: []RoleStatement{statement}}
+			if size, err = current.Size(); err != nil {
+				return nil, err
+			}
+		}
+		if size > limit {
+			return nil, &PolicySizeExceededError{Kind: fmt.Sprintf("statement %d", idx), Size: size, Limit: limit}
+		}
+	}
+	if len(current.Statement) > 0 {
+		res = append(res, current)
+	}
+	return res, nil
+}
+
+// Validate checks whether the document is a valid iam identity-based policy
+func (r *RoleDocument) Validate() error {
+	if r.Version != "" && r.Version != "2012-10-17" && r.Version != "2008-10-17" {
+		return fmt.Errorf("unknown policy version %q", r.Version)
+	}
+	if len(r.Statement) == 0 {
+		return fmt.Errorf("policy has no statement")
+	}
+	for idx, st := range r.Statement {
+		if st.Effect != StatementAllow && st.Effect != StatementDeny {
+			return fmt.Errorf("statement %d: unknown effect %q", idx, st.Effect)
+		}
+		if (len(st.Action) == 0) == (len(st.NotAction) == 0) {
+			return fmt.Errorf("statement %d: exactly one of Action and NotAction is required", idx)
+		}
+		if (len(st.Resource) == 0) == (len(st.NotResource) == 0) {
+			return fmt.Errorf("statement %d: exactly one of Resource and NotResource is required", idx)
+		}
+	}
+	return nil
+}
+
+// ParseRoleDocument parses and validates a raw json iam policy document,
+// fields which are not supported in identity-based policy are rejected
+func ParseRoleDocument(doc string) (*RoleDocument, error) {
+	var raw rawRoleDocument
+	decoder := json.NewDecoder(strings.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, errors.Wrap(err, "Invalid policy document")
+	}
+	statement, err := unmarshalRoleStatements(raw.Statement, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid policy statement")
+	}
+	res := &RoleDocument{
+		Version:   raw.Version,
+		Id:        raw.Id,
+		Statement: statement,
+	}
+	if err := res.Validate(); err != nil {
+		return nil, errors.Wrap(err, "Invalid policy document")
+	}
+	return res, nil
+}
+
+type rawRoleDocument struct {
+	Version   string
+	Id        string
+	Statement json.RawMessage
+}
+
+func unmarshalRoleStatements(b json.RawMessage, strict bool) ([]RoleStatement, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || bytes.Equal(b, []byte("null")) {
+		return nil, nil
+	}
+	decode := func(v interface{}) error {
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+		return decoder.Decode(v)
+	}
+	if b[0] == '{' {
+		var st RoleStatement
+		if err := decode(&st); err != nil {
+			return nil, err
+		}
+		return []RoleStatement{st}, nil
+	}
+	var sts []RoleStatement
+	if err := decode(&sts); err != nil {
+		return nil, err
+	}
+	return sts, nil
+}
+
 type RoleStatement struct {
-	Effect    StatementEffect
-	Action    []string
-	Resource  []string
-	Condition StatementCondition `json:"Condition,omitempty"`
+	Sid         string `json:"Sid,omitempty"`
+	Effect      StatementEffect
+	Action      StringList         `json:"Action,omitempty"`
+	NotAction   StringList         `json:"NotAction,omitempty"`
+	Resource    StringList         `json:"Resource,omitempty"`
+	NotResource StringList         `json:"NotResource,omitempty"`
+	Condition   StatementCondition `json:"Condition,omitempty"`
+}
+
+type StatementCondition map[string]map[string]ConditionValues
+
+// StringList is a list of strings which can be unmarshalled from a json string or a json list
+type StringList []string
+
+func (s *StringList) UnmarshalJSON(b []byte) error {
+	values, err := unmarshalStringOrList(b)
+	if err != nil {
+		return err
+	}
+	*s = values
+	return nil
 }
 
-type StatementCondition map[string]map[string]string
+// ConditionValues is the values of condition key, it is marshalled to a json string if there is only one value
+type ConditionValues []string
+
+func (c ConditionValues) MarshalJSON() ([]byte, error) {
+	if len(c) == 1 {
+		return json.Marshal(c[0])
+	}
+	return json.Marshal([]string(c))
+}
+
+func (c *ConditionValues) UnmarshalJSON(b []byte) error {
+	values, err := unmarshalStringOrList(b)
+	if err != nil {
+		return err
+	}
+	*c = values
+	return nil
+}
+
+// unmarshalStringOrList unmarshals a json scalar or a json list of scalars to strings
+func unmarshalStringOrList(b []byte) ([]string, error) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	scalar := func(v interface{}) (string, error) {
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case bool, float64:
+			return fmt.Sprint(val), nil
+		}
+		return "", fmt.Errorf("unexpected value %v", v)
+	}
+	if list, ok := v.([]interface{}); ok {
+		res := make([]string, 0, len(list))
+		for _, item := range list {
+			val, err := scalar(item)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, val)
+		}
+		return res, nil
+	}
+	if v == nil {
+		return nil, nil
+	}
+	val, err := scalar(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{val}, nil
+}
 
 func roleStatementFromIRSAStatementSpec(sts *irsav1alpha1.StatementSpec) RoleStatement {
+	var condition StatementCondition
+	if sts.Condition != nil {
+		condition = make(StatementCondition, len(sts.Condition))
+		for op, kv := range sts.Condition {
+			condition[op] = make(map[string]ConditionValues, len(kv))
+			for k, v := range kv {
+				condition[op][k] = ConditionValues{v}
+			}
+		}
+	}
 	return RoleStatement{
 		Effect:    StatementEffect(sts.Effect),
 		Action:    sts.Action,
 		Resource:  sts.Resource,
-		Condition: StatementCondition(sts.Condition),
+		Condition: condition,
 	}
 }
 
@@ -174,7 +549,7 @@ type AssumeRoleStatement struct {
 	Effect    StatementEffect
 	Principal AssumeRoleStatementPrincipal `json:"Principal,omitempty"`
 	Action    string
-	Condition map[string]map[string]string `json:"Condition,omitempty"`
+	Condition StatementCondition `json:"Condition,omitempty"`
 }
 
 // AssumeRoleDocument defines the trust relationship of aws iam role
@@ -183,6 +558,108 @@ type AssumeRoleDocument struct {
 	Statement []AssumeRoleStatement
 }
 
+// CheckSize returns PolicySizeExceededError if the trust policy exceeds the size limit
+func (t *AssumeRoleDocument) CheckSize(limit int) error {
+	if t == nil {
+		return nil
+	}
+	doc, err := t.AssumeRoleDocumentPolicyDocument()
+	if err != nil {
+		return errors.Wrap(err, "Marshal trust policy failed")
+	}
+	if len(doc) > limit {
+		return &PolicySizeExceededError{Kind: "trust policy", Size: len(doc), Limit: limit}
+	}
+	return nil
+}
+
+// isSubjectStatement returns true if the statement only trusts the subjects of oidc provider by StringEquals
+func (st *AssumeRoleStatement) isSubjectStatement(oidcProviderArn string) bool {
+	if st.Action != AssumeRoleWithWebIdentityAction || st.Principal.Federated != oidcProviderArn || len(st.Condition) != 1 {
+		return false
+	}
+	issuerHostpath := getIssuerHostpath(oidcProviderArn)
+	for key := range st.Condition["StringEquals"] {
+		if key != issuerHostpath+":sub" && key != issuerHostpath+":aud" {
+			return false
+		}
+	}
+	return len(st.Condition["StringEquals"][issuerHostpath+":sub"]) > 0
+}
+
+// AllowSubjects consolidates the subjects of oidc provider with the same audience into one statement,
+// and adds the subjects into the statement of the audience. If the audience is set, the subjects are moved out of
+// the statement without aud condition, e.g. written by the earlier versions, so they are not trusted for any audience
+func (t *AssumeRoleDocument) AllowSubjects(oidcProviderArn, audience string, subjects []string) {
+	issuerHostpath := getIssuerHostpath(oidcProviderArn)
+	subKey, audKey := issuerHostpath+":sub", issuerHostpath+":aud"
+	var statements []AssumeRoleStatement
+	// index of the consolidated statement of each audience in statements
+	consolidated := map[string]int{}
+	for _, st := range t.Statement {
+		if !st.isSubjectStatement(oidcProviderArn) {
+			statements = append(statements, st)
+			continue
+		}
+		aud := strings.Join(st.Condition["StringEquals"][audKey], ",")
+		idx, ok := consolidated[aud]
+		if !ok {
+			consolidated[aud] = len(statements)
+			statements = append(statements, st)
+			continue
+		}
+		target := statements[idx].Condition["StringEquals"]
+		target[subKey] = ConditionValues(slices.Union(target[subKey], st.Condition["StringEquals"][subKey]))
+	}
+	if len(subjects) > 0 {
+		idx, ok := consolidated[audience]
+		if !ok {
+			idx = len(statements)
+			statements = append(statements, newSubjectStatement(oidcProviderArn, audience, "StringEquals", nil))
+		}
+		target := statements[idx].Condition["StringEquals"]
+		target[subKey] = ConditionValues(slices.Union(target[subKey], subjects))
+
+		if noAud, ok := consolidated[""]; ok && audience != "" {
+			target := statements[noAud].Condition["StringEquals"]
+			left := slices.Difference(target[subKey], subjects)
+			if len(left) > 0 {
+				target[subKey] = ConditionValues(left)
+			} else {
+				statements = append(statements[:noAud], statements[noAud+1:]...)
+			}
+		}
+	}
+	t.Statement = statements
+}
+
+// RevokeSubjects removes the subjects of oidc provider from the statements trusting subjects by StringEquals,
+// the statement is removed if none of its subjects is left. Returns true if the document is changed
+func (t *AssumeRoleDocument) RevokeSubjects(oidcProviderArn string, subjects []string) bool {
+	subKey := getIssuerHostpath(oidcProviderArn) + ":sub"
+	changed := false
+	statements := make([]AssumeRoleStatement, 0, len(t.Statement))
+	for _, st := range t.Statement {
+		if !st.isSubjectStatement(oidcProviderArn) {
+			statements = append(statements, st)
+			continue
+		}
+		values := st.Condition["StringEquals"][subKey]
+		left := slices.Difference(values, subjects)
+		if len(left) == len(values) {
+			statements = append(statements, st)
+			continue
+		}
+		changed = true
+		if len(left) > 0 {
+			st.Condition["StringEquals"][subKey] = ConditionValues(left)
+			statements = append(statements, st)
+		}
+	}
+	t.Statement = statements
+	return changed
+}
+
 func (t *AssumeRoleDocument) AssumeRoleDocumentPolicyDocument() (string, error) {
 	bytes, err := json.Marshal(t)
 	if err != nil {
@@ -191,13 +668,28 @@ func (t *AssumeRoleDocument) AssumeRoleDocumentPolicyDocument() (string, error)
 	return string(bytes), nil
 }
 
-func (t *AssumeRoleDocument) IsAllowOIDC(oidcProviderArn, namespace, serviceAccountName string) bool {
+// IsAllowOIDC returns true if the service account is trusted by the subject in StringEquals or the subject pattern in StringLike,
+// and the audience is enforced by the aud condition of the same statement. An empty audience matches the statements of any audience,
+// including the legacy statements without aud condition
+func (t *AssumeRoleDocument) IsAllowOIDC(oidcProviderArn, audience, namespace, serviceAccountName string) bool {
 	if t == nil {
 		return false
 	}
+	issuerHostpath := getIssuerHostpath(oidcProviderArn)
+	subKey, audKey := issuerHostpath+":sub", issuerHostpath+":aud"
+	subject := ServiceAccountSubject(namespace, serviceAccountName)
 	for _, st := range t.Statement {
-		if st.Action == AssumeRoleWithWebIdentityAction && st.Principal.Federated == oidcProviderArn && st.Condition["StringEquals"] != nil {
-			if val := st.Condition["StringEquals"][fmt.Sprintf("%s:sub", getIssuerHostpath(oidcProviderArn))]; val == fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName) {
+		if st.Action != AssumeRoleWithWebIdentityAction || st.Principal.Federated != oidcProviderArn {
+			continue
+		}
+		if audience != "" && !slices.ContainsString(st.Condition["StringEquals"][audKey], audience) {
+			continue
+		}
+		if slices.ContainsString(st.Condition["StringEquals"][subKey], subject) {
+			return true
+		}
+		for _, pattern := range st.Condition["StringLike"][subKey] {
+			if MatchStringLike(pattern, subject) {
 				return true
 			}
 		}
@@ -205,6 +697,14 @@ func (t *AssumeRoleDocument) IsAllowOIDC(oidcProviderArn, namespace, serviceAcco
 	return false
 }
 
+// TrustAudience returns the audience enforced by the trust policy, default is "sts.amazonaws.com"
+func TrustAudience(trust *irsav1alpha1.TrustSpec) string {
+	if trust == nil || trust.Audience == "" {
+		return DefaultAudience
+	}
+	return trust.Audience
+}
+
 func NewAssumeRolePolicyDoc(oidcProviderArn, namespace, serviceAccountName string) (string, error) {
 	// resource : https://aws.amazon.com/blogs/opensource/introducing-fine-grained-iam-roles-service-accounts
 
@@ -218,26 +718,194 @@ func NewAssumeRolePolicyDoc(oidcProviderArn, namespace, serviceAccountName strin
 }
 
 func NewAssumeRolePolicy(oidcProviderArn, namespace, serviceAccountName string) AssumeRoleDocument {
-	// resource : https://aws.amazon.com/blogs/opensource/introducing-fine-grained-iam-roles-service-accounts
+	return NewServiceAccountsAssumeRolePolicy(oidcProviderArn, []types.NamespacedName{{Namespace: namespace, Name: serviceAccountName}}, nil)
+}
 
+// NewServiceAccountsAssumeRolePolicy returns a trust relationship which allows all of the service accounts
+// and the subject patterns of trust to assume the role, the audience is always enforced.
+// The subjects of service accounts and the subject patterns are consolidated into one statement respectively
+// for the oidc provider and each of the oidc providers of trust
+func NewServiceAccountsAssumeRolePolicy(oidcProviderArn string, serviceAccounts []types.NamespacedName, trust *irsav1alpha1.TrustSpec) AssumeRoleDocument {
+	// resource : https://aws.amazon.com/blogs/opensource/introducing-fine-grained-iam-roles-service-accounts
+	audience := TrustAudience(trust)
+	var subjectPatterns []string
+	var principals []irsav1alpha1.TrustPrincipal
+	oidcProviderArns := []string{oidcProviderArn}
+	if trust != nil {
+		subjectPatterns = trust.SubjectPatterns
+		principals = trust.AdditionalPrincipals
+		for _, provider := range trust.OIDCProviders {
+			if !slices.ContainsString(oidcProviderArns, provider.Arn) {
+				oidcProviderArns = append(oidcProviderArns, provider.Arn)
+			}
+		}
+	}
 	// then create the json formatted Trust policy
-	return AssumeRoleDocument{
-		Version: "2012-10-17",
-		Statement: []AssumeRoleStatement{
-			{
-				Effect: StatementAllow,
-				Principal: AssumeRoleStatementPrincipal{
-					Federated: string(oidcProviderArn),
-				},
-				Action: AssumeRoleWithWebIdentityAction,
-				Condition: map[string]map[string]string{
-					"StringEquals": map[string]string{
-						fmt.Sprintf("%s:sub", getIssuerHostpath(oidcProviderArn)): fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName),
-					},
-				},
-			},
+	doc := AssumeRoleDocument{
+		Version:   "2012-10-17",
+		Statement: make([]AssumeRoleStatement, 0, 2*len(oidcProviderArns)+len(principals)),
+	}
+	subjects := make([]string, 0, len(serviceAccounts))
+	for _, sa := range serviceAccounts {
+		subjects = append(subjects, ServiceAccountSubject(sa.Namespace, sa.Name))
+	}
+	for _, providerArn := range oidcProviderArns {
+		if len(subjects) > 0 {
+			doc.Statement = append(doc.Statement, newSubjectStatement(providerArn, audience, "StringEquals", subjects))
+		}
+		if len(subjectPatterns) > 0 {
+			doc.Statement = append(doc.Statement, newSubjectStatement(providerArn, audience, "StringLike", subjectPatterns))
+		}
+	}
+	for _, principal := range principals {
+		doc.Statement = append(doc.Statement, newPrincipalStatement(oidcProviderArn, principal))
+	}
+	return doc
+}
+
+// newSubjectStatement returns the statement which trusts the subjects of oidc provider with the audience
+func newSubjectStatement(oidcProviderArn, audience, operator string, subjects []string) AssumeRoleStatement {
+	issuerHostpath := getIssuerHostpath(oidcProviderArn)
+	condition := StatementCondition{
+		"StringEquals": {
+			fmt.Sprintf("%s:aud", issuerHostpath): ConditionValues{audience},
+		},
+	}
+	if condition[operator] == nil {
+		condition[operator] = map[string]ConditionValues{}
+	}
+	condition[operator][fmt.Sprintf("%s:sub", issuerHostpath)] = ConditionValues(subjects)
+	return AssumeRoleStatement{
+		Effect: StatementAllow,
+		Principal: AssumeRoleStatementPrincipal{
+			Federated: string(oidcProviderArn),
+		},
+		Action:    AssumeRoleWithWebIdentityAction,
+		Condition: condition,
+	}
+}
+
+// newPrincipalStatement returns the trust statement of additional principal,
+// it is in the same form as the statement got from aws, so that the drift can be detected
+func newPrincipalStatement(oidcProviderArn string, principal irsav1alpha1.TrustPrincipal) AssumeRoleStatement {
+	st := AssumeRoleStatement{
+		Effect: StatementAllow,
+		Principal: AssumeRoleStatementPrincipal{
+			AWS:       principal.AWS,
+			Service:   principal.Service,
+			Federated: principal.Federated,
 		},
+		Action: principal.Action,
+	}
+	// aws converts the account id in principal to the arn of account root
+	if accountIDPattern.MatchString(principal.AWS) {
+		st.Principal.AWS = fmt.Sprintf("arn:%s:iam::%s:root", PartitionByArn(oidcProviderArn), principal.AWS)
+	}
+	if st.Action == "" {
+		st.Action = AssumeRoleAction
+		if principal.Federated != "" {
+			st.Action = AssumeRoleWithWebIdentityAction
+		}
+	}
+	if len(principal.Condition) > 0 {
+		st.Condition = make(StatementCondition, len(principal.Condition))
+		for op, kv := range principal.Condition {
+			st.Condition[op] = make(map[string]ConditionValues, len(kv))
+			for k, v := range kv {
+				st.Condition[op][k] = ConditionValues{v}
+			}
+		}
+	}
+	return st
+}
+
+// ValidateTrustPrincipal returns error if not exactly one of AWS, Service and Federated is set, the principal contains wildcards,
+// or the Federated principal has no condition, which lets anyone authenticated by the provider assume the role
+func ValidateTrustPrincipal(principal irsav1alpha1.TrustPrincipal) error {
+	set := 0
+	for _, v := range []string{principal.AWS, principal.Service, principal.Federated} {
+		if v != "" {
+			set++
+		}
+		if strings.Contains(v, "*") {
+			return fmt.Errorf("wildcards are not allowed in trusted principal %s", v)
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of aws, service and federated must be set in trusted principal")
+	}
+	if principal.Federated != "" && len(principal.Condition) == 0 {
+		return fmt.Errorf("condition is required by federated principal %s", principal.Federated)
+	}
+	return nil
+}
+
+// TrustPrincipalValue returns the value of the principal, which is one of AWS, Service and Federated
+func TrustPrincipalValue(principal irsav1alpha1.TrustPrincipal) string {
+	for _, v := range []string{principal.AWS, principal.Service, principal.Federated} {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// accountIDPattern matches the id of aws account
+var accountIDPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// ValidateOIDCProvider returns error if the arn is not the arn of iam oidc provider or the cluster is not set
+func ValidateOIDCProvider(provider irsav1alpha1.OIDCProvider) error {
+	if provider.Cluster == "" {
+		return fmt.Errorf("Cluster of oidc provider %s is required", provider.Arn)
+	}
+	if !strings.HasPrefix(provider.Arn, "arn:") || !strings.Contains(provider.Arn, ":oidc-provider/") {
+		return fmt.Errorf("%s is not the arn of iam oidc provider", provider.Arn)
+	}
+	return nil
+}
+
+// ClusterTagKey returns the tag key recording that the cluster depends on the iam role
+func ClusterTagKey(cluster string) string {
+	return ClusterTagKeyPrefix + cluster
+}
+
+// ServiceAccountSubject returns the subject of service account token
+func ServiceAccountSubject(namespace, serviceAccountName string) string {
+	return fmt.Sprintf("%s%s:%s", ServiceAccountSubjectPrefix, namespace, serviceAccountName)
+}
+
+// ValidateSubjectPattern returns the namespace of the subject pattern,
+// the pattern must be system:serviceaccount:<namespace>:<name> and wildcards are only allowed in name
+func ValidateSubjectPattern(pattern string) (string, error) {
+	if !strings.HasPrefix(pattern, ServiceAccountSubjectPrefix) {
+		return "", fmt.Errorf("subject pattern %s must start with %s", pattern, ServiceAccountSubjectPrefix)
+	}
+	splits := strings.Split(strings.TrimPrefix(pattern, ServiceAccountSubjectPrefix), ":")
+	if len(splits) != 2 || splits[0] == "" || splits[1] == "" {
+		return "", fmt.Errorf("subject pattern %s must be %s<namespace>:<name>", pattern, ServiceAccountSubjectPrefix)
+	}
+	if strings.ContainsAny(splits[0], "*?") {
+		return "", fmt.Errorf("subject pattern %s cannot use wildcards in namespace", pattern)
+	}
+	return splits[0], nil
+}
+
+// MatchStringLike returns true if s matches the pattern of StringLike condition, * matches any characters and ? matches one character
+func MatchStringLike(pattern, s string) bool {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
 	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String()).MatchString(s)
 }
 
 func getIssuerHostpath(oidcProviderArn string) string {
@@ -250,6 +918,44 @@ func getIssuerHostpath(oidcProviderArn string) string {
 	return issuerHostpath
 }
 
+// AccountIDByArn returns the aws account id in arn, e.g. arn:aws:iam::000000000000:role/name
+func AccountIDByArn(arn string) string {
+	splits := strings.SplitN(arn, ":", 6)
+	if len(splits) != 6 {
+		return ""
+	}
+	return splits[4]
+}
+
+// PartitionByArn returns the aws partition in arn, default is aws
+func PartitionByArn(arn string) string {
+	splits := strings.SplitN(arn, ":", 6)
+	if len(splits) != 6 || splits[1] == "" {
+		return "aws"
+	}
+	return splits[1]
+}
+
+// ManagedPolicyArn returns the arn of managed policy, the name of aws managed policy, e.g. AmazonS3ReadOnlyAccess
+// or service-role/AmazonEC2RoleforSSM, is resolved to the arn in the same partition as the iam arn
+func ManagedPolicyArn(arn, policy string) string {
+	if strings.HasPrefix(policy, "arn:") {
+		return policy
+	}
+	return fmt.Sprintf("arn:%s:iam::aws:policy/%s", PartitionByArn(arn), strings.TrimPrefix(policy, "/"))
+}
+
+// PolicyArnByArn returns the arn of customer managed policy in the same partition and account as the iam arn,
+// e.g. arn:aws:iam::000000000000:oidc-provider/name
+func PolicyArnByArn(arn, policyName string) string {
+	splits := strings.SplitN(arn, ":", 6)
+	if len(splits) != 6 {
+		return ""
+	}
+	// the arn of sts, e.g. arn:aws:sts::000000000000:assumed-role/name, is in the same partition and account
+	return fmt.Sprintf("arn:%s:iam::%s:policy/%s", splits[1], splits[4], policyName)
+}
+
 func RoleNameByArn(roleArn string) string {
 	splits := strings.Split(roleArn, "/")
 	return splits[len(splits)-1]
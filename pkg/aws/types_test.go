@@ -17,11 +17,14 @@ limitations under the License.
 package aws
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestNewIamRole(t *testing.T) {
@@ -81,8 +84,8 @@ func TestNewIamRole(t *testing.T) {
 							Action:   []string{"*"},
 							Effect:   StatementAllow,
 							Condition: StatementCondition{
-								"StringEquals": map[string]string{
-									"key": "value",
+								"StringEquals": map[string]ConditionValues{
+									"key": {"value"},
 								},
 							},
 						},
@@ -121,16 +124,474 @@ func TestNewIamRole(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "new a iam role bound to multiple service accounts",
+			args: args{
+				oidcProviderArn: testOidcProviderArn,
+				irsa: &irsav1alpha1.IamRoleServiceAccount{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+						ServiceAccounts: []irsav1alpha1.ServiceAccountRef{
+							{Name: "app"},
+							{Name: "app", Namespace: "default"},
+							{Name: "app", Namespace: "other"},
+						},
+					},
+				},
+			},
+			want: &IamRole{
+				AssumeRolePolicy: assumeRoleDocument2Pointer(NewServiceAccountsAssumeRolePolicy(testOidcProviderArn, []types.NamespacedName{
+					{Namespace: "default", Name: "app"},
+					{Namespace: "other", Name: "app"},
+				}, nil)),
+				Tags: map[string]string{
+					IrsaContollerManagedTagKey: IrsaContollerManagedTagVal,
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewIamRole(tt.args.oidcProviderArn, tt.args.irsa, nil); !reflect.DeepEqual(got, tt.want) {
+			if got, _ := NewIamRole(tt.args.oidcProviderArn, tt.args.irsa, nil, nil); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewIamRole() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestParseRoleDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    *RoleDocument
+		wantErr bool
+	}{
+		{
+			name: "parse document with single statement and list condition",
+			doc: `{
+				"Version": "2012-10-17",
+				"Statement": {
+					"Sid": "ReadBucket",
+					"Effect": "Allow",
+					"Action": "s3:GetObject",
+					"Resource": ["arn:aws:s3:::bucket/*"],
+					"Condition": {"StringEquals": {"aws:SourceVpce": ["vpce-1", "vpce-2"]}, "Bool": {"aws:SecureTransport": true}}
+				}
+			}`,
+			want: &RoleDocument{
+				Version: "2012-10-17",
+				Statement: []RoleStatement{
+					{
+						Sid:      "ReadBucket",
+						Effect:   StatementAllow,
+						Action:   []string{"s3:GetObject"},
+						Resource: []string{"arn:aws:s3:::bucket/*"},
+						Condition: StatementCondition{
+							"StringEquals": {"aws:SourceVpce": {"vpce-1", "vpce-2"}},
+							"Bool":         {"aws:SecureTransport": {"true"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "parse document with NotAction and NotResource",
+			doc:  `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","NotAction":["iam:*"],"NotResource":"*"}]}`,
+			want: &RoleDocument{
+				Version: "2012-10-17",
+				Statement: []RoleStatement{
+					{
+						Effect:      StatementDeny,
+						NotAction:   []string{"iam:*"},
+						NotResource: []string{"*"},
+					},
+				},
+			},
+		},
+		{
+			name:    "invalid json",
+			doc:     `{"Version":"2012-10-17",`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			doc:     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"*","Resource":"*"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "both action and not action",
+			doc:     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"*","NotAction":"iam:*","Resource":"*"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "no statement",
+			doc:     `{"Version":"2012-10-17","Statement":[]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRoleDocument(tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRoleDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseRoleDocument() = %v, want %v", got, tt.want)
+			}
+			if got == nil {
+				return
+			}
+			// marshalled document should be parsed to the same document
+			doc, err := got.RoleDocumentPolicyDocument()
+			if err != nil {
+				t.Fatalf("Marshal document failed: %v", err)
+			}
+			if again, err := ParseRoleDocument(doc); err != nil || !reflect.DeepEqual(again, got) {
+				t.Fatalf("ParseRoleDocument() marshalled document = %v, %v, want %v", again, err, got)
+			}
+		})
+	}
+}
+
+func TestSplitRoleDocument(t *testing.T) {
+	statement := func(resourceLen int) RoleStatement {
+		return RoleStatement{
+			Effect:   StatementAllow,
+			Action:   []string{"s3:GetObject"},
+			Resource: []string{"arn:aws:s3:::" + strings.Repeat("a", resourceLen)},
+		}
+	}
+	tests := []struct {
+		name      string
+		statement []RoleStatement
+		wantCount []int
+		wantErr   bool
+	}{
+		{
+			name:      "small document is not split",
+			statement: []RoleStatement{statement(10), statement(10)},
+			wantCount: []int{2},
+		},
+		{
+			name:      "statements are packed in order",
+			statement: []RoleStatement{statement(2500), statement(2500), statement(2500), statement(10)},
+			wantCount: []int{2, 2},
+		},
+		{
+			name:      "statement over than limit",
+			statement: []RoleStatement{statement(10), statement(ManagedPolicySizeLimit)},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &RoleDocument{Version: "2012-10-17", Statement: tt.statement}
+			got, err := SplitRoleDocument(doc, ManagedPolicySizeLimit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SplitRoleDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, ok := err.(*PolicySizeExceededError); !ok {
+					t.Fatalf("SplitRoleDocument() error = %v, want PolicySizeExceededError", err)
+				}
+				return
+			}
+			var gotCount []int
+			var gotStatement []RoleStatement
+			for _, policy := range got {
+				size, err := policy.Size()
+				if err != nil || size > ManagedPolicySizeLimit {
+					t.Fatalf("SplitRoleDocument() size of policy = %d, %v, want not over than %d", size, err, ManagedPolicySizeLimit)
+				}
+				gotCount = append(gotCount, len(policy.Statement))
+				gotStatement = append(gotStatement, policy.Statement...)
+			}
+			if !reflect.DeepEqual(gotCount, tt.wantCount) || !reflect.DeepEqual(gotStatement, tt.statement) {
+				t.Fatalf("SplitRoleDocument() statement count = %v, want %v", gotCount, tt.wantCount)
+			}
+		})
+	}
+}
+
 func assumeRoleDocument2Pointer(a AssumeRoleDocument) *AssumeRoleDocument {
 	return &a
 }
+
+func TestAssumeRoleDocument_IsAllowOIDC(t *testing.T) {
+	doc := NewServiceAccountsAssumeRolePolicy(testOidcProviderArn, []types.NamespacedName{{Namespace: "default", Name: "app"}}, &irsav1alpha1.TrustSpec{
+		SubjectPatterns: []string{"system:serviceaccount:team-a:*", "system:serviceaccount:team-b:job-?"},
+	})
+	hostpath := getIssuerHostpath(testOidcProviderArn)
+	for _, st := range doc.Statement {
+		if !reflect.DeepEqual(st.Condition["StringEquals"][hostpath+":aud"], ConditionValues{DefaultAudience}) {
+			t.Fatalf("audience should be enforced, but got: %v", st.Condition)
+		}
+	}
+	// the trust relationship created before audience is enforced
+	legacy := AssumeRoleDocument{
+		Statement: []AssumeRoleStatement{
+			{
+				Effect:    StatementAllow,
+				Principal: AssumeRoleStatementPrincipal{Federated: testOidcProviderArn},
+				Action:    AssumeRoleWithWebIdentityAction,
+				Condition: StatementCondition{"StringEquals": {hostpath + ":sub": {"system:serviceaccount:legacy:app"}}},
+			},
+		},
+	}
+	tests := []struct {
+		name      string
+		doc       *AssumeRoleDocument
+		oidc      string
+		audience  string
+		namespace string
+		sa        string
+		want      bool
+	}{
+		{name: "StringEquals subject", doc: &doc, oidc: testOidcProviderArn, audience: DefaultAudience, namespace: "default", sa: "app", want: true},
+		{name: "StringLike subject", doc: &doc, oidc: testOidcProviderArn, audience: DefaultAudience, namespace: "team-a", sa: "worker", want: true},
+		{name: "StringLike single character", doc: &doc, oidc: testOidcProviderArn, audience: DefaultAudience, namespace: "team-b", sa: "job-1", want: true},
+		{name: "StringLike not matched", doc: &doc, oidc: testOidcProviderArn, audience: DefaultAudience, namespace: "team-b", sa: "job-10", want: false},
+		{name: "other namespace", doc: &doc, oidc: testOidcProviderArn, audience: DefaultAudience, namespace: "default", sa: "other", want: false},
+		{name: "other oidc provider", doc: &doc, oidc: "arn:aws:iam::000000000000:oidc-provider/other", audience: DefaultAudience, namespace: "default", sa: "app", want: false},
+		{name: "other audience", doc: &doc, oidc: testOidcProviderArn, audience: "vault", namespace: "default", sa: "app", want: false},
+		{name: "subject without audience", doc: &legacy, oidc: testOidcProviderArn, audience: DefaultAudience, namespace: "legacy", sa: "app", want: false},
+		{name: "subject without audience of any audience", doc: &legacy, oidc: testOidcProviderArn, namespace: "legacy", sa: "app", want: true},
+		{name: "nil document", oidc: testOidcProviderArn, audience: DefaultAudience, namespace: "default", sa: "app", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.doc.IsAllowOIDC(tt.oidc, tt.audience, tt.namespace, tt.sa); got != tt.want {
+				t.Errorf("AssumeRoleDocument.IsAllowOIDC() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSubjectPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{name: "namespace wide", pattern: "system:serviceaccount:team-a:*", want: "team-a"},
+		{name: "exact subject", pattern: "system:serviceaccount:team-a:app", want: "team-a"},
+		{name: "wildcard namespace", pattern: "system:serviceaccount:team-*:app", wantErr: true},
+		{name: "any subject", pattern: "*", wantErr: true},
+		{name: "missing name", pattern: "system:serviceaccount:team-a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateSubjectPattern(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateSubjectPattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateSubjectPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTrustPrincipal(t *testing.T) {
+	github := "arn:aws:iam::000000000000:oidc-provider/token.actions.githubusercontent.com"
+	tests := []struct {
+		name      string
+		principal irsav1alpha1.TrustPrincipal
+		wantErr   bool
+	}{
+		{name: "aws role", principal: irsav1alpha1.TrustPrincipal{AWS: "arn:aws:iam::000000000000:role/ci"}},
+		{name: "service", principal: irsav1alpha1.TrustPrincipal{Service: "ec2.amazonaws.com"}},
+		{name: "federated with condition", principal: irsav1alpha1.TrustPrincipal{Federated: github, Condition: irsav1alpha1.StatementConditionSpec{
+			"StringEquals": {"token.actions.githubusercontent.com:sub": "repo:org/repo:ref:refs/heads/main"},
+		}}},
+		{name: "federated without condition", principal: irsav1alpha1.TrustPrincipal{Federated: github}, wantErr: true},
+		{name: "anyone", principal: irsav1alpha1.TrustPrincipal{AWS: "*"}, wantErr: true},
+		{name: "any account", principal: irsav1alpha1.TrustPrincipal{AWS: "arn:aws:iam::*:root"}, wantErr: true},
+		{name: "multiple principals", principal: irsav1alpha1.TrustPrincipal{AWS: "000000000000", Service: "ec2.amazonaws.com"}, wantErr: true},
+		{name: "empty", principal: irsav1alpha1.TrustPrincipal{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateTrustPrincipal(tt.principal); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTrustPrincipal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewServiceAccountsAssumeRolePolicy_additionalPrincipals(t *testing.T) {
+	doc := NewServiceAccountsAssumeRolePolicy(testOidcProviderArn, nil, &irsav1alpha1.TrustSpec{
+		AdditionalPrincipals: []irsav1alpha1.TrustPrincipal{
+			{AWS: "111111111111"},
+			{AWS: "arn:aws:iam::111111111111:role/ci", Action: "sts:TagSession"},
+			{Service: "ec2.amazonaws.com"},
+			{Federated: "arn:aws:iam::111111111111:oidc-provider/token.actions.githubusercontent.com", Condition: irsav1alpha1.StatementConditionSpec{
+				"StringEquals": {"token.actions.githubusercontent.com:aud": "sts.amazonaws.com"},
+			}},
+		},
+	})
+	want := []AssumeRoleStatement{
+		{Effect: StatementAllow, Principal: AssumeRoleStatementPrincipal{AWS: "arn:aws:iam::111111111111:root"}, Action: AssumeRoleAction},
+		{Effect: StatementAllow, Principal: AssumeRoleStatementPrincipal{AWS: "arn:aws:iam::111111111111:role/ci"}, Action: "sts:TagSession"},
+		{Effect: StatementAllow, Principal: AssumeRoleStatementPrincipal{Service: "ec2.amazonaws.com"}, Action: AssumeRoleAction},
+		{
+			Effect:    StatementAllow,
+			Principal: AssumeRoleStatementPrincipal{Federated: "arn:aws:iam::111111111111:oidc-provider/token.actions.githubusercontent.com"},
+			Action:    AssumeRoleWithWebIdentityAction,
+			Condition: StatementCondition{"StringEquals": {"token.actions.githubusercontent.com:aud": {"sts.amazonaws.com"}}},
+		},
+	}
+	if !reflect.DeepEqual(doc.Statement, want) {
+		t.Fatalf("NewServiceAccountsAssumeRolePolicy() = %v, want %v", doc.Statement, want)
+	}
+	if err := ValidateTrustPrincipal(irsav1alpha1.TrustPrincipal{AWS: "111111111111", Service: "ec2.amazonaws.com"}); err == nil {
+		t.Fatalf("ValidateTrustPrincipal() should reject more than one principal")
+	}
+	if err := ValidateTrustPrincipal(irsav1alpha1.TrustPrincipal{}); err == nil {
+		t.Fatalf("ValidateTrustPrincipal() should reject empty principal")
+	}
+}
+
+func TestAssumeRoleDocument_AllowSubjects(t *testing.T) {
+	hostpath := getIssuerHostpath(testOidcProviderArn)
+	principal := AssumeRoleStatement{Effect: StatementAllow, Principal: AssumeRoleStatementPrincipal{Service: "ec2.amazonaws.com"}, Action: AssumeRoleAction}
+	// the trust relationship with one statement per service account
+	doc := AssumeRoleDocument{Version: "2012-10-17", Statement: []AssumeRoleStatement{
+		NewAssumeRolePolicy(testOidcProviderArn, "default", "a").Statement[0],
+		principal,
+		NewAssumeRolePolicy(testOidcProviderArn, "default", "b").Statement[0],
+	}}
+	doc.AllowSubjects(testOidcProviderArn, DefaultAudience, []string{ServiceAccountSubject("default", "b"), ServiceAccountSubject("default", "c")})
+	want := []AssumeRoleStatement{
+		newSubjectStatement(testOidcProviderArn, DefaultAudience, "StringEquals", []string{
+			ServiceAccountSubject("default", "a"), ServiceAccountSubject("default", "b"), ServiceAccountSubject("default", "c"),
+		}),
+		principal,
+	}
+	if !reflect.DeepEqual(doc.Statement, want) {
+		t.Fatalf("AllowSubjects() = %v, want %v", doc.Statement, want)
+	}
+
+	// 1. revoke subject should keep the others
+	if !doc.RevokeSubjects(testOidcProviderArn, []string{ServiceAccountSubject("default", "b")}) {
+		t.Fatalf("1 RevokeSubjects() should change the document")
+	}
+	if got := doc.Statement[0].Condition["StringEquals"][hostpath+":sub"]; !reflect.DeepEqual(got, ConditionValues{ServiceAccountSubject("default", "a"), ServiceAccountSubject("default", "c")}) {
+		t.Fatalf("1 RevokeSubjects() left subjects %v", got)
+	}
+	// 2. revoke subject not trusted should change nothing
+	if doc.RevokeSubjects(testOidcProviderArn, []string{ServiceAccountSubject("default", "b")}) {
+		t.Fatalf("2 RevokeSubjects() should not change the document")
+	}
+	// 3. revoke all subjects should drop the statement
+	doc.RevokeSubjects(testOidcProviderArn, []string{ServiceAccountSubject("default", "a"), ServiceAccountSubject("default", "c")})
+	if !reflect.DeepEqual(doc.Statement, []AssumeRoleStatement{principal}) {
+		t.Fatalf("3 RevokeSubjects() = %v, want only the principal statement", doc.Statement)
+	}
+	// 4. subjects of other audience should be allowed in the statement of the audience
+	doc.AllowSubjects(testOidcProviderArn, "vault", []string{ServiceAccountSubject("default", "a")})
+	if !reflect.DeepEqual(doc.Statement[1], newSubjectStatement(testOidcProviderArn, "vault", "StringEquals", []string{ServiceAccountSubject("default", "a")})) {
+		t.Fatalf("4 AllowSubjects() = %v, want the statement of audience vault", doc.Statement)
+	}
+	if !doc.IsAllowOIDC(testOidcProviderArn, "vault", "default", "a") || doc.IsAllowOIDC(testOidcProviderArn, DefaultAudience, "default", "a") {
+		t.Fatalf("4 subject should be allowed by audience vault only, but got: %v", doc.Statement)
+	}
+}
+
+func TestAssumeRoleDocument_AllowSubjectsWithoutAudience(t *testing.T) {
+	subKey := getIssuerHostpath(testOidcProviderArn) + ":sub"
+	// the trust relationship written by the earlier versions, which does not enforce the audience
+	baseline := func(namespace, name string) AssumeRoleStatement {
+		return AssumeRoleStatement{
+			Effect:    StatementAllow,
+			Principal: AssumeRoleStatementPrincipal{Federated: testOidcProviderArn},
+			Action:    AssumeRoleWithWebIdentityAction,
+			Condition: StatementCondition{"StringEquals": {subKey: ConditionValues{ServiceAccountSubject(namespace, name)}}},
+		}
+	}
+	doc := AssumeRoleDocument{Version: "2012-10-17", Statement: []AssumeRoleStatement{baseline("default", "a"), baseline("default", "b")}}
+
+	// 1. the allowed subject should be moved to the statement of audience, the others are kept
+	doc.AllowSubjects(testOidcProviderArn, DefaultAudience, []string{ServiceAccountSubject("default", "a")})
+	want := []AssumeRoleStatement{
+		baseline("default", "b"),
+		newSubjectStatement(testOidcProviderArn, DefaultAudience, "StringEquals", []string{ServiceAccountSubject("default", "a")}),
+	}
+	if !reflect.DeepEqual(doc.Statement, want) {
+		t.Fatalf("1 AllowSubjects() = %v, want %v", doc.Statement, want)
+	}
+	if doc.IsAllowOIDC(testOidcProviderArn, "vault", "default", "a") {
+		t.Fatalf("1 subject should not be trusted for other audience, but got: %v", doc.Statement)
+	}
+
+	// 2. the statement without aud condition should be dropped once all of its subjects are moved
+	doc.AllowSubjects(testOidcProviderArn, DefaultAudience, []string{ServiceAccountSubject("default", "b")})
+	want = []AssumeRoleStatement{
+		newSubjectStatement(testOidcProviderArn, DefaultAudience, "StringEquals", []string{ServiceAccountSubject("default", "a"), ServiceAccountSubject("default", "b")}),
+	}
+	if !reflect.DeepEqual(doc.Statement, want) {
+		t.Fatalf("2 AllowSubjects() = %v, want %v", doc.Statement, want)
+	}
+}
+
+func TestAssumeRoleDocument_CheckSize(t *testing.T) {
+	var sas []types.NamespacedName
+	for i := 0; i < 5; i++ {
+		sas = append(sas, types.NamespacedName{Namespace: "default", Name: fmt.Sprintf("sa-%d", i)})
+	}
+	doc := NewServiceAccountsAssumeRolePolicy(testOidcProviderArn, sas, nil)
+	if err := doc.CheckSize(TrustPolicySizeLimit); err != nil {
+		t.Fatalf("CheckSize() error = %v", err)
+	}
+	for i := 5; i < 60; i++ {
+		sas = append(sas, types.NamespacedName{Namespace: "default", Name: fmt.Sprintf("sa-%d", i)})
+	}
+	doc = NewServiceAccountsAssumeRolePolicy(testOidcProviderArn, sas, nil)
+	if _, ok := doc.CheckSize(TrustPolicySizeLimit).(*PolicySizeExceededError); !ok {
+		t.Fatalf("CheckSize() should return PolicySizeExceededError")
+	}
+	// the quota of trust policy is raised
+	if err := doc.CheckSize(MaxTrustPolicySizeLimit); err != nil {
+		t.Fatalf("CheckSize() of raised limit error = %v", err)
+	}
+}
+
+func TestNewServiceAccountsAssumeRolePolicy_oidcProviders(t *testing.T) {
+	otherOidcProviderArn := "arn:aws:iam::000000000000:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/OTHER"
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Trust: &irsav1alpha1.TrustSpec{
+				SubjectPatterns: []string{"system:serviceaccount:default:job-*"},
+				OIDCProviders:   []irsav1alpha1.OIDCProvider{{Arn: otherOidcProviderArn, Cluster: "green"}},
+			},
+		},
+	}
+	role, err := NewIamRole(testOidcProviderArn, irsa, map[string]string{"team": "a"}, nil)
+	if err != nil {
+		t.Fatalf("NewIamRole() error = %v", err)
+	}
+	if len(role.AssumeRolePolicy.Statement) != 4 {
+		t.Fatalf("NewIamRole() should trust subjects and subject patterns of both oidc providers, but got: %v", role.AssumeRolePolicy.Statement)
+	}
+	for _, oidc := range []string{testOidcProviderArn, otherOidcProviderArn} {
+		if !role.AssumeRolePolicy.IsAllowOIDC(oidc, DefaultAudience, "default", "app") || !role.AssumeRolePolicy.IsAllowOIDC(oidc, DefaultAudience, "default", "job-1") {
+			t.Fatalf("NewIamRole() should trust oidc provider %s, but got: %v", oidc, role.AssumeRolePolicy.Statement)
+		}
+	}
+	wantTags := map[string]string{"team": "a", IrsaContollerManagedTagKey: IrsaContollerManagedTagVal}
+	if !reflect.DeepEqual(role.Tags, wantTags) {
+		t.Fatalf("NewIamRole() tags = %v, want %v", role.Tags, wantTags)
+	}
+
+	if err := ValidateOIDCProvider(irsav1alpha1.OIDCProvider{Arn: otherOidcProviderArn}); err == nil {
+		t.Fatalf("ValidateOIDCProvider() should reject provider without cluster")
+	}
+	if err := ValidateOIDCProvider(irsav1alpha1.OIDCProvider{Arn: "arn:aws:iam::000000000000:role/app", Cluster: "green"}); err == nil {
+		t.Fatalf("ValidateOIDCProvider() should reject arn which is not oidc provider")
+	}
+}
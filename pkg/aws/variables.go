@@ -0,0 +1,139 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"regexp"
+	"strings"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+// variablePattern matches the variables of irsa-controller, e.g. ${namespace} or ${labels.team},
+// iam policy variables, e.g. ${aws:username} or ${*}, are not matched
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z][A-Za-z0-9_./-]*)\}`)
+
+// oidcRegionPattern matches the region in the url of eks oidc provider, e.g. oidc.eks.us-west-2.amazonaws.com/id/xxx
+var oidcRegionPattern = regexp.MustCompile(`oidc\.eks\.([a-z0-9-]+)\.amazonaws\.com`)
+
+const (
+	VariableNamespace = "namespace"
+	VariableName      = "name"
+	VariableCluster   = "cluster"
+	VariableAccountID = "accountId"
+	VariableRegion    = "region"
+	VariablePartition = "partition"
+	// VariableLabelPrefix is the prefix of variables of irsa labels, e.g. ${labels.team}
+	VariableLabelPrefix = "labels."
+	// VariableNamespaceLabelPrefix is the prefix of variables of namespace labels, e.g. ${namespace.labels.team}
+	VariableNamespaceLabelPrefix = "namespace.labels."
+)
+
+// PolicyVariables are the variables which can be used in the statements and tags of irsa
+type PolicyVariables map[string]string
+
+// NewPolicyVariables returns the variables of irsa, region is empty if it cannot be found in the oidc provider
+func NewPolicyVariables(oidcProviderArn, cluster string, irsa *irsav1alpha1.IamRoleServiceAccount, namespaceLabels map[string]string) PolicyVariables {
+	vars := PolicyVariables{
+		VariableNamespace: irsa.GetNamespace(),
+		VariableName:      irsa.GetName(),
+		VariableCluster:   cluster,
+		VariableAccountID: AccountIDByArn(oidcProviderArn),
+		VariablePartition: PartitionByArn(oidcProviderArn),
+	}
+	if region := RegionByOIDCProviderArn(oidcProviderArn); region != "" {
+		vars[VariableRegion] = region
+	}
+	for k, v := range irsa.GetLabels() {
+		vars[VariableLabelPrefix+k] = v
+	}
+	for k, v := range namespaceLabels {
+		vars[VariableNamespaceLabelPrefix+k] = v
+	}
+	return vars
+}
+
+// RegionByOIDCProviderArn returns the region of eks oidc provider, returns empty if it is not an eks oidc provider
+func RegionByOIDCProviderArn(oidcProviderArn string) string {
+	if matches := oidcRegionPattern.FindStringSubmatch(oidcProviderArn); len(matches) == 2 {
+		return matches[1]
+	}
+	return ""
+}
+
+// Render replaces the variables in s, returns error if any variable is unresolved
+func (v PolicyVariables) Render(s string) (string, error) {
+	var unresolved []string
+	res := variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		val, ok := v[name]
+		if !ok || val == "" {
+			unresolved = append(unresolved, match)
+			return match
+		}
+		return val
+	})
+	if len(unresolved) > 0 {
+		return res, errors.Errorf("Unresolved variables %s", strings.Join(unresolved, ", "))
+	}
+	return res, nil
+}
+
+// RenderIrsa returns a copy of irsa whose policy statements and tags are rendered, returns the first error of rendering
+func (v PolicyVariables) RenderIrsa(irsa *irsav1alpha1.IamRoleServiceAccount) (*irsav1alpha1.IamRoleServiceAccount, error) {
+	var renderErr error
+	render := func(s string) string {
+		res, err := v.Render(s)
+		if err != nil && renderErr == nil {
+			renderErr = err
+		}
+		return res
+	}
+	renderList := func(list []string) {
+		for idx := range list {
+			list[idx] = render(list[idx])
+		}
+	}
+	renderPolicy := func(policy *irsav1alpha1.InlinePolicySpec) {
+		for idx := range policy.Statement {
+			statement := &policy.Statement[idx]
+			renderList(statement.Resource)
+			renderList(statement.Action)
+			for _, condition := range statement.Condition {
+				for key, val := range condition {
+					condition[key] = render(val)
+				}
+			}
+		}
+	}
+
+	res := irsa.DeepCopy()
+	for k, val := range res.Spec.Tags {
+		res.Spec.Tags[k] = render(val)
+	}
+	if policy := res.Spec.Policy; policy != nil {
+		if policy.InlinePolicy != nil {
+			renderPolicy(policy.InlinePolicy)
+		}
+		for idx := range policy.InlinePolicies {
+			renderPolicy(&policy.InlinePolicies[idx].InlinePolicySpec)
+		}
+		policy.InlinePolicyDocument = render(policy.InlinePolicyDocument)
+	}
+	return res, renderErr
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 domechn.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"testing"
+
+	irsav1alpha1 "domc.me/irsa-controller/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPolicyVariables_Render(t *testing.T) {
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			Labels:    map[string]string{"app.kubernetes.io/name": "app"},
+		},
+	}
+	vars := NewPolicyVariables("arn:aws-cn:iam::000000000000:oidc-provider/oidc.eks.cn-north-1.amazonaws.com.cn/id/ABC", "cluster", irsa, map[string]string{"team": "data"})
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "render builtin variables",
+			s:    "arn:${partition}:s3:::${cluster}-${namespace}-${name}-${region}-${accountId}/*",
+			want: "arn:aws-cn:s3:::cluster-default-test-cn-north-1-000000000000/*",
+		},
+		{
+			name: "render labels of irsa and namespace",
+			s:    "${labels.app.kubernetes.io/name}-${namespace.labels.team}",
+			want: "app-data",
+		},
+		{
+			name: "iam policy variables are kept",
+			s:    "arn:aws:s3:::bucket/${aws:PrincipalTag/team}/${*}",
+			want: "arn:aws:s3:::bucket/${aws:PrincipalTag/team}/${*}",
+		},
+		{
+			name:    "unresolved variable",
+			s:       "${namespace.labels.owner}",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := vars.Render(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PolicyVariables.Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Fatalf("PolicyVariables.Render() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyVariables_RenderIrsa(t *testing.T) {
+	irsa := &irsav1alpha1.IamRoleServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: irsav1alpha1.IamRoleServiceAccountSpec{
+			Tags: map[string]string{"namespace": "${namespace}"},
+			Policy: &irsav1alpha1.PolicySpec{
+				InlinePolicy: &irsav1alpha1.InlinePolicySpec{
+					Version: "2012-10-17",
+					Statement: []irsav1alpha1.StatementSpec{
+						{
+							Resource:  []string{"arn:aws:s3:::data-${namespace}/*"},
+							Action:    []string{"s3:GetObject"},
+							Effect:    string(StatementAllow),
+							Condition: irsav1alpha1.StatementConditionSpec{"StringEquals": {"aws:ResourceTag/owner": "${name}"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	vars := NewPolicyVariables(testOidcProviderArn, "cluster", irsa, nil)
+	role, err := NewIamRole(testOidcProviderArn, irsa, nil, vars)
+	if err != nil {
+		t.Fatalf("NewIamRole() error = %v", err)
+	}
+	statement := role.InlinePolicy.Statement[0]
+	if statement.Resource[0] != "arn:aws:s3:::data-default/*" || statement.Condition["StringEquals"]["aws:ResourceTag/owner"][0] != "test" || role.Tags["namespace"] != "default" {
+		t.Fatalf("NewIamRole() should render variables, but got: %v, %v", statement, role.Tags)
+	}
+	if irsa.Spec.Policy.InlinePolicy.Statement[0].Resource[0] != "arn:aws:s3:::data-${namespace}/*" {
+		t.Fatalf("RenderIrsa() should not modify irsa")
+	}
+
+	irsa.Spec.Tags["owner"] = "${labels.owner}"
+	if _, err := vars.RenderIrsa(irsa); err == nil {
+		t.Fatalf("RenderIrsa() should reject unresolved variables")
+	}
+	if _, err := NewIamRole(testOidcProviderArn, irsa, nil, vars); err == nil {
+		t.Fatalf("NewIamRole() should return the error of unresolved variables")
+	}
+}
@@ -40,3 +40,23 @@ func RemoveString(slice []string, s string) (result []string) {
 func Equal(a, b []string) bool {
 	return slices.Equal(a, b)
 }
+
+// Difference returns the items in a but not in b
+func Difference(a, b []string) (result []string) {
+	for _, item := range a {
+		if !ContainsString(b, item) {
+			result = append(result, item)
+		}
+	}
+	return
+}
+
+// Union returns the items in a, followed by the items in b but not in a, duplicated items are removed
+func Union(a, b []string) (result []string) {
+	for _, item := range append(append([]string{}, a...), b...) {
+		if !ContainsString(result, item) {
+			result = append(result, item)
+		}
+	}
+	return
+}
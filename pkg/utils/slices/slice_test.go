@@ -136,3 +136,74 @@ func TestEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestDifference(t *testing.T) {
+	type args struct {
+		a []string
+		b []string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantResult []string
+	}{
+		{
+			name: "Items not in b",
+			args: args{
+				a: []string{"test", "test2", "test3"},
+				b: []string{"test2"},
+			},
+			wantResult: []string{"test", "test3"},
+		},
+		{
+			name: "All items in b",
+			args: args{
+				a: []string{"test"},
+				b: []string{"test", "test2"},
+			},
+			wantResult: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotResult := Difference(tt.args.a, tt.args.b); !reflect.DeepEqual(gotResult, tt.wantResult) {
+				t.Errorf("Difference() = %v, want %v", gotResult, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestUnion(t *testing.T) {
+	type args struct {
+		a []string
+		b []string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantResult []string
+	}{
+		{
+			name: "Items in a or b",
+			args: args{
+				a: []string{"test", "test2"},
+				b: []string{"test3", "test2"},
+			},
+			wantResult: []string{"test", "test2", "test3"},
+		},
+		{
+			name: "Duplicated items in a",
+			args: args{
+				a: []string{"test", "test"},
+			},
+			wantResult: []string{"test"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotResult := Union(tt.args.a, tt.args.b); !reflect.DeepEqual(gotResult, tt.wantResult) {
+				t.Errorf("Union() = %v, want %v", gotResult, tt.wantResult)
+			}
+		})
+	}
+}